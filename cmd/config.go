@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/config"
+	"github.com/Jassem-HCP/nompose/internal/image"
+	"github.com/Jassem-HCP/nompose/internal/types"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect nompose configuration",
+	Long: `Nompose reads its settings from, in increasing order of precedence:
+  1. ~/.nompose/config.yml
+  2. .nompose.yml next to the source being converted
+  3. NOMPOSE_* environment variables (e.g. NOMPOSE_RESOURCES_CPU=500)
+  4. flags passed to nompose generate
+
+Image rewrite rules are read from the image_rewrites list of the config
+files, project rules first:
+  image_rewrites:
+    - match: postgres
+      tag: ^16$
+      pin: "16.4"                          # pin a tag
+    - match: docker.io/*                   # then pull Docker Hub images through a mirror
+      replace: registry.corp/dockerhub/*
+    - match: "*"
+      tag: ^latest$
+      lock: true                           # use the digest recorded in nompose.lock`,
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show [source]",
+	Short: "Show the effective configuration and where each value comes from",
+	Example: `  nompose config show
+  nompose config show ./my-project/docker-compose.yml`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runConfigShow,
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configShowCmd)
+}
+
+func runConfigShow(cmd *cobra.Command, args []string) error {
+	projectDir := "."
+	if len(args) > 0 {
+		projectDir = config.ProjectDir(args[0])
+	}
+
+	cfg, err := config.Load(projectDir)
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+
+	fmt.Printf("⚙️  Effective configuration:\n")
+	fmt.Print(cfg.Table("   "))
+	if rewrites := cfg.Rewrites(); len(rewrites) > 0 {
+		fmt.Printf("\n🔁 Image rewrites, in the order they apply:\n")
+		for i, rewrite := range rewrites {
+			fmt.Printf("   %d. %s (%s)\n", i+1, describeRewrite(rewrite.Rule), rewrite.Source)
+		}
+	}
+	fmt.Printf("\n💡 Flags passed to nompose generate override these values\n")
+
+	return nil
+}
+
+// describeRewrite summarizes a rewrite rule on one line
+func describeRewrite(rule types.ImageRewrite) string {
+	parts := []string{rule.Match}
+	if rule.Tag != "" {
+		parts = append(parts, fmt.Sprintf("tag ~ %s", rule.Tag))
+	}
+	if rule.Replace != "" {
+		parts = append(parts, "→ "+rule.Replace)
+	}
+	if rule.Pin != "" {
+		parts = append(parts, "pin "+rule.Pin)
+	}
+	if rule.Lock {
+		parts = append(parts, "digest from "+image.LockFileName)
+	}
+	return strings.Join(parts, " ")
+}
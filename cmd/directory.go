@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/parser"
+	"github.com/Jassem-HCP/nompose/internal/scanner"
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+func handleProcfile(filePath string) error {
+	fmt.Fprintf(progress, "📋 Parsing Procfile...\n")
+
+	envFile := filepath.Join(filepath.Dir(filePath), ".env")
+	if _, err := os.Stat(envFile); err != nil {
+		envFile = ""
+	}
+	services, err := parseSource(scanner.Source{Type: types.SourceProcfile, Path: filePath, EnvFile: envFile})
+	if err != nil {
+		return err
+	}
+
+	if err := checkOverrides(services); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(progress, "✅ Found %d processes:\n", len(services))
+	printServices(services)
+
+	return handleServices(services)
+}
+
+func handleLocalDirectory(dir string) error {
+	services, err := directoryServices(dir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(progress, "✅ Found %d services:\n", len(services))
+	printServices(services)
+
+	return handleServices(services)
+}
+
+// directoryServices scans dir, lets the user choose among the sources found
+// and parses the chosen ones
+func directoryServices(dir string) ([]types.EnhancedServiceConfig, error) {
+	fmt.Fprintf(progress, "📂 Scanning directory...\n")
+
+	// --type dockerfile on a directory asks for its Dockerfile.<env> variants too
+	directoryScanner := scanner.NewScanner()
+	directoryScanner.DockerfileVariants = generateOptions.ForceType == string(types.SourceDockerfile)
+	plan, err := directoryScanner.Scan(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan directory: %w", err)
+	}
+	if len(plan.Sources) == 0 {
+		return nil, fmt.Errorf("❌ no compose files, Dockerfiles or Procfiles found in %s", dir)
+	}
+
+	fmt.Fprintf(progress, "✅ Found %d deployable sources:\n", len(plan.Sources))
+	for i, name := range plan.Names() {
+		fmt.Fprintf(progress, "   %d. %-15s %s\n", i+1, plan.Sources[i].Type, name)
+	}
+	for _, skipped := range plan.Skipped {
+		fmt.Fprintf(progress, "   ⏭️  Skipped %s\n", skipped)
+	}
+
+	chosen := []int{0}
+	if len(plan.Sources) > 1 {
+		chosen, err = newConfirmer().ChooseSources(len(plan.Sources))
+		if err != nil {
+			return nil, fmt.Errorf("failed to choose sources: %w", err)
+		}
+	}
+
+	// Every deployable becomes its own job, so names must not collide across sources
+	var services []types.EnhancedServiceConfig
+	taken := make(map[string]bool)
+	for _, index := range chosen {
+		source := plan.Sources[index]
+		parsed, err := parseSource(source)
+		if err != nil {
+			return nil, err
+		}
+		for _, service := range parsed {
+			if taken[service.Name] {
+				renamed := sourcePrefix(plan.Dir, source.Path) + "-" + service.Name
+				fmt.Fprintf(progress, "   ⚠️  %s from %s renamed to %s (name already used)\n", service.Name, plan.Relative(source.Path), renamed)
+				service.Name = renamed
+			}
+			taken[service.Name] = true
+			services = append(services, service)
+		}
+	}
+
+	if err := checkOverrides(services); err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+// parseSource parses one source of a directory plan, applying its .env file
+func parseSource(source scanner.Source) ([]types.EnhancedServiceConfig, error) {
+	var services []types.EnhancedServiceConfig
+	var err error
+	switch source.Type {
+	case types.SourceDockerCompose:
+		services, err = parser.NewDockerComposeParser().ParseFiles(source.Path, source.Overrides...)
+	case types.SourceDockerfile:
+		services, err = parser.NewDockerfileParser().Parse(source.Path)
+	case types.SourceProcfile:
+		services, err = parser.NewProcfileParser().Parse(source.Path)
+	default:
+		return nil, fmt.Errorf("❌ %s sources can't be converted yet", source.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", source.Path, err)
+	}
+
+	if source.EnvFile == "" {
+		return services, nil
+	}
+	env, err := parser.ParseEnvFile(source.EnvFile)
+	if err != nil {
+		return nil, err
+	}
+	for i := range services {
+		if services[i].Environment == nil {
+			services[i].Environment = make(map[string]string)
+		}
+		for key, value := range env {
+			services[i].Environment[key] = value
+		}
+	}
+	return services, nil
+}
+
+// sourcePrefix names a source by its directory, to tell same-named services apart
+func sourcePrefix(root, path string) string {
+	dir := filepath.Dir(path)
+	relative, err := filepath.Rel(root, dir)
+	if err != nil || relative == "." {
+		if absolute, err := filepath.Abs(dir); err == nil {
+			dir = absolute
+		}
+		relative = filepath.Base(dir)
+	}
+	return strings.ReplaceAll(strings.ToLower(filepath.ToSlash(relative)), "/", "-")
+}
+
+// printServices lists parsed services with their image, ports and environment
+func printServices(services []types.EnhancedServiceConfig) {
+	for i, service := range services {
+		fmt.Fprintf(progress, "   %d. %s (%s)\n", i+1, service.Name, service.ResolvedImage)
+		if len(service.ResolvedPorts) > 0 {
+			fmt.Fprintf(progress, "      Ports: %d detected\n", len(service.ResolvedPorts))
+		}
+		if len(service.Environment) > 0 {
+			fmt.Fprintf(progress, "      Environment: %d variables\n", len(service.Environment))
+		}
+		for _, task := range service.Tasks {
+			if task.Lifecycle != "" {
+				fmt.Fprintf(progress, "      Task: %s (%s, %s)\n", task.Name, task.Image, task.Lifecycle)
+			} else {
+				fmt.Fprintf(progress, "      Task: %s (%s)\n", task.Name, task.Image)
+			}
+		}
+	}
+}
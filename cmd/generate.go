@@ -1,15 +1,57 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/Jassem-HCP/nompose/internal/config"
 	"github.com/Jassem-HCP/nompose/internal/detector"
 	"github.com/Jassem-HCP/nompose/internal/generator"
+	"github.com/Jassem-HCP/nompose/internal/git"
+	"github.com/Jassem-HCP/nompose/internal/image"
 	"github.com/Jassem-HCP/nompose/internal/interactive"
 	"github.com/Jassem-HCP/nompose/internal/parser"
+	"github.com/Jassem-HCP/nompose/internal/types"
 	"github.com/spf13/cobra"
 )
 
+// generateOptions holds the flags shared by every generated job
+var generateOptions types.GenerateOptions
+
+// outputDir is where generated files are written
+var outputDir string
+
+// overrideFlags holds the raw --set values
+var overrideFlags []string
+
+// offline skips registry lookups for image sources
+var offline bool
+
+// handleServices is what the running command does with the services found
+// in a source: generate confirms them and writes their jobs
+var handleServices = confirmAndGenerate
+
+// imageLock holds the digests of nompose.lock, nil without a lockfile
+var imageLock *image.Lockfile
+
+// locked requires every image to be pinned by nompose.lock
+var locked bool
+
+// imageRewriter applies the configured image rewrite rules during confirmation
+var imageRewriter *image.Rewriter
+
+// jobOutput receives every generated file when streaming with --output -
+var jobOutput io.Writer
+
+// progress receives progress, prompts and summaries; stderr when the jobs
+// are streamed to stdout
+var progress io.Writer = os.Stdout
+
 var generateCmd = &cobra.Command{
 	Use:   "generate [source]",
 	Short: "Generate Nomad jobs from Docker configurations",
@@ -19,52 +61,330 @@ Supported sources:
   - docker-compose.yml (→ multiple Nomad jobs)
   - Dockerfile (→ single Nomad job) 
   - nginx:latest (→ single Nomad job from image)
-  - ./my-app (→ analyze directory)
-  - https://github.com/user/repo (→ analyze repository)`,
+  - Procfile (→ one Nomad job per process)
+  - Kubernetes YAML (→ one Nomad job per Deployment, StatefulSet and CronJob)
+  - ./my-app (→ every compose file, Dockerfile and Procfile found)
+  - https://github.com/user/repo, file:///srv/git/app.git#v1.2:deploy (→ analyze repository)`,
 	Example: `  nompose generate docker-compose.yml
   nompose generate Dockerfile
   nompose generate nginx:alpine
-  nompose generate ./my-project`,
+  nompose generate ./my-project
+  nompose generate https://github.com/user/repo --ref v1.2.0 --subdir services/api`,
 	Args: cobra.ExactArgs(1),
 	RunE: runGenerate,
 }
 
 func init() {
 	rootCmd.AddCommand(generateCmd)
+
+	flags := generateCmd.Flags()
+	flags.StringVar(&generateOptions.LogDriver, "log-driver", "", "logging driver injected into every job (e.g. fluentd, syslog, json-file)")
+	flags.StringToStringVar(&generateOptions.LogOptions, "log-opt", nil, "logging driver option, repeatable (e.g. --log-opt fluentd-address=localhost:24224)")
+	flags.StringVar(&generateOptions.Mesh, "mesh", "", "service mesh mode (consul-connect)")
+	flags.StringVar(&generateOptions.UpstreamAddr, "upstream-address", generator.UpstreamAddressLocalhost, "how rewritten hostnames reach mesh upstreams (localhost, env)")
+	flags.StringVar(&generateOptions.AddressRewrite, "address-rewrite", generator.AddressRewriteNone, "rewrite references to other services (none, consul-dns, consul-template, nomad-template)")
+	flags.StringVar(&generateOptions.ServiceProvider, "service-provider", generator.ServiceProviderConsul, "service registration provider (consul, nomad)")
+	flags.StringVar(&generateOptions.IngressMode, "ingress", "", "expose services through an ingress (traefik, fabio, consul-gateway)")
+	flags.StringVar(&generateOptions.Image, "image", "", "image a Dockerfile or saved image is deployed as (e.g. registry.example.com/app:1.2.0); also picks the image in a multi-image archive")
+	flags.StringVar(&generateOptions.ForceType, "type", "", "source type, when detection guesses wrong ("+detector.TypeList()+")")
+	flags.StringVar(&gitRef, "ref", "", "branch, tag or commit of a git source (default: the default branch)")
+	flags.StringVar(&gitSubdir, "subdir", "", "directory or file inside a git source to analyze")
+	flags.BoolVar(&locked, "locked", false, "fail unless every image is pinned by nompose.lock")
+	flags.BoolVar(&offline, "offline", false, "don't query registries for the config of image sources")
+	flags.StringVar(&generateOptions.ServiceName, "service-name", "", "job name, when a single job is generated")
+	flags.IntVar(&generateOptions.Port, "port", 0, "published port, when a single job is generated")
+	flags.IntVar(&generateOptions.Instances, "instances", 0, "default group count for services without deploy.replicas")
+	flags.IntVar(&generateOptions.CPU, "cpu", 0, "default CPU in MHz, instead of the name-based estimate")
+	flags.IntVar(&generateOptions.Memory, "memory", 0, "default memory in MB, instead of the name-based estimate")
+	flags.StringVar(&generateOptions.HealthCheck, "health-check", "", `HTTP path checked for services without a compose healthcheck ("none" disables checks)`)
+	flags.StringSliceVar(&generateOptions.Datacenters, "datacenters", nil, "datacenters jobs may run in (default dc1)")
+	flags.StringVar(&generateOptions.Namespace, "namespace", "", "Nomad namespace of the jobs")
+	flags.StringVar(&generateOptions.Region, "region", "", "Nomad region of the jobs")
+	flags.StringVar(&generateOptions.NodePool, "node-pool", "", "node pool the jobs are placed in")
+	flags.IntVar(&generateOptions.Priority, "priority", 0, "job priority (1-100)")
+	flags.BoolVar(&generateOptions.WithConsul, "with-consul", false, "give tasks a Consul token through a consul block")
+	flags.BoolVar(&generateOptions.WithVault, "with-vault", false, "give tasks a Vault token through a vault block")
+	flags.StringArrayVar(&overrideFlags, "set", nil, "per-service setting, repeatable: <service>.<cpu|memory|count|port|priority|health_check|namespace|region|node_pool|datacenters>=<value> (e.g. --set api.cpu=800)")
+	flags.StringVar(&generateOptions.Registry, "registry", "", "registry prefix for images nompose builds (e.g. registry.example.com/team)")
+	flags.StringVar(&generateOptions.ImageTag, "image-tag", "", "tag for images nompose builds (default: short git commit of the source, else latest)")
+	flags.StringVar(&generateOptions.VolumeType, "volume-type", "", "mount named volumes as host, csi or docker volumes (default: list them in a comment)")
+	flags.StringVar(&outputDir, "output-dir", ".", "directory generated files are written to")
+	flags.StringVarP(&generateOptions.OutputFile, "output", "o", "", `"-" streams every generated file to stdout, each after a "# file: <name>" line and separated by "---" lines`)
+	flags.BoolVar(&generateOptions.DryRun, "dry-run", false, "show which files would be written without writing them")
+	flags.BoolVar(&generateOptions.Force, "force", false, "overwrite generated files even if they were edited by hand")
+	flags.StringSliceVar(&generateOptions.LabelTagPrefixes, "label-tag-prefix", nil, "turn compose labels starting with this prefix into service tags, repeatable (e.g. --label-tag-prefix com.example.)")
 }
 
 func runGenerate(cmd *cobra.Command, args []string) error {
 	source := args[0]
 
-	fmt.Printf("🔍 Analyzing source: %s\n", source)
+	cfg, err := config.Load(config.ProjectDir(source))
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	if err := applyConfig(cmd, cfg); err != nil {
+		return err
+	}
 
-	// Detect source type
-	detector := detector.NewDetector()
-	result := detector.DetectSourceType(source)
+	// Without Consul, other services can only be found through Nomad's own catalog
+	if generateOptions.ServiceProvider == generator.ServiceProviderNomad && cfg.IsDefault("address_rewrite") {
+		generateOptions.AddressRewrite = generator.AddressRewriteNomadTemplate
+	}
+
+	generateOptions.WithIngress = generateOptions.IngressMode != ""
+
+	overrides, err := generator.ParseOverrides(overrideFlags)
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	generateOptions.Overrides = overrides
+
+	if err := validateGenerateOptions(generateOptions); err != nil {
+		return err
+	}
+
+	lockPath := filepath.Join(config.ProjectDir(source), image.LockFileName)
+	if imageLock, err = image.ReadLockfile(lockPath); err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	if locked && imageLock == nil {
+		return fmt.Errorf("❌ --locked needs %s; run nompose lock first", lockPath)
+	}
+	imageRewriter, err = image.NewRewriter(cfg.ImageRewrites(), imageLock)
+	if err != nil {
+		return fmt.Errorf("❌ invalid image_rewrites configuration: %w", err)
+	}
+
+	// Keep stdout for the jobs; progress and prompts move to stderr
+	if generateOptions.OutputFile == "-" {
+		jobOutput = os.Stdout
+		progress = os.Stderr
+	}
+
+	return analyzeSource(source)
+}
+
+// analyzeSource detects the type of a source, unless --type names it, and
+// passes the services parsed from it to handleServices
+func analyzeSource(source string) error {
+	fmt.Fprintf(progress, "🔍 Analyzing source: %s\n", source)
+
+	// Detect source type, unless --type names it
+	sourceDetector := detector.NewDetector()
+	result := sourceDetector.DetectSourceType(source)
+	if generateOptions.ForceType != "" {
+		result = sourceDetector.DetectAs(source, types.SourceType(generateOptions.ForceType))
+	}
+
+	// --ref turns a local clone into a git source, so the ref is checked out
+	if gitRef != "" && result.SourceType == types.SourceLocalDir && git.IsWorkTree(source) {
+		result.SourceType = types.SourceGitRepo
+		result.Reason = "git working tree with --ref"
+	}
 
 	if !result.Valid {
 		return fmt.Errorf("❌ %s", result.Error)
 	}
+	if (gitRef != "" || gitSubdir != "") && result.SourceType != types.SourceGitRepo {
+		return fmt.Errorf("❌ --ref and --subdir only apply to git sources, not %s", result.SourceType)
+	}
+
+	fmt.Fprintf(progress, "✅ Detected source type: %s (%s confidence: %s)\n", result.SourceType, result.Confidence, result.Reason)
+	if result.Confidence != types.ConfidenceHigh {
+		fmt.Fprintf(progress, "💡 Not a %s? Pass --type with one of: %s\n", result.SourceType, detector.TypeList())
+	}
 
-	fmt.Printf("✅ Detected source type: %s\n", result.SourceType)
+	// Git sources are tagged with the commit they are cloned at
+	if generateOptions.ImageTag == "" && result.SourceType != types.SourceGitRepo {
+		generateOptions.ImageTag = defaultImageTag(config.ProjectDir(source))
+	}
 
 	// Parse based on source type
 	switch result.SourceType {
-	case "docker-compose":
+	case "docker-compose", "swarm-stack":
 		return handleDockerCompose(source)
 	case "dockerfile":
 		return handleDockerfile(source)
+	case "procfile":
+		return handleProcfile(source)
+	case "kubernetes":
+		return handleKubernetes(source)
+	case "local-directory":
+		return handleLocalDirectory(source)
+	case "git-repo":
+		return handleGitRepo(source)
 	case "docker-image":
 		return handleDockerImage(source)
+	case "nomad-job":
+		return fmt.Errorf("❌ %s is already a Nomad job; use 'nompose reverse %s' to convert it to docker-compose", source, source)
+	default:
+		return fmt.Errorf("❌ %s sources can't be generated from yet", result.SourceType)
+	}
+}
+
+// configFlags maps configuration keys to the generate flags overriding them
+var configFlags = map[string]string{
+	"datacenters":      "datacenters",
+	"namespace":        "namespace",
+	"region":           "region",
+	"node_pool":        "node-pool",
+	"priority":         "priority",
+	"registry":         "registry",
+	"service_provider": "service-provider",
+	"volume_type":      "volume-type",
+	"resources.cpu":    "cpu",
+	"resources.memory": "memory",
+	"mesh":             "mesh",
+	"address_rewrite":  "address-rewrite",
+	"ingress":          "ingress",
+	"log_driver":       "log-driver",
+	"output_dir":       "output-dir",
+}
+
+// applyConfig fills flags the user didn't pass from the configuration files
+// and environment, and records the flags they did pass as the winning layer
+func applyConfig(cmd *cobra.Command, cfg *config.Config) error {
+	flags := cmd.Flags()
+	for _, setting := range config.Settings {
+		name := configFlags[setting.Key]
+		flag := flags.Lookup(name)
+		if flag == nil {
+			continue
+		}
+
+		if flags.Changed(name) {
+			value := flag.Value.String()
+			if setting.Kind == "list" {
+				list, _ := flags.GetStringSlice(name)
+				value = strings.Join(list, ",")
+			}
+			if err := cfg.Set(setting.Key, value, config.SourceFlag+" --"+name); err != nil {
+				return fmt.Errorf("❌ %w", err)
+			}
+			continue
+		}
+
+		if cfg.IsDefault(setting.Key) {
+			continue
+		}
+		if err := flag.Value.Set(cfg.Get(setting.Key)); err != nil {
+			return fmt.Errorf("❌ invalid %s from %s: %w", setting.Key, cfg.Source(setting.Key), err)
+		}
+	}
+	return nil
+}
+
+// validateGenerateOptions rejects unsupported flag values before any prompts are shown
+func validateGenerateOptions(options types.GenerateOptions) error {
+	switch options.Mesh {
+	case "", generator.MeshConsulConnect:
+	default:
+		return fmt.Errorf("❌ unsupported --mesh %q (supported: %s)", options.Mesh, generator.MeshConsulConnect)
+	}
+
+	switch options.UpstreamAddr {
+	case generator.UpstreamAddressLocalhost, generator.UpstreamAddressEnv:
 	default:
-		fmt.Printf("🚧 %s parsing coming in next sub-steps...\n", result.SourceType)
+		return fmt.Errorf("❌ unsupported --upstream-address %q (supported: %s, %s)", options.UpstreamAddr, generator.UpstreamAddressLocalhost, generator.UpstreamAddressEnv)
+	}
+
+	switch options.AddressRewrite {
+	case generator.AddressRewriteNone, generator.AddressRewriteConsulDNS, generator.AddressRewriteConsulTemplate, generator.AddressRewriteNomadTemplate:
+	default:
+		return fmt.Errorf("❌ unsupported --address-rewrite %q (supported: %s, %s, %s, %s)", options.AddressRewrite,
+			generator.AddressRewriteNone, generator.AddressRewriteConsulDNS, generator.AddressRewriteConsulTemplate, generator.AddressRewriteNomadTemplate)
+	}
+
+	switch options.ServiceProvider {
+	case generator.ServiceProviderConsul, generator.ServiceProviderNomad:
+	default:
+		return fmt.Errorf("❌ unsupported --service-provider %q (supported: %s, %s)", options.ServiceProvider, generator.ServiceProviderConsul, generator.ServiceProviderNomad)
+	}
+
+	if options.ServiceProvider == generator.ServiceProviderNomad {
+		if options.Mesh != "" {
+			return fmt.Errorf("❌ --mesh %s needs Consul; it can't be used with --service-provider nomad", options.Mesh)
+		}
+		if options.AddressRewrite == generator.AddressRewriteConsulDNS || options.AddressRewrite == generator.AddressRewriteConsulTemplate {
+			return fmt.Errorf("❌ --address-rewrite %s needs Consul; use %s with --service-provider nomad", options.AddressRewrite, generator.AddressRewriteNomadTemplate)
+		}
+	}
+
+	switch options.IngressMode {
+	case "", generator.IngressTraefik, generator.IngressFabio:
+	case generator.IngressConsulGateway:
+		if options.Mesh != generator.MeshConsulConnect {
+			return fmt.Errorf("❌ --ingress %s routes to Connect services; add --mesh %s", options.IngressMode, generator.MeshConsulConnect)
+		}
+	default:
+		return fmt.Errorf("❌ unsupported --ingress %q (supported: %s, %s, %s)", options.IngressMode, generator.IngressTraefik, generator.IngressFabio, generator.IngressConsulGateway)
+	}
+
+	if options.IngressMode == generator.IngressFabio && options.ServiceProvider == generator.ServiceProviderNomad {
+		return fmt.Errorf("❌ fabio reads routes from Consul; it can't be used with --service-provider nomad")
+	}
+
+	switch options.OutputFile {
+	case "", "-":
+	default:
+		return fmt.Errorf("❌ unsupported --output %q: use \"-\" for stdout, or --output-dir to choose where files are written", options.OutputFile)
+	}
+
+	switch options.VolumeType {
+	case "", generator.VolumeTypeHost, generator.VolumeTypeCSI, generator.VolumeTypeDocker:
+	default:
+		return fmt.Errorf("❌ unsupported --volume-type %q (supported: %s, %s, %s)", options.VolumeType, generator.VolumeTypeHost, generator.VolumeTypeCSI, generator.VolumeTypeDocker)
+	}
+
+	if options.ImageTag != "" && !image.IsReference("app:"+options.ImageTag) {
+		return fmt.Errorf("❌ invalid --image-tag %q: tags are up to 128 letters, digits, '_', '.' and '-', not starting with '.' or '-'", options.ImageTag)
+	}
+
+	if options.Priority != 0 && (options.Priority < 1 || options.Priority > 100) {
+		return fmt.Errorf("❌ --priority must be between 1 and 100, got %d", options.Priority)
+	}
+	for name, override := range options.Overrides {
+		if override.Priority > 100 {
+			return fmt.Errorf("❌ --set %s.priority must be between 1 and 100, got %d", name, override.Priority)
+		}
 	}
 
+	if options.OutputFile == "-" && options.DryRun {
+		return fmt.Errorf("❌ --dry-run can't be combined with --output -, which writes no files")
+	}
+
+	if options.Mesh != "" && options.AddressRewrite != generator.AddressRewriteNone {
+		return fmt.Errorf("❌ --address-rewrite can't be combined with --mesh, which already routes services through upstreams")
+	}
+
+	return nil
+}
+
+// checkOverrides rejects options that don't match the services being generated
+func checkOverrides(services []types.EnhancedServiceConfig) error {
+	names := make(map[string]bool)
+	for _, service := range services {
+		names[service.Name] = true
+	}
+	for name := range generateOptions.Overrides {
+		if !names[name] {
+			return fmt.Errorf("❌ --set refers to unknown service %q", name)
+		}
+	}
+
+	if len(services) > 1 {
+		if generateOptions.ServiceName != "" {
+			return fmt.Errorf("❌ --service-name only applies to a single job; %d services found", len(services))
+		}
+		if generateOptions.Port != 0 {
+			return fmt.Errorf("❌ --port only applies to a single job; use --set <service>.port=<port> instead")
+		}
+	}
 	return nil
 }
 
 func handleDockerCompose(filePath string) error {
-	fmt.Printf("📋 Parsing docker-compose file...\n")
+	fmt.Fprintf(progress, "📋 Parsing docker-compose file...\n")
 
 	// Parse with enhanced data preservation
 	parser := parser.NewDockerComposeParser()
@@ -73,28 +393,73 @@ func handleDockerCompose(filePath string) error {
 		return fmt.Errorf("failed to parse docker-compose: %w", err)
 	}
 
-	fmt.Printf("✅ Found %d services:\n", len(services))
+	if err := checkOverrides(services); err != nil {
+		return err
+	}
 
-	// Show enhanced detection summary
-	for i, service := range services {
-		fmt.Printf("   %d. %s (%s)\n", i+1, service.Name, service.ResolvedImage)
-		if len(service.ResolvedPorts) > 0 {
-			fmt.Printf("      Ports: %d detected\n", len(service.ResolvedPorts))
-		}
-		if len(service.Environment) > 0 {
-			fmt.Printf("      Environment: %d variables\n", len(service.Environment))
-		}
+	fmt.Fprintf(progress, "✅ Found %d services:\n", len(services))
+	printServices(services)
+
+	// Enhanced interactive confirmation, then generate enhanced Nomad job files
+	return handleServices(services)
+}
+
+func handleDockerfile(filePath string) error {
+	if info, err := os.Stat(filePath); err == nil && info.IsDir() {
+		return handleLocalDirectory(filePath)
 	}
 
-	// Enhanced interactive confirmation
-	confirmer := interactive.NewConfirmer()
+	fmt.Fprintf(progress, "📋 Parsing Dockerfile...\n")
+
+	parser := parser.NewDockerfileParser()
+	services, err := parser.Parse(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to parse Dockerfile: %w", err)
+	}
+
+	if err := checkOverrides(services); err != nil {
+		return err
+	}
+
+	service := &services[0]
+	if generateOptions.Image != "" {
+		service.ResolvedImage = generateOptions.Image
+	}
+
+	fmt.Fprintf(progress, "✅ Found service: %s\n", service.Name)
+	if len(service.ResolvedPorts) > 0 {
+		fmt.Fprintf(progress, "   Exposed ports: %s\n", strings.Join(service.OriginalService.Expose, ", "))
+	}
+	if len(service.Environment) > 0 {
+		fmt.Fprintf(progress, "   Environment: %d variables\n", len(service.Environment))
+	}
+	if service.OriginalService.HealthCheck != nil && !service.OriginalService.HealthCheck.Disable {
+		fmt.Fprintf(progress, "   Healthcheck: defined\n")
+	}
+	if len(service.OriginalService.Volumes) > 0 {
+		fmt.Fprintf(progress, "   Volumes: %s\n", strings.Join(service.OriginalService.Volumes, ", "))
+	}
+
+	return handleServices(services)
+}
+
+// confirmAndGenerate confirms services interactively and writes their jobs
+func confirmAndGenerate(services []types.EnhancedServiceConfig) error {
+	confirmer := newConfirmer()
+	confirmer.RewriteImages(imageRewriter)
 	confirmedServices, err := confirmer.ConfirmServices(services)
 	if err != nil {
 		return fmt.Errorf("failed to confirm services: %w", err)
 	}
+	if err := pinImages(confirmedServices); err != nil {
+		return err
+	}
 
-	// Generate enhanced Nomad job files
-	generator := generator.NewNomadGenerator(".")
+	generator := generator.NewNomadGenerator(outputDir, generateOptions)
+	generator.ReportTo(progress)
+	if jobOutput != nil {
+		generator.StreamTo(jobOutput)
+	}
 	if err := generator.GenerateJobs(confirmedServices); err != nil {
 		return fmt.Errorf("failed to generate Nomad jobs: %w", err)
 	}
@@ -102,12 +467,149 @@ func handleDockerCompose(filePath string) error {
 	return nil
 }
 
-func handleDockerfile(filePath string) error {
-	fmt.Printf("🚧 Dockerfile parsing coming in next sub-step...\n")
-	return nil
+// newConfirmer creates a confirmer prompting on the progress output
+func newConfirmer() *interactive.Confirmer {
+	confirmer := interactive.NewConfirmer(generateOptions)
+	confirmer.ReportTo(progress)
+	return confirmer
+}
+
+// defaultImageTag tags built images with the short commit of the git
+// working tree containing dir, or "latest" outside a repository
+func defaultImageTag(dir string) string {
+	commit, err := git.HeadCommit(context.Background(), dir)
+	if err != nil || len(commit) < 12 {
+		return "latest"
+	}
+	return commit[:12]
 }
 
-func handleDockerImage(image string) error {
-	fmt.Printf("🚧 Docker image analysis coming in next sub-step...\n")
+// pinImages replaces images with the digests recorded in nompose.lock.
+// Images nompose builds are left alone: build.sh produces them.
+func pinImages(services []types.EnhancedServiceConfig) error {
+	if imageLock == nil {
+		return nil
+	}
+
+	var missing []string
+	pinned := 0
+	for i := range services {
+		service := &services[i]
+		if service.Build != nil || strings.HasPrefix(service.ResolvedImage, "{{") {
+			continue
+		}
+		reference, ok := imageLock.Pin(service.ResolvedImage)
+		if !ok {
+			missing = append(missing, service.ResolvedImage)
+			continue
+		}
+		if reference != service.ResolvedImage {
+			service.ResolvedImage = reference
+			pinned++
+		}
+	}
+
+	if pinned > 0 {
+		fmt.Fprintf(progress, "🔒 Pinned %d images to their digests in %s\n", pinned, image.LockFileName)
+	}
+	if len(missing) > 0 {
+		if locked {
+			return fmt.Errorf("❌ images missing from %s: %s (run nompose lock)", image.LockFileName, strings.Join(missing, ", "))
+		}
+		fmt.Fprintf(progress, "⚠️  Not in %s, left unpinned: %s\n", image.LockFileName, strings.Join(missing, ", "))
+	}
 	return nil
 }
+
+// registryTimeout bounds registry lookups for image sources
+const registryTimeout = 30 * time.Second
+
+func handleDockerImage(source string) error {
+	fmt.Fprintf(progress, "📋 Inspecting image...\n")
+
+	img, err := inspectImage(source)
+	if err != nil {
+		return err
+	}
+
+	imageRef := img.Reference
+	if generateOptions.Image != "" {
+		imageRef = generateOptions.Image
+	}
+	if imageRef == "" {
+		return fmt.Errorf("❌ %s has no image name; pass --image with the name it's pushed as (docker load and docker push it first)", source)
+	}
+
+	service := img.ToService(image.NameFromReference(imageRef), imageRef)
+	services := []types.EnhancedServiceConfig{service}
+	if err := checkOverrides(services); err != nil {
+		return err
+	}
+
+	imageConfig := img.Config
+	fmt.Fprintf(progress, "✅ Found image: %s\n", imageRef)
+	if len(service.ResolvedPorts) > 0 {
+		fmt.Fprintf(progress, "   Exposed ports: %s\n", strings.Join(service.OriginalService.Expose, ", "))
+	}
+	if len(imageConfig.Entrypoint) > 0 {
+		fmt.Fprintf(progress, "   Entrypoint: %s\n", strings.Join(imageConfig.Entrypoint, " "))
+	}
+	if len(imageConfig.Cmd) > 0 {
+		fmt.Fprintf(progress, "   Command: %s\n", strings.Join(imageConfig.Cmd, " "))
+	}
+	if imageConfig.WorkingDir != "" {
+		fmt.Fprintf(progress, "   Working directory: %s\n", imageConfig.WorkingDir)
+	}
+	if imageConfig.User != "" {
+		fmt.Fprintf(progress, "   User: %s\n", imageConfig.User)
+	}
+	if len(imageConfig.Env) > 0 {
+		fmt.Fprintf(progress, "   Environment: %d variables (kept in the image)\n", len(imageConfig.Env))
+	}
+	if len(imageConfig.Labels) > 0 {
+		fmt.Fprintf(progress, "   Labels: %d\n", len(imageConfig.Labels))
+	}
+	if service.OriginalService.HealthCheck != nil && !service.OriginalService.HealthCheck.Disable {
+		fmt.Fprintf(progress, "   Healthcheck: defined\n")
+	}
+	if len(service.OriginalService.Volumes) > 0 {
+		fmt.Fprintf(progress, "   Volumes: %s\n", strings.Join(service.OriginalService.Volumes, ", "))
+	}
+
+	return handleServices(services)
+}
+
+// inspectImage reads the image config from an archive, an OCI layout or the
+// registry; registry failures fall back to an image without metadata
+func inspectImage(source string) (*image.Image, error) {
+	switch {
+	case image.IsTarball(source):
+		img, err := image.FromTarball(source, generateOptions.Image)
+		if err != nil {
+			return nil, fmt.Errorf("❌ %w", err)
+		}
+		return img, nil
+	case image.IsLayout(source):
+		img, err := image.FromLayout(source, generateOptions.Image)
+		if err != nil {
+			return nil, fmt.Errorf("❌ %w", err)
+		}
+		return img, nil
+	}
+
+	fallback := &image.Image{Reference: source, Source: source}
+	if offline {
+		fmt.Fprintf(progress, "⚠️  Offline: ports and healthcheck of %s aren't known, set them with --port and --health-check\n", source)
+		return fallback, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), registryTimeout)
+	defer cancel()
+	img, err := image.NewRegistryClient(nil).FromRegistry(ctx, source)
+	if err != nil {
+		fmt.Fprintf(progress, "⚠️  Could not inspect %s: %v\n", source, err)
+		fmt.Fprintf(progress, "   Continuing without image metadata; set ports with --port\n")
+		return fallback, nil
+	}
+	return img, nil
+}
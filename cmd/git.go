@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/detector"
+	"github.com/Jassem-HCP/nompose/internal/git"
+	"github.com/Jassem-HCP/nompose/internal/scanner"
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+// gitRef and gitSubdir pick what to analyze in a git source
+var (
+	gitRef    string
+	gitSubdir string
+)
+
+func handleGitRepo(source string) error {
+	repo := git.ParseSource(source)
+	if gitRef != "" {
+		repo.Ref = gitRef
+	}
+	if gitSubdir != "" {
+		repo.Subdir = strings.Trim(gitSubdir, "/")
+	}
+	if !git.IsURL(repo.URL) {
+		if absolute, err := filepath.Abs(repo.URL); err == nil {
+			repo.URL = absolute
+		}
+	}
+
+	// Docker can't fetch local repositories, so their checkout is kept for builds
+	keep := git.IsLocal(repo.URL)
+	cloneParent := ""
+	if keep {
+		dir, err := checkoutsDir()
+		if err != nil {
+			return fmt.Errorf("failed to create checkout directory: %w", err)
+		}
+		cloneParent = dir
+	}
+
+	cloneDir, err := os.MkdirTemp(cloneParent, "nompose-git-")
+	if err != nil {
+		return fmt.Errorf("failed to create clone directory: %w", err)
+	}
+	defer os.RemoveAll(cloneDir)
+
+	fmt.Fprintf(progress, "📥 Cloning %s...\n", git.Redact(repo.URL))
+	commit, err := git.Clone(context.Background(), repo, cloneDir)
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	ref := repo.Ref
+	if ref == "" {
+		ref = "default branch"
+	}
+	fmt.Fprintf(progress, "✅ Checked out %s at %s\n", ref, commit[:12])
+	if generateOptions.ImageTag == "" {
+		generateOptions.ImageTag = commit[:12]
+	}
+
+	if keep {
+		if cloneDir, err = keepCheckout(cloneDir, commit); err != nil {
+			return fmt.Errorf("❌ %w", err)
+		}
+		fmt.Fprintf(progress, "📁 Build contexts use the checkout kept at %s\n", cloneDir)
+	}
+
+	root := filepath.Join(cloneDir, filepath.FromSlash(repo.Subdir))
+	if relative, err := filepath.Rel(cloneDir, root); err != nil || strings.HasPrefix(relative, "..") {
+		return fmt.Errorf("❌ subdirectory %q is outside the repository", repo.Subdir)
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return fmt.Errorf("❌ %q not found in the repository at %s", repo.Subdir, commit[:12])
+	}
+
+	var services []types.EnhancedServiceConfig
+	if info.IsDir() {
+		services, err = directoryServices(root)
+	} else {
+		services, err = fileServices(root)
+	}
+	if err != nil {
+		return err
+	}
+
+	stampGitSource(services, repo, commit, cloneDir, keep)
+
+	fmt.Fprintf(progress, "✅ Found %d services:\n", len(services))
+	printServices(services)
+
+	return handleServices(services)
+}
+
+// fileServices parses a single file a git subdirectory points at
+func fileServices(path string) ([]types.EnhancedServiceConfig, error) {
+	result := detector.NewDetector().DetectSourceType(path)
+	if !result.Valid {
+		return nil, fmt.Errorf("❌ %s", result.Error)
+	}
+	fmt.Fprintf(progress, "✅ Detected source type: %s (%s confidence: %s)\n", result.SourceType, result.Confidence, result.Reason)
+
+	services, err := parseSource(scanner.Source{Type: result.SourceType, Path: path})
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOverrides(services); err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+// checkoutsDir returns the directory checkouts of local repositories are kept in
+func checkoutsDir() (string, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		cache = os.TempDir()
+	}
+	dir := filepath.Join(cache, "nompose", "checkouts")
+	return dir, os.MkdirAll(dir, 0755)
+}
+
+// keepCheckout moves a clone to a directory named after its commit, which
+// outlives nompose; a checkout kept by an earlier run is reused
+func keepCheckout(cloneDir, commit string) (string, error) {
+	checkout := filepath.Join(filepath.Dir(cloneDir), commit[:12])
+	if _, err := os.Stat(checkout); err == nil {
+		return checkout, nil
+	}
+	if err := os.Rename(cloneDir, checkout); err != nil {
+		return "", fmt.Errorf("failed to keep the checkout: %w", err)
+	}
+	return checkout, nil
+}
+
+// stampGitSource records the repository and commit on every service, makes
+// source files relative to the repository and points build contexts at the
+// commit, so the jobs trace back to exactly what was analyzed; kept checkouts
+// are used as build contexts as they are
+func stampGitSource(services []types.EnhancedServiceConfig, repo git.Source, commit, cloneDir string, kept bool) {
+	remote := git.Redact(repo.URL)
+	for i := range services {
+		service := &services[i]
+		service.SourceRepo = remote
+		service.SourceCommit = commit
+		if relative, err := filepath.Rel(cloneDir, service.SourceFile); err == nil {
+			service.SourceFile = filepath.ToSlash(relative)
+		}
+
+		if kept {
+			continue
+		}
+
+		if service.Build != nil {
+			if buildContext, ok := commitContext(remote, commit, cloneDir, service.Build.Context); ok {
+				build := *service.Build
+				build.Context = buildContext
+				service.Build = &build
+			}
+		}
+
+		context, ok := strings.CutPrefix(service.ResolvedImage, "{{BUILD_REQUIRED:")
+		if !ok {
+			continue
+		}
+		if buildContext, ok := commitContext(remote, commit, cloneDir, strings.TrimSuffix(context, "}}")); ok {
+			service.ResolvedImage = fmt.Sprintf("{{BUILD_REQUIRED:%s}}", buildContext)
+		}
+	}
+}
+
+// commitContext turns a directory of the clone into the <repo>#<commit>:<dir>
+// build context docker build accepts; directories outside the clone are kept
+func commitContext(remote, commit, cloneDir, dir string) (string, bool) {
+	relative, err := filepath.Rel(cloneDir, dir)
+	if err != nil || strings.HasPrefix(relative, "..") {
+		return "", false
+	}
+	buildContext := remote + "#" + commit
+	if relative != "." {
+		buildContext += ":" + filepath.ToSlash(relative)
+	}
+	return buildContext, true
+}
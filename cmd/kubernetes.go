@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Jassem-HCP/nompose/internal/parser"
+)
+
+func handleKubernetes(filePath string) error {
+	fmt.Fprintf(progress, "📋 Parsing Kubernetes manifest...\n")
+
+	k8sParser := parser.NewKubernetesParser()
+	services, err := k8sParser.Parse(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to parse Kubernetes manifest: %w", err)
+	}
+	for _, warning := range k8sParser.Warnings() {
+		fmt.Fprintf(progress, "   ⚠️  %s\n", warning)
+	}
+
+	if err := checkOverrides(services); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(progress, "✅ Found %d workloads:\n", len(services))
+	printServices(services)
+
+	return handleServices(services)
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/config"
+	"github.com/Jassem-HCP/nompose/internal/detector"
+	"github.com/Jassem-HCP/nompose/internal/image"
+	"github.com/Jassem-HCP/nompose/internal/types"
+	"github.com/spf13/cobra"
+)
+
+// lockLayouts are OCI layouts searched for digests before registries
+var lockLayouts []string
+
+var lockCmd = &cobra.Command{
+	Use:   "lock [source]",
+	Short: "Pin the images of a source to digests in nompose.lock",
+	Long: `Resolve every image a source runs to the digest it points at today and
+write them to nompose.lock next to the source. nompose generate then deploys
+images by digest, and fails with --locked when an image isn't locked.
+
+Image rewrite rules apply first, so the mirrored images are locked. Images
+nompose builds are skipped: build.sh tags them with the source commit.`,
+	Example: `  nompose lock docker-compose.yml
+  nompose lock ./my-project --layout ./images/oci
+  nompose generate docker-compose.yml --locked`,
+	Args: cobra.ExactArgs(1),
+	RunE: runLock,
+}
+
+func init() {
+	rootCmd.AddCommand(lockCmd)
+
+	flags := lockCmd.Flags()
+	flags.StringSliceVar(&lockLayouts, "layout", nil, "OCI layout directory to resolve digests from before asking registries, repeatable")
+	flags.BoolVar(&offline, "offline", false, "only resolve digests from --layout directories")
+	flags.StringVar(&generateOptions.ForceType, "type", "", "source type, when detection guesses wrong ("+detector.TypeList()+")")
+	flags.StringVar(&gitRef, "ref", "", "branch, tag or commit of a git source (default: the default branch)")
+	flags.StringVar(&gitSubdir, "subdir", "", "directory or file inside a git source to analyze")
+}
+
+func runLock(cmd *cobra.Command, args []string) error {
+	source := args[0]
+
+	projectDir := config.ProjectDir(source)
+	cfg, err := config.Load(projectDir)
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	// Without a lock, lock rules leave images as they are
+	imageRewriter, err = image.NewRewriter(cfg.ImageRewrites(), nil)
+	if err != nil {
+		return fmt.Errorf("❌ invalid image_rewrites configuration: %w", err)
+	}
+	for _, dir := range lockLayouts {
+		if !image.IsLayout(dir) {
+			return fmt.Errorf("❌ %s is not an OCI image layout", dir)
+		}
+	}
+
+	lockPath := filepath.Join(projectDir, image.LockFileName)
+	handleServices = func(services []types.EnhancedServiceConfig) error {
+		return lockImages(services, lockPath)
+	}
+	return analyzeSource(source)
+}
+
+// lockImages resolves the digest of every image and writes the lockfile;
+// nothing is written when any image fails to resolve
+func lockImages(services []types.EnhancedServiceConfig, lockPath string) error {
+	fmt.Fprintf(progress, "\n🔒 Resolving image digests...\n")
+
+	client := image.NewRegistryClient(nil)
+
+	lock := image.NewLockfile()
+	seen := make(map[string]bool)
+	var failed []string
+	for _, service := range services {
+		if strings.HasPrefix(service.ResolvedImage, "{{") {
+			fmt.Fprintf(progress, "   ⏭️  %s: built by nompose, skipped\n", service.Name)
+			continue
+		}
+		reference, _, err := imageRewriter.Rewrite(service.ResolvedImage)
+		if err != nil {
+			return fmt.Errorf("❌ %w", err)
+		}
+		if seen[reference] {
+			continue
+		}
+		seen[reference] = true
+
+		ref, err := image.ParseReference(reference)
+		if err != nil {
+			fmt.Fprintf(progress, "   ❌ %s: %v\n", service.Name, err)
+			failed = append(failed, reference)
+			continue
+		}
+		if ref.Digest != "" {
+			fmt.Fprintf(progress, "   ✅ %s: already pinned by digest\n", reference)
+			continue
+		}
+
+		// Each lookup gets its own timeout, so slow registries don't starve later images
+		ctx, cancel := context.WithTimeout(context.Background(), registryTimeout)
+		digest, from, err := resolveDigest(ctx, client, reference)
+		cancel()
+		if err != nil {
+			fmt.Fprintf(progress, "   ❌ %s: %v\n", reference, err)
+			failed = append(failed, reference)
+			continue
+		}
+		if err := lock.Lock(reference, digest); err != nil {
+			return fmt.Errorf("❌ %w", err)
+		}
+		fmt.Fprintf(progress, "   ✅ %s → %s (%s)\n", reference, digest, from)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("❌ failed to resolve %d images, %s not written: %s", len(failed), lockPath, strings.Join(failed, ", "))
+	}
+	if err := lock.Write(lockPath); err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+
+	fmt.Fprintf(progress, "✅ Locked %d images in %s\n", len(lock.Images), lockPath)
+	fmt.Fprintf(progress, "💡 nompose generate now pins these digests; add --locked to fail on unlocked images\n")
+	return nil
+}
+
+// resolveDigest finds an image's digest in the --layout directories, then
+// in its registry; it also returns where the digest was found
+func resolveDigest(ctx context.Context, client *image.RegistryClient, reference string) (string, string, error) {
+	for _, dir := range lockLayouts {
+		digest, found, err := image.LayoutDigest(dir, reference)
+		if err != nil {
+			return "", "", err
+		}
+		if found {
+			return digest, dir, nil
+		}
+	}
+	if offline {
+		return "", "", fmt.Errorf("not found in any --layout directory")
+	}
+
+	digest, err := client.Digest(ctx, reference)
+	if err != nil {
+		return "", "", err
+	}
+	ref, _ := image.ParseReference(reference)
+	return digest, ref.Registry, nil
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/generator"
+	"github.com/Jassem-HCP/nompose/internal/parser"
+	"github.com/Jassem-HCP/nompose/internal/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reverseOutput string
+	reverseForce  bool
+)
+
+var reverseCmd = &cobra.Command{
+	Use:   "reverse <job files...>",
+	Short: "Convert Nomad jobs back into a docker-compose.yml",
+	Long: `Read Nomad jobs in HCL or JSON and write a docker-compose.yml that runs
+their docker tasks locally.
+
+Ports, env, static templates, volumes, service checks and resources are
+carried over. Init tasks, poststart tasks, Connect upstreams and Consul
+lookups become depends_on. Nomad-only settings are listed at the top of the
+file.`,
+	Example: `  nompose reverse job.nomad.hcl
+  nompose reverse web.nomad.hcl db.nomad.hcl -o docker-compose.yml
+  nompose reverse job.json -o -`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runReverse,
+}
+
+func init() {
+	rootCmd.AddCommand(reverseCmd)
+
+	flags := reverseCmd.Flags()
+	flags.StringVarP(&reverseOutput, "output", "o", "docker-compose.yml", "file to write, - for stdout")
+	flags.BoolVar(&reverseForce, "force", false, "overwrite an existing output file")
+}
+
+func runReverse(cmd *cobra.Command, args []string) error {
+	toStdout := reverseOutput == "-"
+	if !toStdout && !reverseForce {
+		if _, err := os.Stat(reverseOutput); err == nil {
+			return fmt.Errorf("❌ %s already exists, use --force to overwrite it", reverseOutput)
+		}
+	}
+
+	// Progress goes to stderr when the compose file goes to stdout
+	progress := os.Stdout
+	if toStdout {
+		progress = os.Stderr
+	}
+
+	var jobs []types.NomadJob
+	var notes []string
+	for _, path := range args {
+		fmt.Fprintf(progress, "📋 Parsing Nomad job %s...\n", path)
+		job, err := parser.NewNomadJobParser().Parse(path)
+		if err != nil {
+			return fmt.Errorf("❌ failed to parse %s: %w", path, err)
+		}
+		fmt.Fprintf(progress, "✅ Found job %s with %d groups\n", job.Name, len(job.Groups))
+		jobs = append(jobs, *job)
+		for _, unsupported := range job.Unsupported {
+			notes = append(notes, job.Name+": "+unsupported)
+		}
+	}
+
+	composeGenerator := generator.NewComposeGenerator()
+	compose, err := composeGenerator.Generate(jobs)
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	notes = append(notes, composeGenerator.Notes()...)
+
+	content := reverseHeader(args, notes) + compose
+	if toStdout {
+		fmt.Print(content)
+	} else {
+		if err := os.WriteFile(reverseOutput, []byte(content), 0644); err != nil {
+			return fmt.Errorf("❌ failed to write %s: %w", reverseOutput, err)
+		}
+		fmt.Fprintf(progress, "📄 Generated: %s\n", reverseOutput)
+	}
+
+	if len(notes) > 0 {
+		fmt.Fprintf(progress, "\n⚠️  Not converted:\n")
+		for _, note := range notes {
+			fmt.Fprintf(progress, "   • %s\n", note)
+		}
+	}
+	return nil
+}
+
+// reverseHeader records the source jobs and what compose can't express
+func reverseHeader(sources []string, notes []string) string {
+	var header strings.Builder
+	header.WriteString("# Generated by nompose reverse from " + strings.Join(sources, ", ") + "\n")
+	if len(notes) > 0 {
+		header.WriteString("#\n# Not converted:\n")
+		for _, note := range notes {
+			header.WriteString("#   - " + note + "\n")
+		}
+	}
+	header.WriteString("\n")
+	return header.String()
+}
@@ -17,7 +17,8 @@ Examples:
   nompose generate docker-compose.yml
   nompose generate Dockerfile  
   nompose generate nginx:latest
-  nompose generate ./my-app`,
+  nompose generate ./my-app
+  nompose reverse job.nomad.hcl`,
 }
 
 func Execute() {
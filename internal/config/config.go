@@ -0,0 +1,298 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/types"
+	"gopkg.in/yaml.v3"
+)
+
+// File names of the configuration layers
+const (
+	UserConfigDir  = ".nompose"
+	UserConfigFile = "config.yml"
+	ProjectFile    = ".nompose.yml"
+	EnvPrefix      = "NOMPOSE_"
+)
+
+// Sources reported for values not read from a file
+const (
+	SourceDefault   = "default"
+	SourceEnvPrefix = "env "
+	SourceFlag      = "flag"
+)
+
+// Setting describes one configuration key
+type Setting struct {
+	Key         string
+	Default     string
+	Kind        string // "string", "int" or "list"
+	Description string
+}
+
+// Settings lists every supported key, in display order
+var Settings = []Setting{
+	{"datacenters", "dc1", "list", "datacenters jobs may run in"},
+	{"namespace", "", "string", "Nomad namespace of the jobs"},
+	{"region", "", "string", "Nomad region of the jobs"},
+	{"node_pool", "", "string", "node pool the jobs are placed in"},
+	{"priority", "", "int", "job priority (1-100)"},
+	{"registry", "", "string", "registry prefix for images nompose builds (e.g. registry.example.com/team)"},
+	{"service_provider", "consul", "string", "service registration provider (consul, nomad)"},
+	{"volume_type", "", "string", "how named volumes are mounted (host, csi, docker); unset lists them in a comment"},
+	{"resources.cpu", "", "int", "default CPU in MHz"},
+	{"resources.memory", "", "int", "default memory in MB"},
+	{"mesh", "", "string", "service mesh mode (consul-connect)"},
+	{"address_rewrite", "none", "string", "rewrite references to other services (none, consul-dns, consul-template, nomad-template)"},
+	{"ingress", "", "string", "ingress mode (traefik, fabio, consul-gateway)"},
+	{"log_driver", "", "string", "logging driver injected into every job"},
+	{"output_dir", ".", "string", "directory generated files are written to"},
+}
+
+// Value is a setting's effective value and the layer it came from
+type Value struct {
+	Value  string
+	Source string
+}
+
+// Config is the merged configuration of every layer
+type Config struct {
+	values   map[string]Value
+	rewrites []Rewrite
+}
+
+// Rewrite is an image rewrite rule and the file it came from
+type Rewrite struct {
+	Rule   types.ImageRewrite
+	Source string
+}
+
+// rewritesKey holds the image rewrite rules; being a list of mappings it is
+// read on its own rather than as a setting
+const rewritesKey = "image_rewrites"
+
+// Load merges the defaults, ~/.nompose/config.yml, the .nompose.yml in
+// projectDir and NOMPOSE_* environment variables, later layers winning
+func Load(projectDir string) (*Config, error) {
+	config := &Config{values: make(map[string]Value)}
+	for _, setting := range Settings {
+		config.values[setting.Key] = Value{Value: setting.Default, Source: SourceDefault}
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		if err := config.loadFile(filepath.Join(home, UserConfigDir, UserConfigFile)); err != nil {
+			return nil, err
+		}
+	}
+
+	if projectDir != "" {
+		if err := config.loadFile(filepath.Join(projectDir, ProjectFile)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := config.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// loadFile merges a YAML config file; a missing file is not an error
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	var file struct {
+		Rewrites []types.ImageRewrite `yaml:"image_rewrites"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("invalid config %s: %s must be a list of rules: %w", path, rewritesKey, err)
+	}
+	// Rules of later, more specific layers are tried first
+	var rewrites []Rewrite
+	for _, rule := range file.Rewrites {
+		rewrites = append(rewrites, Rewrite{Rule: rule, Source: path})
+	}
+	c.rewrites = append(rewrites, c.rewrites...)
+	delete(raw, rewritesKey)
+
+	values := make(map[string]string)
+	flatten("", raw, values)
+
+	for _, key := range sortedKeys(values) {
+		if err := c.Set(key, values[key], path); err != nil {
+			return fmt.Errorf("invalid config %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// loadEnv merges NOMPOSE_<KEY> variables, e.g. NOMPOSE_RESOURCES_CPU
+func (c *Config) loadEnv() error {
+	for _, setting := range Settings {
+		name := EnvName(setting.Key)
+		if value, ok := os.LookupEnv(name); ok {
+			if err := c.Set(setting.Key, value, SourceEnvPrefix+name); err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Set records a value for a key, validating its type
+func (c *Config) Set(key, value, source string) error {
+	setting, ok := lookup(key)
+	if !ok {
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	if setting.Kind == "int" && value != "" {
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%s must be a number, got %q", key, value)
+		}
+	}
+	c.values[key] = Value{Value: value, Source: source}
+	return nil
+}
+
+// Get returns a setting's effective value
+func (c *Config) Get(key string) string {
+	return c.values[key].Value
+}
+
+// Int returns a numeric setting, 0 when unset
+func (c *Config) Int(key string) int {
+	n, _ := strconv.Atoi(c.values[key].Value)
+	return n
+}
+
+// List returns a comma separated setting as a list
+func (c *Config) List(key string) []string {
+	var items []string
+	for _, item := range strings.Split(c.values[key].Value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Rewrites returns the image rewrite rules of every layer, in the order they apply
+func (c *Config) Rewrites() []Rewrite {
+	return c.rewrites
+}
+
+// ImageRewrites returns the image rewrite rules without their sources
+func (c *Config) ImageRewrites() []types.ImageRewrite {
+	rules := make([]types.ImageRewrite, len(c.rewrites))
+	for i, rewrite := range c.rewrites {
+		rules[i] = rewrite.Rule
+	}
+	return rules
+}
+
+// Source returns the layer a setting's value came from
+func (c *Config) Source(key string) string {
+	return c.values[key].Source
+}
+
+// Table lists every setting with its effective value and the layer it came
+// from, one per line, as `nompose config show` prints them
+func (c *Config) Table(indent string) string {
+	width := 0
+	for _, setting := range Settings {
+		if len(setting.Key) > width {
+			width = len(setting.Key)
+		}
+	}
+
+	var table strings.Builder
+	for _, setting := range Settings {
+		value := c.Get(setting.Key)
+		if value == "" {
+			value = "-"
+		}
+		fmt.Fprintf(&table, "%s%-*s  %-30s (%s)\n", indent, width, setting.Key, value, c.Source(setting.Key))
+	}
+	return table.String()
+}
+
+// IsDefault reports whether no layer set the key
+func (c *Config) IsDefault(key string) bool {
+	return c.values[key].Source == SourceDefault
+}
+
+// EnvName returns the environment variable overriding a key
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+// ProjectDir returns the directory whose .nompose.yml applies to a source
+func ProjectDir(source string) string {
+	info, err := os.Stat(source)
+	if err != nil {
+		// Images and remote sources use the working directory
+		return "."
+	}
+	if info.IsDir() {
+		return source
+	}
+	return filepath.Dir(source)
+}
+
+func lookup(key string) (Setting, bool) {
+	for _, setting := range Settings {
+		if setting.Key == key {
+			return setting, true
+		}
+	}
+	return Setting{}, false
+}
+
+// flatten turns nested mappings into dotted keys and lists into comma separated values
+func flatten(prefix string, raw map[string]interface{}, values map[string]string) {
+	for key, value := range raw {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			flatten(key, v, values)
+		case []interface{}:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprintf("%v", item)
+			}
+			values[key] = strings.Join(items, ",")
+		case nil:
+			values[key] = ""
+		default:
+			values[key] = fmt.Sprintf("%v", v)
+		}
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
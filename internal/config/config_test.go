@@ -0,0 +1,193 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// isolate points the user layer at a temporary home and clears NOMPOSE_* variables
+func isolate(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, setting := range Settings {
+		name := EnvName(setting.Key)
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	return home
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadLayerPrecedence(t *testing.T) {
+	home := isolate(t)
+	project := t.TempDir()
+	userFile := filepath.Join(home, UserConfigDir, UserConfigFile)
+	projectFile := filepath.Join(project, ProjectFile)
+
+	writeFile(t, userFile, `namespace: user
+region: eu
+priority: 10
+datacenters: [dc-a, dc-b]
+resources:
+  cpu: 100
+  memory: 256
+`)
+	writeFile(t, projectFile, `namespace: project
+resources:
+  cpu: 200
+  memory: 512
+`)
+	t.Setenv("NOMPOSE_RESOURCES_CPU", "300")
+	t.Setenv("NOMPOSE_RESOURCES_MEMORY", "1024")
+
+	cfg, err := Load(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Flags are the last layer; nompose generate records them with Set
+	if err := cfg.Set("resources.memory", "2048", SourceFlag+" --memory"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key    string
+		value  string
+		source string
+	}{
+		{"service_provider", "consul", SourceDefault},
+		{"region", "eu", userFile},
+		{"priority", "10", userFile},
+		{"datacenters", "dc-a,dc-b", userFile},
+		{"namespace", "project", projectFile},
+		{"resources.cpu", "300", SourceEnvPrefix + "NOMPOSE_RESOURCES_CPU"},
+		{"resources.memory", "2048", SourceFlag + " --memory"},
+	}
+	for _, test := range tests {
+		if got := cfg.Get(test.key); got != test.value {
+			t.Errorf("%s = %q, want %q", test.key, got, test.value)
+		}
+		if got := cfg.Source(test.key); got != test.source {
+			t.Errorf("%s source = %q, want %q", test.key, got, test.source)
+		}
+	}
+
+	if got := cfg.List("datacenters"); len(got) != 2 || got[1] != "dc-b" {
+		t.Errorf("datacenters = %v", got)
+	}
+	if cfg.Int("resources.cpu") != 300 || !cfg.IsDefault("mesh") || cfg.IsDefault("namespace") {
+		t.Errorf("unexpected Int or IsDefault results")
+	}
+}
+
+func TestLoadImageRewritesProjectFirst(t *testing.T) {
+	home := isolate(t)
+	project := t.TempDir()
+	writeFile(t, filepath.Join(home, UserConfigDir, UserConfigFile), `image_rewrites:
+  - match: docker.io/*
+    replace: mirror.corp/*
+`)
+	writeFile(t, filepath.Join(project, ProjectFile), `image_rewrites:
+  - match: postgres
+    pin: "16.4"
+`)
+
+	cfg, err := Load(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rewrites := cfg.Rewrites()
+	if len(rewrites) != 2 || rewrites[0].Rule.Match != "postgres" || rewrites[1].Rule.Match != "docker.io/*" {
+		t.Fatalf("rewrites = %+v, want project rules first", rewrites)
+	}
+	if rewrites[0].Source != filepath.Join(project, ProjectFile) {
+		t.Errorf("rewrite source = %q", rewrites[0].Source)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		project string
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "unknown key", project: "volumes: host\n", wantErr: `unknown setting "volumes"`},
+		{name: "not a number", project: "priority: high\n", wantErr: `priority must be a number, got "high"`},
+		{name: "malformed yaml", project: "namespace: [\n", wantErr: "failed to parse config"},
+		{name: "malformed rewrites", project: "image_rewrites: postgres\n", wantErr: "image_rewrites must be a list of rules"},
+		{name: "env not a number", env: map[string]string{"NOMPOSE_RESOURCES_CPU": "lots"}, wantErr: "invalid NOMPOSE_RESOURCES_CPU"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			isolate(t)
+			project := t.TempDir()
+			if test.project != "" {
+				writeFile(t, filepath.Join(project, ProjectFile), test.project)
+			}
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+			if _, err := Load(project); err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("error = %v, want one containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestTableShowsSources(t *testing.T) {
+	isolate(t)
+	project := t.TempDir()
+	projectFile := filepath.Join(project, ProjectFile)
+	writeFile(t, projectFile, "namespace: team\n")
+	t.Setenv("NOMPOSE_REGION", "eu")
+
+	cfg, err := Load(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Set("mesh", "consul-connect", SourceFlag+" --mesh"); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(cfg.Table("   "), "\n"), "\n")
+	if len(lines) != len(Settings) {
+		t.Fatalf("expected one line per setting, got %d", len(lines))
+	}
+	row := func(key string) string {
+		for _, line := range lines {
+			if fields := strings.Fields(line); len(fields) > 0 && fields[0] == key {
+				return line
+			}
+		}
+		t.Fatalf("no row for %s", key)
+		return ""
+	}
+
+	for key, want := range map[string][]string{
+		"namespace":   {"team", "(" + projectFile + ")"},
+		"region":      {"eu", "(env NOMPOSE_REGION)"},
+		"mesh":        {"consul-connect", "(flag --mesh)"},
+		"datacenters": {"dc1", "(default)"},
+		"node_pool":   {" - ", "(default)"},
+	} {
+		line := row(key)
+		for _, part := range want {
+			if !strings.Contains(line, part) {
+				t.Errorf("row %q is missing %q", line, part)
+			}
+		}
+	}
+}
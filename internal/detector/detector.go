@@ -1,9 +1,13 @@
 package detector
 
 import (
+	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/Jassem-HCP/nompose/internal/git"
+	"github.com/Jassem-HCP/nompose/internal/image"
 	"github.com/Jassem-HCP/nompose/internal/types"
 )
 
@@ -27,59 +31,96 @@ func (d *Detector) DetectSourceType(source string) types.DetectionResult {
 		}
 	}
 
-	// GitHub repository detection
-	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
-		if strings.Contains(source, "github.com") {
-			return types.DetectionResult{
-				SourceType: types.SourceGitHubRepo,
-				Source:     source,
-				Valid:      true,
+	// Git repositories: remote URLs and local bare repositories, with an optional #ref:subdir
+	if git.IsURL(source) {
+		repo := git.ParseSource(source).URL
+		if strings.HasPrefix(repo, "http://") || strings.HasPrefix(repo, "https://") {
+			if !strings.HasSuffix(repo, ".git") && !knownGitHost(repo) {
+				return detected(types.SourceGitRepo, source, types.ConfidenceMedium, "HTTP URL, assumed to be a git repository")
 			}
 		}
-		return types.DetectionResult{
-			Valid: false,
-			Error: "URL provided but not a supported GitHub repository",
-		}
+		return detected(types.SourceGitRepo, source, types.ConfidenceHigh, "git URL")
+	}
+	if git.IsBareRepo(git.ParseSource(source).URL) {
+		return detected(types.SourceGitRepo, source, types.ConfidenceHigh, "bare git repository")
 	}
 
-	// Docker image detection (contains : for tag)
-	if strings.Contains(source, ":") && !strings.Contains(source, "/") && !strings.Contains(source, "\\") {
-		return types.DetectionResult{
-			SourceType: types.SourceDockerImage,
-			Source:     source,
-			Valid:      true,
+	// Saved images: docker save archives and OCI image layouts
+	if image.IsTarball(source) {
+		return detected(types.SourceDockerImage, source, types.ConfidenceHigh, "image archive")
+	}
+	if image.IsLayout(source) {
+		return detected(types.SourceDockerImage, source, types.ConfidenceHigh, "OCI image layout (has an oci-layout file)")
+	}
+
+	// Existing files are classified by their content
+	if info, err := os.Stat(source); err == nil {
+		if info.IsDir() {
+			return detected(types.SourceLocalDir, source, types.ConfidenceHigh, "directory")
 		}
+		return d.sniffFile(source)
 	}
 
-	// File-based detection
-	filename := filepath.Base(source)
-	extension := strings.ToLower(filepath.Ext(source))
+	// Missing files can only be told apart by name; parsing reports them missing
+	if sourceType, reason, ok := detectByName(source); ok {
+		return detected(sourceType, source, types.ConfidenceLow, reason+"; file not found, content not checked")
+	}
 
-	// Docker Compose detection
-	if filename == "docker-compose.yml" || 
-	   filename == "docker-compose.yaml" ||
-	   extension == ".yml" || 
-	   extension == ".yaml" {
-		return types.DetectionResult{
-			SourceType: types.SourceDockerCompose,
-			Source:     source,
-			Valid:      true,
+	// Image references, unless written as a path
+	if !isPathLike(source) && image.IsReference(source) {
+		return detected(types.SourceDockerImage, source, types.ConfidenceMedium, "image reference (no local file with that name)")
+	}
+
+	// Default to local directory
+	return detected(types.SourceLocalDir, source, types.ConfidenceLow, "neither an existing file nor an image reference")
+}
+
+// DetectAs skips detection for a source whose type was given with --type
+func (d *Detector) DetectAs(source string, sourceType types.SourceType) types.DetectionResult {
+	for _, known := range types.SourceTypes {
+		if known == sourceType {
+			return detected(sourceType, strings.TrimSpace(source), types.ConfidenceHigh, "set with --type")
 		}
 	}
+	return types.DetectionResult{
+		Valid: false,
+		Error: fmt.Sprintf("unknown source type %q (supported: %s)", sourceType, TypeList()),
+	}
+}
 
-	// Dockerfile detection
-	if strings.HasPrefix(strings.ToLower(filename), "dockerfile") {
-		return types.DetectionResult{
-			SourceType: types.SourceDockerfile,
-			Source:     source,
-			Valid:      true,
+// knownGitHost reports whether a URL points at a well-known git hosting service
+func knownGitHost(repo string) bool {
+	for _, host := range []string{"github.com", "gitlab.com", "bitbucket.org", "codeberg.org"} {
+		if strings.Contains(repo, "://"+host+"/") {
+			return true
 		}
 	}
+	return false
+}
 
-	// Default to local directory
+// detected builds a valid detection result
+func detected(sourceType types.SourceType, source string, confidence types.Confidence, reason string) types.DetectionResult {
 	return types.DetectionResult{
-		SourceType: types.SourceLocalDir,
+		SourceType: sourceType,
 		Source:     source,
 		Valid:      true,
+		Confidence: confidence,
+		Reason:     reason,
+	}
+}
+
+// TypeList formats the source types accepted by --type
+func TypeList() string {
+	names := make([]string, len(types.SourceTypes))
+	for i, sourceType := range types.SourceTypes {
+		names[i] = string(sourceType)
 	}
+	return strings.Join(names, ", ")
+}
+
+// isPathLike reports whether source is written as a path rather than a name
+func isPathLike(source string) bool {
+	return strings.HasPrefix(source, ".") || strings.HasPrefix(source, "/") ||
+		strings.HasPrefix(source, "~") || strings.Contains(source, "\\") ||
+		filepath.VolumeName(source) != ""
 }
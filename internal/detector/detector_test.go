@@ -0,0 +1,92 @@
+package detector
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+func TestDetectSourceType(t *testing.T) {
+	tests := map[string]types.SourceType{
+		"nginx:alpine":        types.SourceDockerImage,
+		"ghcr.io/org/app:1.2": types.SourceDockerImage,
+		"localhost:5000/app":  types.SourceDockerImage,
+		"app@sha256:4c2c5fe3e6a6e4b1b8b5c0f0f1a3d5c7e9b0a2c4d6e8f0a1b3c5d7e9f1a3b5c7": types.SourceDockerImage,
+		"library/nginx":                         types.SourceDockerImage,
+		"app.tar":                               types.SourceDockerImage,
+		"C:file.yml":                            types.SourceDockerCompose,
+		"docker-compose.yml":                    types.SourceDockerCompose,
+		"Dockerfile.prod":                       types.SourceDockerfile,
+		"./my-project":                          types.SourceLocalDir,
+		"testdata-that-is-Upper":                types.SourceLocalDir,
+		"https://github.com/user/repo":          types.SourceGitRepo,
+		"https://git.example.com/team/app.git":  types.SourceGitRepo,
+		"git@github.com:user/repo.git#main:api": types.SourceGitRepo,
+		"file:///srv/git/app.git":               types.SourceGitRepo,
+	}
+
+	detector := NewDetector()
+	for source, want := range tests {
+		result := detector.DetectSourceType(source)
+		if !result.Valid {
+			t.Errorf("%s: unexpected error %s", source, result.Error)
+			continue
+		}
+		if result.SourceType != want {
+			t.Errorf("%s: detected %s, want %s", source, result.SourceType, want)
+		}
+	}
+}
+
+func TestDetectSourceContent(t *testing.T) {
+	tests := []struct {
+		file       string
+		sourceType types.SourceType
+		confidence types.Confidence
+	}{
+		{"compose.yaml", types.SourceDockerCompose, types.ConfidenceHigh},
+		{"deployment.yml", types.SourceKubernetes, types.ConfidenceHigh},
+		{"stack.yml", types.SourceSwarmStack, types.ConfidenceMedium},
+		{"web.nomad.hcl", types.SourceNomadJob, types.ConfidenceHigh},
+		{"job.json", types.SourceNomadJob, types.ConfidenceHigh},
+		{"Procfile", types.SourceProcfile, types.ConfidenceHigh},
+		{"build-image", types.SourceDockerfile, types.ConfidenceMedium},
+	}
+
+	detector := NewDetector()
+	for _, test := range tests {
+		result := detector.DetectSourceType(filepath.Join("testdata", test.file))
+		if !result.Valid {
+			t.Errorf("%s: unexpected error %s", test.file, result.Error)
+			continue
+		}
+		if result.SourceType != test.sourceType || result.Confidence != test.confidence {
+			t.Errorf("%s: detected %s (%s confidence), want %s (%s confidence)", test.file, result.SourceType, result.Confidence, test.sourceType, test.confidence)
+		}
+		if result.Reason == "" {
+			t.Errorf("%s: no reason given", test.file)
+		}
+	}
+
+	for _, file := range []string{"ci.yml", "settings.yml"} {
+		result := detector.DetectSourceType(filepath.Join("testdata", file))
+		if result.Valid {
+			t.Errorf("%s: detected as %s, want an error", file, result.SourceType)
+		}
+	}
+	if result := detector.DetectSourceType(filepath.Join("testdata", "settings.yml")); !strings.Contains(result.Error, "--type") {
+		t.Errorf("ambiguous file error doesn't suggest --type: %s", result.Error)
+	}
+}
+
+func TestDetectAs(t *testing.T) {
+	detector := NewDetector()
+	if result := detector.DetectAs("stack.yml", types.SourceDockerCompose); !result.Valid || result.SourceType != types.SourceDockerCompose {
+		t.Errorf("--type docker-compose not honoured: %+v", result)
+	}
+	if result := detector.DetectAs("stack.yml", "helm"); result.Valid {
+		t.Error("unknown --type accepted")
+	}
+}
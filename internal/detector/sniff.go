@@ -0,0 +1,290 @@
+package detector
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/types"
+	"gopkg.in/yaml.v3"
+)
+
+// maxSniffSize is how much of a file is read to classify it
+const maxSniffSize = 1 << 20
+
+// composeFileNames are the file names docker compose looks for
+var composeFileNames = map[string]bool{
+	"compose.yaml":        true,
+	"compose.yml":         true,
+	"docker-compose.yaml": true,
+	"docker-compose.yml":  true,
+}
+
+var (
+	hclJobPattern     = regexp.MustCompile(`(?m)^\s*job\s+"[^"]+"\s*\{`)
+	procfileLine      = regexp.MustCompile(`^[A-Za-z0-9_-]+:\s*\S`)
+	dockerInstruction = regexp.MustCompile(`(?i)^(FROM|ARG)\s+\S`)
+)
+
+// detectByName classifies a file by its name alone
+func detectByName(path string) (types.SourceType, string, bool) {
+	name := filepath.Base(path)
+	lower := strings.ToLower(name)
+	extension := strings.ToLower(filepath.Ext(name))
+
+	switch {
+	case composeFileNames[lower]:
+		return types.SourceDockerCompose, "canonical Compose file name", true
+	case strings.HasPrefix(lower, "dockerfile") || strings.HasPrefix(lower, "containerfile") || extension == ".dockerfile":
+		return types.SourceDockerfile, "Dockerfile name", true
+	case name == "Procfile" || strings.HasPrefix(name, "Procfile."):
+		return types.SourceProcfile, "Procfile name", true
+	case extension == ".nomad" || strings.HasSuffix(lower, ".nomad.hcl") || strings.HasSuffix(lower, ".nomad.json"):
+		return types.SourceNomadJob, "Nomad job file name", true
+	case extension == ".yml" || extension == ".yaml":
+		return types.SourceDockerCompose, "YAML file name", true
+	}
+	return "", "", false
+}
+
+// sniffFile classifies an existing file by its content, using its name as a hint
+func (d *Detector) sniffFile(path string) types.DetectionResult {
+	data, err := readHead(path)
+	if err != nil {
+		return types.DetectionResult{Valid: false, Error: fmt.Sprintf("failed to read %s: %v", path, err)}
+	}
+
+	named, nameReason, hasName := detectByName(path)
+	extension := strings.ToLower(filepath.Ext(path))
+	trimmed := bytes.TrimSpace(data)
+
+	// Names that only one format uses are trusted, with the content confirming them
+	switch named {
+	case types.SourceDockerfile:
+		if looksLikeDockerfile(data) {
+			return detected(named, path, types.ConfidenceHigh, nameReason+" and FROM instruction")
+		}
+		return detected(named, path, types.ConfidenceMedium, nameReason+", but no FROM instruction found")
+	case types.SourceProcfile:
+		if looksLikeProcfile(data) {
+			return detected(named, path, types.ConfidenceHigh, nameReason+" and process: command lines")
+		}
+		return detected(named, path, types.ConfidenceMedium, nameReason+", but no process: command lines found")
+	}
+
+	if len(trimmed) == 0 {
+		return ambiguous(path, "the file is empty")
+	}
+
+	if trimmed[0] == '{' {
+		if result, ok := sniffJSON(path, trimmed); ok {
+			return result
+		}
+	}
+	if extension == ".hcl" || extension == ".nomad" || !hasName {
+		if hclJobPattern.Match(data) {
+			return detected(types.SourceNomadJob, path, types.ConfidenceHigh, `HCL job "..." block`)
+		}
+	}
+
+	result, ok, yamlErr := sniffYAML(path, data)
+	if ok {
+		return result
+	}
+
+	// Formats that also parse as YAML are only considered for non-YAML names
+	if extension != ".yml" && extension != ".yaml" {
+		if looksLikeDockerfile(data) {
+			return detected(types.SourceDockerfile, path, types.ConfidenceMedium, "starts with a FROM instruction")
+		}
+		if looksLikeProcfile(data) {
+			return detected(types.SourceProcfile, path, types.ConfidenceMedium, "every line is a process: command pair")
+		}
+	}
+
+	if yamlErr != nil && (extension == ".yml" || extension == ".yaml") {
+		return ambiguous(path, fmt.Sprintf("it isn't valid YAML (%v)", yamlErr))
+	}
+	if named == types.SourceDockerCompose && extension != ".yml" && extension != ".yaml" {
+		return ambiguous(path, "it has a Compose file name but no top-level services")
+	}
+	if hasName {
+		return ambiguous(path, fmt.Sprintf("%s suggests %s, but the content doesn't match", nameReason, named))
+	}
+	return ambiguous(path, "the content matches no supported format")
+}
+
+// sniffYAML recognises Compose, Swarm stack and Kubernetes YAML, and GitHub
+// workflows, which are YAML but not deployable
+func sniffYAML(path string, data []byte) (types.DetectionResult, bool, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	var kinds []string
+	for {
+		var doc map[string]interface{}
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return types.DetectionResult{}, false, err
+		}
+
+		_, hasAPIVersion := doc["apiVersion"]
+		kind, hasKind := doc["kind"].(string)
+		_, hasJobs := doc["jobs"]
+		_, hasOn := doc["on"]
+		services, hasServices := doc["services"].(map[string]interface{})
+
+		switch {
+		case hasAPIVersion && hasKind:
+			kinds = append(kinds, kind)
+		case hasJobs && hasOn:
+			return types.DetectionResult{
+				Valid:  false,
+				Error:  fmt.Sprintf("%s is a GitHub Actions workflow, not a deployable source; point nompose at the compose file, Dockerfile or image it builds", path),
+				Reason: "top-level on: and jobs:",
+			}, true, nil
+		case hasServices:
+			if markers := swarmMarkers(doc, services); len(markers) > 0 {
+				return detected(types.SourceSwarmStack, path, types.ConfidenceMedium,
+					"top-level services with Swarm-only settings ("+strings.Join(markers, ", ")+")"), true, nil
+			}
+			return detected(types.SourceDockerCompose, path, types.ConfidenceHigh, "top-level services"), true, nil
+		}
+	}
+
+	if len(kinds) > 0 {
+		return detected(types.SourceKubernetes, path, types.ConfidenceHigh, "apiVersion/kind: "+strings.Join(uniqueSorted(kinds), ", ")), true, nil
+	}
+	return types.DetectionResult{}, false, nil
+}
+
+// swarmMarkers lists settings only docker stack deploy honours
+func swarmMarkers(doc map[string]interface{}, services map[string]interface{}) []string {
+	found := make(map[string]bool)
+
+	if networks, ok := doc["networks"].(map[string]interface{}); ok {
+		for _, network := range networks {
+			if settings, ok := network.(map[string]interface{}); ok && settings["driver"] == "overlay" {
+				found["overlay network"] = true
+			}
+		}
+	}
+
+	for _, service := range services {
+		settings, ok := service.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		deploy, ok := settings["deploy"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if deploy["mode"] == "global" {
+			found["deploy.mode: global"] = true
+		}
+		for _, key := range []string{"placement", "update_config", "rollback_config", "endpoint_mode"} {
+			if _, ok := deploy[key]; ok {
+				found["deploy."+key] = true
+			}
+		}
+	}
+
+	markers := make([]string, 0, len(found))
+	for marker := range found {
+		markers = append(markers, marker)
+	}
+	sort.Strings(markers)
+	return markers
+}
+
+// sniffJSON recognises Nomad jobs in API JSON and Kubernetes JSON manifests
+func sniffJSON(path string, data []byte) (types.DetectionResult, bool) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return types.DetectionResult{}, false
+	}
+
+	if job, ok := doc["Job"]; ok {
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(job, &fields) == nil && fields["TaskGroups"] != nil {
+			return detected(types.SourceNomadJob, path, types.ConfidenceHigh, "JSON Job with TaskGroups"), true
+		}
+	}
+	if doc["TaskGroups"] != nil && doc["ID"] != nil {
+		return detected(types.SourceNomadJob, path, types.ConfidenceHigh, "JSON job with ID and TaskGroups"), true
+	}
+	if doc["apiVersion"] != nil && doc["kind"] != nil {
+		return detected(types.SourceKubernetes, path, types.ConfidenceHigh, "JSON apiVersion/kind"), true
+	}
+	return types.DetectionResult{}, false
+}
+
+// looksLikeDockerfile reports whether the first instruction is FROM, or ARG before FROM
+func looksLikeDockerfile(data []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		return dockerInstruction.MatchString(line)
+	}
+	return false
+}
+
+// looksLikeProcfile reports whether every line is a `process: command` pair
+func looksLikeProcfile(data []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	processes := 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !procfileLine.MatchString(line) {
+			return false
+		}
+		processes++
+	}
+	return processes > 0
+}
+
+// ambiguous reports a file detection couldn't classify, pointing at --type
+func ambiguous(path, why string) types.DetectionResult {
+	return types.DetectionResult{
+		Valid:  false,
+		Error:  fmt.Sprintf("could not tell what kind of source %s is: %s; pass --type (%s)", path, why, TypeList()),
+		Reason: why,
+	}
+}
+
+func readHead(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(io.LimitReader(file, maxSniffSize))
+}
+
+func uniqueSorted(values []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
@@ -0,0 +1,269 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+// Files written next to the jobs when nompose builds images
+const (
+	buildScriptFile = "build.sh"
+	bakeFile        = "docker-bake.hcl"
+)
+
+// Builder pack uses when PACK_BUILDER isn't set
+const defaultPackBuilder = "paketobuildpacks/builder-jammy-base"
+
+// shellSafe matches words that need no quoting in a POSIX shell
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_./:=,@%+-]+$`)
+
+// buildTarget is an image the generated build files produce
+type buildTarget struct {
+	Name  string // Service the image was chosen for; the bake target name
+	Image string
+	Build types.BuildConfig
+}
+
+// buildTargets lists the images to build, one per image name; services
+// sharing an image (e.g. Procfile processes) share its target
+func (g *NomadGenerator) buildTargets() []buildTarget {
+	var targets []buildTarget
+	seen := make(map[string]string)
+	for _, service := range g.services {
+		if service.Build == nil || service.ResolvedImage == "" || strings.HasPrefix(service.ResolvedImage, "{{") {
+			continue
+		}
+		if first, ok := seen[service.ResolvedImage]; ok {
+			if first != service.Name && g.catalog[first].Build.Context != service.Build.Context {
+				g.warn("%s and %s both build %s; only %s's build is in %s", first, service.Name, service.ResolvedImage, first, buildScriptFile)
+			}
+			continue
+		}
+		seen[service.ResolvedImage] = service.Name
+
+		build := *service.Build
+		build.Context = relativeToWorkDir(build.Context)
+		build.Secrets = append([]types.BuildSecret(nil), build.Secrets...)
+		for i, secret := range build.Secrets {
+			if secret.File != "" {
+				build.Secrets[i].File = relativeToWorkDir(secret.File)
+			}
+		}
+		if build.Builder == types.BuilderBuildpacks && isRemoteContext(build.Context) {
+			g.warn("%s is built with buildpacks, which can't build from %s; build %s yourself", service.Name, build.Context, service.ResolvedImage)
+			continue
+		}
+		targets = append(targets, buildTarget{Name: service.Name, Image: service.ResolvedImage, Build: build})
+	}
+	return targets
+}
+
+// writeBuildFiles writes build.sh and, when any image is built from a
+// Dockerfile, docker-bake.hcl. Both build from the directory nompose ran in.
+func (g *NomadGenerator) writeBuildFiles() ([]string, error) {
+	targets := g.buildTargets()
+	if len(targets) == 0 {
+		return nil, nil
+	}
+
+	script, err := g.writeFile(buildScriptFile, g.buildScript(targets))
+	if err != nil {
+		return nil, err
+	}
+	files := []string{script}
+
+	for _, target := range targets {
+		if target.Build.Builder == types.BuilderDocker {
+			bake, err := g.writeFile(bakeFile, bakeContent(targets))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, bake)
+			break
+		}
+	}
+	return files, nil
+}
+
+// buildScript renders build.sh: docker buildx for Dockerfiles, pack for
+// buildpacks. Images are loaded locally unless PUSH=1.
+func (g *NomadGenerator) buildScript(targets []buildTarget) string {
+	var content strings.Builder
+	content.WriteString("#!/bin/sh\n")
+	content.WriteString("# Builds the images the generated jobs run.\n")
+	content.WriteString("# PUSH=1 pushes them to the registry instead of loading them locally.\n")
+	content.WriteString("set -eu\n\n")
+	fmt.Fprintf(&content, "cd \"$(dirname \"$0\")%s\"\n\n", g.workDirFromOutput())
+	content.WriteString("if [ \"${PUSH:-0}\" = 1 ]; then\n")
+	content.WriteString("  output=--push\n")
+	content.WriteString("  publish=--publish\n")
+	content.WriteString("else\n")
+	content.WriteString("  output=--load\n")
+	content.WriteString("  publish=\n")
+	content.WriteString("fi\n")
+
+	for _, target := range targets {
+		build := target.Build
+		fmt.Fprintf(&content, "\n# %s\n", target.Name)
+
+		if build.Builder == types.BuilderBuildpacks {
+			fmt.Fprintf(&content, "pack build %s $publish \\\n", shellQuote(target.Image))
+			fmt.Fprintf(&content, "  --path %s \\\n", shellQuote(build.Context))
+			fmt.Fprintf(&content, "  --builder \"${PACK_BUILDER:-%s}\"\n", defaultPackBuilder)
+			continue
+		}
+
+		var lines []string
+		if build.Dockerfile != "" {
+			lines = append(lines, "--file "+shellQuote(dockerfilePath(build)))
+		}
+		if build.Target != "" {
+			lines = append(lines, "--target "+shellQuote(build.Target))
+		}
+		for _, key := range sortedKeys(build.Args) {
+			lines = append(lines, "--build-arg "+shellQuote(key+"="+build.Args[key]))
+		}
+		for _, cache := range build.CacheFrom {
+			lines = append(lines, "--cache-from "+shellQuote(cache))
+		}
+		if len(build.Platforms) > 0 {
+			lines = append(lines, "--platform "+shellQuote(strings.Join(build.Platforms, ",")))
+		}
+		for _, secret := range build.Secrets {
+			lines = append(lines, "--secret "+shellQuote(secretSpec(secret)))
+		}
+		lines = append(lines, "--tag "+shellQuote(target.Image), shellQuote(build.Context))
+
+		content.WriteString("docker buildx build $output")
+		for _, line := range lines {
+			content.WriteString(" \\\n  " + line)
+		}
+		content.WriteString("\n")
+	}
+
+	return content.String()
+}
+
+// bakeContent renders docker-bake.hcl with a target per Dockerfile image;
+// buildpacks images are only in build.sh
+func bakeContent(targets []buildTarget) string {
+	var content strings.Builder
+	content.WriteString("# Builds the images the generated jobs run: docker buildx bake [--push]\n\n")
+
+	var names []string
+	for _, target := range targets {
+		if target.Build.Builder == types.BuilderDocker {
+			names = append(names, target.Name)
+		}
+	}
+	writeBlock(&content, "", `group "default"`, []hclAttribute{{"targets", quoteList(names)}})
+
+	for _, target := range targets {
+		build := target.Build
+		if build.Builder != types.BuilderDocker {
+			fmt.Fprintf(&content, "# %s is built with buildpacks; see %s\n\n", target.Name, buildScriptFile)
+			continue
+		}
+
+		attrs := []hclAttribute{{"context", quote(build.Context)}}
+		if build.Dockerfile != "" {
+			attrs = append(attrs, hclAttribute{"dockerfile", quote(build.Dockerfile)})
+		}
+		if build.Target != "" {
+			attrs = append(attrs, hclAttribute{"target", quote(build.Target)})
+		}
+		attrs = append(attrs, hclAttribute{"tags", quoteList([]string{target.Image})})
+		if len(build.Args) > 0 {
+			attrs = append(attrs, hclAttribute{"args", hclMap("  ", build.Args)})
+		}
+		if len(build.CacheFrom) > 0 {
+			attrs = append(attrs, hclAttribute{"cache-from", quoteList(build.CacheFrom)})
+		}
+		if len(build.Platforms) > 0 {
+			attrs = append(attrs, hclAttribute{"platforms", quoteList(build.Platforms)})
+		}
+		if len(build.Secrets) > 0 {
+			secrets := make([]string, len(build.Secrets))
+			for i, secret := range build.Secrets {
+				secrets[i] = secretSpec(secret)
+			}
+			attrs = append(attrs, hclAttribute{"secret", quoteList(secrets)})
+		}
+		writeBlock(&content, "", fmt.Sprintf("target %s", quote(target.Name)), attrs)
+	}
+
+	return strings.TrimSuffix(content.String(), "\n")
+}
+
+// workDirFromOutput is the path from the output directory back to the
+// directory nompose ran in, as a "/<path>" suffix; empty when they match
+func (g *NomadGenerator) workDirFromOutput() string {
+	workDir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	outputDir, err := filepath.Abs(g.outputDir)
+	if err != nil {
+		return ""
+	}
+	relative, err := filepath.Rel(outputDir, workDir)
+	if err != nil || relative == "." {
+		return ""
+	}
+	return "/" + filepath.ToSlash(relative)
+}
+
+// dockerfilePath is the Dockerfile as docker buildx build --file expects it:
+// relative to the working directory for local contexts, to the context for
+// git contexts
+func dockerfilePath(build types.BuildConfig) string {
+	if isRemoteContext(build.Context) || filepath.IsAbs(build.Dockerfile) {
+		return build.Dockerfile
+	}
+	return filepath.ToSlash(filepath.Join(build.Context, build.Dockerfile))
+}
+
+// secretSpec renders a build secret as --secret takes it
+func secretSpec(secret types.BuildSecret) string {
+	switch {
+	case secret.File != "":
+		return fmt.Sprintf("id=%s,src=%s", secret.ID, secret.File)
+	case secret.Environment != "":
+		return fmt.Sprintf("id=%s,env=%s", secret.ID, secret.Environment)
+	}
+	return "id=" + secret.ID
+}
+
+// relativeToWorkDir shortens absolute paths under the working directory
+func relativeToWorkDir(path string) string {
+	if !filepath.IsAbs(path) {
+		return filepath.ToSlash(path)
+	}
+	workDir, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	relative, err := filepath.Rel(workDir, path)
+	if err != nil || strings.HasPrefix(relative, "..") {
+		return path
+	}
+	return filepath.ToSlash(relative)
+}
+
+// isRemoteContext reports whether a build context is a git or https URL
+func isRemoteContext(context string) bool {
+	return strings.Contains(context, "://") || strings.HasPrefix(context, "git@")
+}
+
+// shellQuote quotes a word for a POSIX shell when it needs quoting
+func shellQuote(word string) string {
+	if shellSafe.MatchString(word) {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
@@ -0,0 +1,224 @@
+package generator
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/shellwords"
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+// Service registration providers
+const (
+	ServiceProviderConsul = "consul"
+	ServiceProviderNomad  = "nomad"
+)
+
+// Check types each provider can run
+var supportedCheckTypes = map[string]map[string]bool{
+	ServiceProviderConsul: {"http": true, "tcp": true, "script": true, "grpc": true},
+	ServiceProviderNomad:  {"http": true, "tcp": true},
+}
+
+// healthCheck is a converted compose healthcheck
+type healthCheck struct {
+	Type     string
+	Path     string   // http checks
+	Protocol string   // http checks: http or https
+	PortName string   // http and tcp checks
+	Command  []string // script checks
+	Interval string
+	Timeout  string
+	Retries  int
+	Grace    string
+}
+
+// serviceProvider returns the provider services are registered with
+func (g *NomadGenerator) serviceProvider() string {
+	if g.options.ServiceProvider == "" {
+		return ServiceProviderConsul
+	}
+	return g.options.ServiceProvider
+}
+
+// buildHealthCheck converts the compose healthcheck, falling back to a TCP
+// check on the main port when there is none or the provider can't run it
+func (g *NomadGenerator) buildHealthCheck(service types.EnhancedServiceConfig) healthCheck {
+	check := healthCheck{Type: "tcp", PortName: g.portLabel(service, 0), Interval: "30s", Timeout: "3s"}
+
+	config := service.OriginalService.HealthCheck
+	if config == nil {
+		if path := g.healthCheckPath(service); path != "" {
+			check.Type = "http"
+			check.Path = path
+		}
+		return check
+	}
+	if config.Disable {
+		return check
+	}
+
+	if config.Interval != "" {
+		check.Interval = config.Interval
+	}
+	if config.Timeout != "" {
+		check.Timeout = config.Timeout
+	}
+	check.Retries = config.Retries
+	check.Grace = config.StartPeriod
+
+	command, shell := healthCheckCommand(config.Test)
+	if len(command) == 0 {
+		return check
+	}
+
+	if path, protocol, portName, ok := g.httpCheckTarget(service, command, shell); ok {
+		check.Type = "http"
+		check.Path = path
+		check.Protocol = protocol
+		check.PortName = portName
+		return check
+	}
+
+	if !supportedCheckTypes[g.serviceProvider()]["script"] {
+		g.warn("%s: healthcheck %q needs a script check, which the %s provider doesn't support; using a TCP check instead", service.Name, strings.Join(command, " "), g.serviceProvider())
+		return check
+	}
+
+	check.Type = "script"
+	check.Command = command
+	return check
+}
+
+// healthCheckCommand flattens the compose test forms: ["CMD", ...], ["CMD-SHELL", "..."] and a plain string
+func healthCheckCommand(test interface{}) ([]string, string) {
+	switch value := test.(type) {
+	case string:
+		return []string{"/bin/sh", "-c", value}, value
+	case []interface{}:
+		if len(value) == 0 {
+			return nil, ""
+		}
+		words := make([]string, 0, len(value))
+		for _, word := range value[1:] {
+			words = append(words, fmt.Sprintf("%v", word))
+		}
+		switch fmt.Sprintf("%v", value[0]) {
+		case "CMD":
+			return words, ""
+		case "CMD-SHELL":
+			shell := strings.Join(words, " ")
+			return []string{"/bin/sh", "-c", shell}, shell
+		}
+	}
+	// "NONE" or an unknown form disables the check
+	return nil, ""
+}
+
+// httpCheckTarget recognises `curl`/`wget` probes of a local URL
+func (g *NomadGenerator) httpCheckTarget(service types.EnhancedServiceConfig, command []string, shell string) (string, string, string, bool) {
+	words := command
+	if shell != "" {
+		split, err := shellwords.Split(shell)
+		if err != nil {
+			return "", "", "", false
+		}
+		words = split
+	}
+	if len(words) == 0 || (words[0] != "curl" && words[0] != "wget") {
+		return "", "", "", false
+	}
+
+	for _, word := range words[1:] {
+		target, err := url.Parse(word)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+			continue
+		}
+		switch target.Hostname() {
+		case "localhost", "127.0.0.1", "0.0.0.0":
+		default:
+			return "", "", "", false
+		}
+
+		containerPort := 80
+		if target.Scheme == "https" {
+			containerPort = 443
+		}
+		if target.Port() != "" {
+			containerPort, _ = strconv.Atoi(target.Port())
+		}
+
+		portName, ok := g.portNameForContainerPort(service, containerPort)
+		if !ok {
+			return "", "", "", false
+		}
+
+		path := target.EscapedPath()
+		if path == "" {
+			path = "/"
+		}
+		if target.RawQuery != "" {
+			path += "?" + target.RawQuery
+		}
+		return path, target.Scheme, portName, true
+	}
+
+	return "", "", "", false
+}
+
+// portNameForContainerPort finds the network port label mapped to a container port
+func (g *NomadGenerator) portNameForContainerPort(service types.EnhancedServiceConfig, containerPort int) (string, bool) {
+	for i, port := range service.ResolvedPorts {
+		if port.Container == containerPort {
+			return g.portLabel(service, i), true
+		}
+	}
+	return "", false
+}
+
+// checkBlock renders a health check; group-level script checks must name their task
+func (g *NomadGenerator) checkBlock(check healthCheck, groupLevel bool) hclBlock {
+	block := hclBlock{
+		Header: "check",
+		Attrs:  []hclAttribute{{"type", quote(check.Type)}},
+	}
+
+	switch check.Type {
+	case "http":
+		block.Attrs = append(block.Attrs, hclAttribute{"path", quote(check.Path)})
+		if check.Protocol == "https" {
+			block.Attrs = append(block.Attrs, hclAttribute{"protocol", quote("https")})
+		}
+	case "script":
+		block.Attrs = append(block.Attrs, hclAttribute{"command", quote(check.Command[0])})
+		if len(check.Command) > 1 {
+			block.Attrs = append(block.Attrs, hclAttribute{"args", quoteList(check.Command[1:])})
+		}
+		if groupLevel {
+			block.Attrs = append(block.Attrs, hclAttribute{"task", quote("app")})
+		}
+	}
+
+	block.Attrs = append(block.Attrs,
+		hclAttribute{"interval", quote(check.Interval)},
+		hclAttribute{"timeout", quote(check.Timeout)})
+
+	if check.Type != "script" {
+		block.Attrs = append(block.Attrs, hclAttribute{"port", quote(check.PortName)})
+	}
+
+	if check.Retries > 0 {
+		restart := hclBlock{
+			Header: "check_restart",
+			Attrs:  []hclAttribute{{"limit", strconv.Itoa(check.Retries)}},
+		}
+		if check.Grace != "" {
+			restart.Attrs = append(restart.Attrs, hclAttribute{"grace", quote(check.Grace)})
+		}
+		block.Blocks = append(block.Blocks, restart)
+	}
+
+	return block
+}
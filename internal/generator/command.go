@@ -0,0 +1,87 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/Jassem-HCP/nompose/internal/shellwords"
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+// taskCommand holds the docker driver entrypoint, command and args
+type taskCommand struct {
+	Entrypoint []string
+	Command    string
+	Args       []string
+}
+
+// buildTaskCommand converts compose `entrypoint` and `command` into docker driver settings.
+// Compose splits string forms with shell-word rules and never runs a shell, so a
+// command relying on pipes or variables is wrapped in `sh -c` and a warning is recorded.
+func (g *NomadGenerator) buildTaskCommand(service types.EnhancedServiceConfig) taskCommand {
+	var result taskCommand
+
+	result.Entrypoint = g.commandWords(service.Name, "entrypoint", service.OriginalService.Entrypoint, false)
+
+	// With a custom entrypoint the command is handed to it, so it can't be re-wrapped
+	command := g.commandWords(service.Name, "command", service.OriginalService.Command, len(result.Entrypoint) == 0)
+	if len(command) > 0 {
+		result.Command = command[0]
+		result.Args = command[1:]
+	}
+
+	return result
+}
+
+// commandWords flattens a string or list command into words
+func (g *NomadGenerator) commandWords(serviceName, field string, value interface{}, allowWrap bool) []string {
+	switch command := value.(type) {
+	case string:
+		if command == "" {
+			return nil
+		}
+		if needsShell, feature := shellwords.RequiresShell(command); needsShell {
+			if allowWrap {
+				g.warn("%s: %s uses a %s, wrapped in `/bin/sh -c` (the image must ship a shell)", serviceName, field, feature)
+				return []string{"/bin/sh", "-c", command}
+			}
+			g.warn("%s: %s uses a %s but is passed to a custom entrypoint; it will not be interpreted by a shell", serviceName, field, feature)
+		}
+		words, err := shellwords.Split(command)
+		if err != nil {
+			g.warn("%s: could not split %s (%v), passing it through a shell", serviceName, field, err)
+			return []string{"/bin/sh", "-c", command}
+		}
+		return words
+	case []interface{}:
+		words := make([]string, 0, len(command))
+		for _, word := range command {
+			words = append(words, fmt.Sprintf("%v", word))
+		}
+		return words
+	}
+	return nil
+}
+
+// commandAttributes returns the entrypoint/command/args/work_dir attributes for the docker config block
+func (g *NomadGenerator) commandAttributes(service types.EnhancedServiceConfig, addresses addressPlan) []hclAttribute {
+	command := g.buildTaskCommand(service)
+	for i, arg := range command.Args {
+		command.Args[i] = addresses.RewriteArg(arg)
+	}
+
+	var attrs []hclAttribute
+	if len(command.Entrypoint) > 0 {
+		attrs = append(attrs, hclAttribute{"entrypoint", quoteList(command.Entrypoint)})
+	}
+	if command.Command != "" {
+		attrs = append(attrs, hclAttribute{"command", quote(command.Command)})
+	}
+	if len(command.Args) > 0 {
+		attrs = append(attrs, hclAttribute{"args", quoteList(command.Args)})
+	}
+	if service.OriginalService.WorkingDir != "" {
+		attrs = append(attrs, hclAttribute{"work_dir", quote(service.OriginalService.WorkingDir)})
+	}
+
+	return attrs
+}
@@ -0,0 +1,710 @@
+package generator
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/types"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// nomadInterpolation matches ${...} runtime variables
+	nomadInterpolation = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+	// consulDNS matches Consul DNS names, e.g. db.service.consul
+	consulDNS = regexp.MustCompile(`\b([a-z0-9][a-z0-9-]*)\.service(?:\.[a-z0-9-]+)?\.consul\b`)
+
+	// templateAddress matches the service lookups nompose renders with
+	// --address-rewrite consul-template and nomad-template
+	templateAddress = regexp.MustCompile(`\{\{\s*range\s+(?:service|nomadService)\s+"([^"]+)"\s*\}\}\{\{\s*\.Address\s*\}\}(:\{\{\s*\.Port\s*\}\})?\{\{\s*end\s*\}\}`)
+
+	// templateEnv matches {{ env "NAME" }}
+	templateEnv = regexp.MustCompile(`\{\{\s*env\s+"([^"]+)"\s*\}\}`)
+)
+
+// Static stand-ins for Nomad runtime variables
+var nomadRuntimeDefaults = map[string]string{
+	"NOMAD_ALLOC_INDEX": "0",
+	"NOMAD_NAMESPACE":   "default",
+	"NOMAD_REGION":      "global",
+	"NOMAD_DC":          "dc1",
+	"NOMAD_TASK_DIR":    "/local",
+	"NOMAD_ALLOC_DIR":   "/alloc",
+	"NOMAD_SECRETS_DIR": "/secrets",
+}
+
+// composeFile is the docker-compose.yml nompose reverse writes
+type composeFile struct {
+	Services map[string]*composeService   `yaml:"services"`
+	Volumes  map[string]map[string]string `yaml:"volumes,omitempty"`
+	Configs  map[string]composeConfig     `yaml:"configs,omitempty"`
+}
+
+type composeService struct {
+	Image       string                       `yaml:"image"`
+	Entrypoint  []string                     `yaml:"entrypoint,omitempty"`
+	Command     []string                     `yaml:"command,omitempty"`
+	WorkingDir  string                       `yaml:"working_dir,omitempty"`
+	User        string                       `yaml:"user,omitempty"`
+	Hostname    string                       `yaml:"hostname,omitempty"`
+	Ports       []string                     `yaml:"ports,omitempty"`
+	Environment map[string]string            `yaml:"environment,omitempty"`
+	Volumes     []string                     `yaml:"volumes,omitempty"`
+	Configs     []composeConfigMount         `yaml:"configs,omitempty"`
+	Healthcheck *composeHealthcheck          `yaml:"healthcheck,omitempty"`
+	DependsOn   map[string]composeDependency `yaml:"depends_on,omitempty"`
+	Privileged  bool                         `yaml:"privileged,omitempty"`
+	CapAdd      []string                     `yaml:"cap_add,omitempty"`
+	CapDrop     []string                     `yaml:"cap_drop,omitempty"`
+	Labels      map[string]string            `yaml:"labels,omitempty"`
+	Deploy      *composeDeploy               `yaml:"deploy,omitempty"`
+	Restart     string                       `yaml:"restart,omitempty"`
+}
+
+type composeConfig struct {
+	Content string `yaml:"content"`
+}
+
+type composeConfigMount struct {
+	Source string `yaml:"source"`
+	Target string `yaml:"target"`
+}
+
+type composeHealthcheck struct {
+	Test        []string `yaml:"test,flow"`
+	Interval    string   `yaml:"interval,omitempty"`
+	Timeout     string   `yaml:"timeout,omitempty"`
+	Retries     int      `yaml:"retries,omitempty"`
+	StartPeriod string   `yaml:"start_period,omitempty"`
+}
+
+type composeDependency struct {
+	Condition string `yaml:"condition"`
+}
+
+type composeDeploy struct {
+	Replicas  int               `yaml:"replicas,omitempty"`
+	Resources *composeResources `yaml:"resources,omitempty"`
+}
+
+type composeResources struct {
+	Limits composeLimits `yaml:"limits"`
+}
+
+type composeLimits struct {
+	CPUs   string `yaml:"cpus,omitempty"`
+	Memory string `yaml:"memory,omitempty"`
+}
+
+// composeTask is a task with the group and job it runs in
+type composeTask struct {
+	Name  string // Compose service name
+	Job   types.NomadJob
+	Group types.JobGroup
+	Task  types.JobTask
+	Main  bool // First main task of its group; owns group ports and services
+}
+
+// consulTarget is where a registered service is reached on the compose network
+type consulTarget struct {
+	Service string
+	Port    int
+}
+
+// ComposeGenerator turns Nomad jobs back into a docker-compose file
+type ComposeGenerator struct {
+	notes    []string
+	tasks    []composeTask
+	registry map[string]consulTarget // Consul service name → compose service
+}
+
+// NewComposeGenerator creates a compose generator
+func NewComposeGenerator() *ComposeGenerator {
+	return &ComposeGenerator{registry: make(map[string]consulTarget)}
+}
+
+// Notes lists what couldn't be carried over to compose
+func (c *ComposeGenerator) Notes() []string {
+	return c.notes
+}
+
+func (c *ComposeGenerator) note(format string, args ...interface{}) {
+	note := fmt.Sprintf(format, args...)
+	for _, existing := range c.notes {
+		if existing == note {
+			return
+		}
+	}
+	c.notes = append(c.notes, note)
+}
+
+// Generate renders the docker-compose.yml running the docker tasks of jobs
+func (c *ComposeGenerator) Generate(jobs []types.NomadJob) (string, error) {
+	c.notes = nil
+	c.planTasks(jobs)
+	if len(c.tasks) == 0 {
+		return "", fmt.Errorf("no docker tasks to convert")
+	}
+
+	compose := composeFile{Services: make(map[string]*composeService)}
+	for _, task := range c.tasks {
+		compose.Services[task.Name] = c.service(task, &compose)
+	}
+
+	// Wait for healthy dependencies when they have a healthcheck
+	for _, service := range compose.Services {
+		for name, dependency := range service.DependsOn {
+			if dependency.Condition == "service_started" && compose.Services[name] != nil && compose.Services[name].Healthcheck != nil {
+				service.DependsOn[name] = composeDependency{Condition: "service_healthy"}
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(compose); err != nil {
+		return "", fmt.Errorf("failed to render docker-compose.yml: %w", err)
+	}
+	return out.String(), nil
+}
+
+// planTasks names a compose service per docker task and indexes the
+// services tasks register
+func (c *ComposeGenerator) planTasks(jobs []types.NomadJob) {
+	c.tasks = nil
+	taken := make(map[string]bool)
+	for _, job := range jobs {
+		for _, group := range job.Groups {
+			base := job.Name
+			if len(job.Groups) > 1 && group.Name != job.Name {
+				base = job.Name + "-" + group.Name
+			}
+
+			mainTasks := 0
+			for _, task := range group.Tasks {
+				if task.Lifecycle == "" && task.Driver == "docker" {
+					mainTasks++
+				}
+			}
+
+			first := true
+			for _, task := range group.Tasks {
+				if task.Driver != "docker" {
+					c.note("%s: task %s uses the %s driver and is left out", job.Name, task.Name, task.Driver)
+					continue
+				}
+				name := base
+				if task.Lifecycle != "" || mainTasks > 1 {
+					name = base + "-" + task.Name
+				}
+				for i := 2; taken[name]; i++ {
+					name = fmt.Sprintf("%s-%s-%d", base, task.Name, i)
+				}
+				taken[name] = true
+
+				main := first && task.Lifecycle == ""
+				if main {
+					first = false
+				}
+				c.tasks = append(c.tasks, composeTask{Name: name, Job: job, Group: group, Task: task, Main: main})
+			}
+		}
+	}
+
+	for _, task := range c.tasks {
+		services := task.Task.Services
+		if task.Main {
+			services = append(append([]types.JobService(nil), task.Group.Services...), services...)
+		}
+		for _, service := range services {
+			if service.Name == "" {
+				continue
+			}
+			if _, ok := c.registry[service.Name]; !ok {
+				c.registry[service.Name] = consulTarget{Service: task.Name, Port: containerPortOf(task.Group, service.Port)}
+			}
+		}
+	}
+}
+
+// service converts a task
+func (c *ComposeGenerator) service(task composeTask, compose *composeFile) *composeService {
+	t := task.Task
+	dependencies := make(map[string]string)
+	service := &composeService{
+		Image:      c.interpolate(task, t.Image, dependencies),
+		WorkingDir: escapeDollar(t.WorkDir),
+		User:       escapeDollar(t.User),
+		Hostname:   escapeDollar(t.Hostname),
+		Privileged: t.Privileged,
+		CapAdd:     t.CapAdd,
+		CapDrop:    t.CapDrop,
+		Labels:     escapeDollars(t.Labels),
+	}
+
+	for _, word := range t.Entrypoint {
+		service.Entrypoint = append(service.Entrypoint, c.interpolate(task, word, dependencies))
+	}
+	if t.Command != "" {
+		service.Command = append(service.Command, c.interpolate(task, t.Command, dependencies))
+	}
+	for _, word := range t.Args {
+		service.Command = append(service.Command, c.interpolate(task, word, dependencies))
+	}
+
+	service.Ports = c.ports(task)
+
+	env := make(map[string]string)
+	for _, key := range sortedKeys(t.Env) {
+		env[key] = c.interpolate(task, t.Env[key], dependencies)
+	}
+	c.templates(task, service, env, compose, dependencies)
+	if len(env) > 0 {
+		service.Environment = env
+	}
+
+	service.Volumes = append(service.Volumes, c.volumes(task, compose)...)
+	service.Healthcheck = c.healthcheck(task)
+
+	// Init tasks run before the main tasks, poststart tasks after them
+	for _, other := range c.tasks {
+		if other.Job.Name != task.Job.Name || other.Group.Name != task.Group.Name || other.Name == task.Name {
+			continue
+		}
+		switch {
+		case t.Lifecycle == "" && other.Task.Lifecycle == "prestart" && other.Task.Sidecar:
+			dependencies[other.Name] = "service_started"
+		case t.Lifecycle == "" && other.Task.Lifecycle == "prestart":
+			dependencies[other.Name] = "service_completed_successfully"
+		case t.Lifecycle == "poststart" && other.Task.Lifecycle == "":
+			dependencies[other.Name] = "service_started"
+		}
+	}
+	if t.Lifecycle == "poststop" {
+		c.note("%s: poststop task %s runs alongside the others in compose", task.Job.Name, t.Name)
+	}
+
+	// Connect upstreams are dependencies
+	if task.Main {
+		for _, registered := range task.Group.Services {
+			for _, upstream := range registered.Upstreams {
+				if target, ok := c.registry[upstream.Service]; ok {
+					dependencies[target.Service] = "service_started"
+				}
+			}
+		}
+	}
+
+	delete(dependencies, task.Name)
+	if len(dependencies) > 0 {
+		service.DependsOn = make(map[string]composeDependency)
+		for name, condition := range dependencies {
+			service.DependsOn[name] = composeDependency{Condition: condition}
+		}
+	}
+
+	service.Deploy = c.deploy(task)
+	service.Restart = "unless-stopped"
+	if task.Job.Type == "batch" || task.Job.Type == "sysbatch" || t.Lifecycle != "" && !t.Sidecar {
+		service.Restart = "no"
+	}
+	if task.Job.Type == "system" || task.Job.Type == "sysbatch" {
+		c.note("%s: %s job runs once here, not on every client", task.Job.Name, task.Job.Type)
+	}
+	return service
+}
+
+// ports publishes the group ports a task uses; the main task also publishes
+// the ports no task lists, e.g. those of bridge mode services
+func (c *ComposeGenerator) ports(task composeTask) []string {
+	used := make(map[string]bool)
+	for _, other := range task.Group.Tasks {
+		for _, label := range other.Ports {
+			used[label] = true
+		}
+	}
+	labels := make(map[string]bool)
+	for _, label := range task.Task.Ports {
+		labels[label] = true
+	}
+
+	var ports []string
+	for _, port := range task.Group.Ports {
+		if !labels[port.Label] && (!task.Main || used[port.Label]) {
+			continue
+		}
+		switch {
+		case port.Static > 0 && port.To > 0:
+			ports = append(ports, fmt.Sprintf("%d:%d", port.Static, port.To))
+		case port.Static > 0:
+			ports = append(ports, fmt.Sprintf("%d:%d", port.Static, port.Static))
+		case port.To > 0:
+			ports = append(ports, strconv.Itoa(port.To))
+		default:
+			c.note("%s: dynamic port %s has no fixed container port; publish the port the app listens on", task.Job.Name, port.Label)
+		}
+		if port.Static > 0 && task.Group.Count > 1 {
+			c.note("%s: %d replicas can't all bind static port %d", task.Job.Name, task.Group.Count, port.Static)
+		}
+	}
+	return ports
+}
+
+// templates renders static templates: env templates into env, file
+// templates into compose configs
+func (c *ComposeGenerator) templates(task composeTask, service *composeService, env map[string]string, compose *composeFile, dependencies map[string]string) {
+	for i, template := range task.Task.Templates {
+		if template.Source != "" {
+			c.note("%s: template %s is read from %s", task.Job.Name, template.Destination, template.Source)
+			continue
+		}
+		data := c.renderTemplate(task, template.Data, dependencies)
+
+		if template.Env {
+			scanner := bufio.NewScanner(strings.NewReader(data))
+			for scanner.Scan() {
+				line := strings.TrimSpace(scanner.Text())
+				if line == "" || strings.HasPrefix(line, "#") {
+					continue
+				}
+				key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+				if !ok {
+					continue
+				}
+				if strings.Contains(value, "{{") {
+					c.note("%s: %s is rendered by a template at runtime", task.Job.Name, strings.TrimSpace(key))
+					continue
+				}
+				env[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+			}
+			continue
+		}
+
+		if strings.Contains(data, "{{") {
+			c.note("%s: template %s is rendered at runtime", task.Job.Name, template.Destination)
+			continue
+		}
+		name := fmt.Sprintf("%s-%s", task.Name, sanitizeConfigName(path.Base(template.Destination)))
+		if _, taken := compose.Configs[name]; taken {
+			name = fmt.Sprintf("%s-%d", name, i+1)
+		}
+		if compose.Configs == nil {
+			compose.Configs = make(map[string]composeConfig)
+		}
+		compose.Configs[name] = composeConfig{Content: escapeDollar(data)}
+		service.Configs = append(service.Configs, composeConfigMount{Source: name, Target: c.templateTarget(task, template.Destination)})
+	}
+}
+
+// renderTemplate resolves the service lookups and env calls nompose puts in
+// templates; anything else is left for the caller to report
+func (c *ComposeGenerator) renderTemplate(task composeTask, data string, dependencies map[string]string) string {
+	data = templateAddress.ReplaceAllStringFunc(data, func(match string) string {
+		groups := templateAddress.FindStringSubmatch(match)
+		target, ok := c.registry[groups[1]]
+		if !ok {
+			target = consulTarget{Service: groups[1]}
+		}
+		dependencies[target.Service] = "service_started"
+		if groups[2] != "" && target.Port > 0 {
+			return fmt.Sprintf("%s:%d", target.Service, target.Port)
+		}
+		if groups[2] != "" {
+			return match
+		}
+		return target.Service
+	})
+	return templateEnv.ReplaceAllStringFunc(data, func(match string) string {
+		name := templateEnv.FindStringSubmatch(match)[1]
+		if value, ok := c.runtimeValue(task, name); ok {
+			return value
+		}
+		if value, ok := task.Task.Env[name]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+// templateTarget is where a template ends up in the container: the target
+// of the bind mount that mounts it, or its path under the task directory
+func (c *ComposeGenerator) templateTarget(task composeTask, destination string) string {
+	destination = strings.TrimPrefix(destination, "${NOMAD_TASK_DIR}/")
+	for _, mount := range task.Task.Mounts {
+		parts := strings.Split(mount, ":")
+		if len(parts) >= 2 && path.Clean(parts[0]) == path.Clean(destination) {
+			return parts[1]
+		}
+	}
+	return "/" + strings.TrimPrefix(path.Clean(destination), "/")
+}
+
+// volumes maps volume_mount blocks to named volumes and keeps bind mounts;
+// paths in the task directory are served by templates
+func (c *ComposeGenerator) volumes(task composeTask, compose *composeFile) []string {
+	var volumes []string
+	for _, mount := range task.Task.VolumeMounts {
+		volume, ok := task.Group.Volumes[mount.Volume]
+		if !ok {
+			c.note("%s: volume_mount of undeclared volume %s", task.Job.Name, mount.Volume)
+			continue
+		}
+		if volume.Type == "csi" {
+			c.note("%s: CSI volume %s becomes a local named volume", task.Job.Name, volume.Source)
+		}
+		spec := volume.Source + ":" + mount.Destination
+		if mount.ReadOnly || volume.ReadOnly {
+			spec += ":ro"
+		}
+		volumes = append(volumes, escapeDollar(spec))
+		if compose.Volumes == nil {
+			compose.Volumes = make(map[string]map[string]string)
+		}
+		compose.Volumes[volume.Source] = map[string]string{}
+	}
+
+	templates := make(map[string]bool)
+	for _, template := range task.Task.Templates {
+		templates[path.Clean(strings.TrimPrefix(template.Destination, "${NOMAD_TASK_DIR}/"))] = true
+	}
+	for _, mount := range task.Task.Mounts {
+		source := strings.Split(mount, ":")[0]
+		switch {
+		case templates[path.Clean(source)]:
+			// Mounted as a config
+		case strings.HasPrefix(source, "/"):
+			volumes = append(volumes, escapeDollar(mount))
+		case strings.Contains(source, "/") || strings.HasPrefix(source, "."):
+			c.note("%s: mount %s reads from the task directory", task.Job.Name, mount)
+		default:
+			// A docker named volume
+			volumes = append(volumes, escapeDollar(mount))
+			if compose.Volumes == nil {
+				compose.Volumes = make(map[string]map[string]string)
+			}
+			compose.Volumes[source] = map[string]string{}
+		}
+	}
+	return volumes
+}
+
+// healthcheck converts the first check of the task's services
+func (c *ComposeGenerator) healthcheck(task composeTask) *composeHealthcheck {
+	services := task.Task.Services
+	if task.Main {
+		services = append(append([]types.JobService(nil), task.Group.Services...), services...)
+	}
+
+	for _, service := range services {
+		for _, check := range service.Checks {
+			health := &composeHealthcheck{Interval: check.Interval, Timeout: check.Timeout, Retries: check.Retries, StartPeriod: check.Grace}
+			switch check.Type {
+			case "http":
+				port := containerPortOf(task.Group, valueOrDefault(check.Port, service.Port))
+				if port == 0 {
+					c.note("%s: http check on port %s has no fixed container port", task.Job.Name, valueOrDefault(check.Port, service.Port))
+					return nil
+				}
+				target := fmt.Sprintf("%s://localhost:%d%s", valueOrDefault(check.Protocol, "http"), port, valueOrDefault(check.Path, "/"))
+				health.Test = []string{"CMD", "curl", "-f", escapeDollar(target)}
+			case "script":
+				health.Test = []string{"CMD", escapeDollar(check.Command)}
+				for _, arg := range check.Args {
+					health.Test = append(health.Test, escapeDollar(arg))
+				}
+			default:
+				c.note("%s: %s check of %s has no compose equivalent", task.Job.Name, check.Type, service.Name)
+				return nil
+			}
+			return health
+		}
+	}
+	return nil
+}
+
+// deploy converts count and resources
+func (c *ComposeGenerator) deploy(task composeTask) *composeDeploy {
+	deploy := &composeDeploy{}
+	if task.Group.Count > 1 && task.Task.Lifecycle == "" {
+		deploy.Replicas = task.Group.Count
+	}
+
+	t := task.Task
+	memory := t.Memory
+	if t.MemoryMax > memory {
+		memory = t.MemoryMax
+	}
+	if t.CPU > 0 || memory > 0 {
+		deploy.Resources = &composeResources{}
+		if t.CPU > 0 {
+			deploy.Resources.Limits.CPUs = strconv.FormatFloat(float64(t.CPU)/1000, 'f', -1, 64)
+		}
+		if memory > 0 {
+			deploy.Resources.Limits.Memory = fmt.Sprintf("%dM", memory)
+		}
+	}
+
+	if deploy.Replicas == 0 && deploy.Resources == nil {
+		return nil
+	}
+	return deploy
+}
+
+// interpolate replaces Nomad runtime variables, Consul DNS names and
+// Connect upstream addresses with their compose equivalents, and escapes
+// what remains so compose doesn't interpolate it
+func (c *ComposeGenerator) interpolate(task composeTask, value string, dependencies map[string]string) string {
+	var unresolved []string
+	value = nomadInterpolation.ReplaceAllStringFunc(value, func(match string) string {
+		name := strings.TrimSpace(nomadInterpolation.FindStringSubmatch(match)[1])
+		if resolved, ok := c.runtimeValue(task, name); ok {
+			return resolved
+		}
+		unresolved = append(unresolved, name)
+		return match
+	})
+
+	value = consulDNS.ReplaceAllStringFunc(value, func(match string) string {
+		name := consulDNS.FindStringSubmatch(match)[1]
+		target, ok := c.registry[name]
+		if !ok {
+			return name
+		}
+		dependencies[target.Service] = "service_started"
+		return target.Service
+	})
+
+	if task.Main {
+		for _, service := range task.Group.Services {
+			for _, upstream := range service.Upstreams {
+				target, ok := c.registry[upstream.Service]
+				if !ok || target.Port == 0 {
+					continue
+				}
+				address := fmt.Sprintf("%s:%d", target.Service, target.Port)
+				for _, host := range []string{"localhost", "127.0.0.1"} {
+					value = strings.ReplaceAll(value, fmt.Sprintf("%s:%d", host, upstream.LocalPort), address)
+				}
+			}
+		}
+	}
+
+	value = escapeDollar(value)
+	for _, name := range unresolved {
+		c.note("%s: ${%s} is only known at runtime and is left as is", task.Job.Name, name)
+	}
+	return value
+}
+
+// runtimeValue is the compose stand-in for a Nomad runtime variable
+func (c *ComposeGenerator) runtimeValue(task composeTask, name string) (string, bool) {
+	if value, ok := nomadRuntimeDefaults[name]; ok {
+		return value, true
+	}
+	switch name {
+	case "NOMAD_JOB_NAME", "NOMAD_JOB_ID":
+		return task.Job.Name, true
+	case "NOMAD_GROUP_NAME":
+		return task.Group.Name, true
+	case "NOMAD_TASK_NAME":
+		return task.Task.Name, true
+	case "NOMAD_ALLOC_NAME":
+		return fmt.Sprintf("%s.%s[0]", task.Job.Name, task.Group.Name), true
+	case "attr.unique.network.ip-address":
+		return task.Name, true
+	}
+
+	for prefix, render := range map[string]func(types.JobPort) string{
+		"NOMAD_PORT_":      func(port types.JobPort) string { return strconv.Itoa(containerPort(port)) },
+		"NOMAD_HOST_PORT_": func(port types.JobPort) string { return strconv.Itoa(hostPort(port)) },
+		"NOMAD_IP_":        func(types.JobPort) string { return task.Name },
+		"NOMAD_HOST_IP_":   func(types.JobPort) string { return task.Name },
+		"NOMAD_ADDR_":      func(port types.JobPort) string { return fmt.Sprintf("%s:%d", task.Name, containerPort(port)) },
+		"NOMAD_HOST_ADDR_": func(port types.JobPort) string { return fmt.Sprintf("%s:%d", task.Name, containerPort(port)) },
+	} {
+		label, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+		for _, port := range task.Group.Ports {
+			if strings.ReplaceAll(port.Label, "-", "_") == label && containerPort(port) > 0 {
+				return render(port), true
+			}
+		}
+	}
+
+	for prefix, render := range map[string]func(consulTarget) string{
+		"NOMAD_UPSTREAM_ADDR_": func(target consulTarget) string { return fmt.Sprintf("%s:%d", target.Service, target.Port) },
+		"NOMAD_UPSTREAM_IP_":   func(target consulTarget) string { return target.Service },
+		"NOMAD_UPSTREAM_PORT_": func(target consulTarget) string { return strconv.Itoa(target.Port) },
+	} {
+		upstream, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+		for service, target := range c.registry {
+			if strings.ReplaceAll(service, "-", "_") == upstream && target.Port > 0 {
+				return render(target), true
+			}
+		}
+	}
+	return "", false
+}
+
+// containerPortOf is the container port behind a group port label
+func containerPortOf(group types.JobGroup, label string) int {
+	for _, port := range group.Ports {
+		if port.Label == label {
+			return containerPort(port)
+		}
+	}
+	return 0
+}
+
+func containerPort(port types.JobPort) int {
+	if port.To > 0 {
+		return port.To
+	}
+	return port.Static
+}
+
+func hostPort(port types.JobPort) int {
+	if port.Static > 0 {
+		return port.Static
+	}
+	return port.To
+}
+
+// sanitizeConfigName turns a file name into a compose config name
+func sanitizeConfigName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '-'
+	}, name)
+}
+
+// escapeDollars escapes values so compose doesn't interpolate them
+func escapeDollars(values map[string]string) map[string]string {
+	if len(values) == 0 {
+		return nil
+	}
+	escaped := make(map[string]string, len(values))
+	for _, key := range sortedKeys(values) {
+		escaped[key] = escapeDollar(values[key])
+	}
+	return escaped
+}
+
+// escapeDollar escapes a value so compose doesn't interpolate it
+func escapeDollar(value string) string {
+	return strings.ReplaceAll(value, "$", "$$")
+}
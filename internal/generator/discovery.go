@@ -0,0 +1,381 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+// Address rewrite providers for references to other compose services
+const (
+	AddressRewriteNone           = "none"
+	AddressRewriteConsulDNS      = "consul-dns"      // cache → cache.service.consul
+	AddressRewriteConsulTemplate = "consul-template" // {{ with index (service "cache") 0 }}
+	AddressRewriteNomadTemplate  = "nomad-template"  // {{ with index (nomadService "cache") 0 }}
+)
+
+// Config files larger than this are mounted as they are
+const maxConfigTemplateSize = 256 << 10
+
+// addressPlan is the result of rewriting compose hostnames for one service
+type addressPlan struct {
+	Env         map[string]string    // Values kept in the env block
+	TemplateEnv map[string]string    // Values rendered by an env template
+	Files       []configFileTemplate // Bind-mounted config files rendered by templates
+	Notes       []string             // References the rewritten addresses can't reach
+	rewriteArg  func(string) string
+}
+
+// configFileTemplate is a bind-mounted config file re-rendered with Nomad addresses
+type configFileTemplate struct {
+	Source      string // Path on the machine running nompose
+	Destination string // Path inside the task directory
+	Target      string // Path inside the container
+	ReadOnly    bool
+	Data        string
+}
+
+// RewriteArg rewrites service hostnames in a command argument
+func (p addressPlan) RewriteArg(arg string) string {
+	if p.rewriteArg == nil {
+		return arg
+	}
+	return p.rewriteArg(arg)
+}
+
+// usesTemplates reports whether addresses are rendered at runtime by templates
+func (g *NomadGenerator) usesTemplates() bool {
+	return g.options.AddressRewrite == AddressRewriteConsulTemplate || g.options.AddressRewrite == AddressRewriteNomadTemplate
+}
+
+// planAddresses finds references to other services in env values, command
+// args and bind-mounted config files, and rewrites them for the selected provider
+func (g *NomadGenerator) planAddresses(service types.EnhancedServiceConfig, upstreams []meshUpstream) addressPlan {
+	plan := addressPlan{
+		Env:         make(map[string]string),
+		TemplateEnv: make(map[string]string),
+	}
+
+	var names []string
+	var rewrite func(value string, references []serviceReference) string
+
+	switch {
+	case g.meshEnabled():
+		for _, upstream := range upstreams {
+			names = append(names, upstream.Service)
+		}
+		rewrite = func(value string, references []serviceReference) string {
+			return g.rewriteForMesh(value, references, upstreams)
+		}
+	case g.options.AddressRewrite == AddressRewriteConsulDNS:
+		names = g.otherServiceNames(service)
+		rewrite = func(value string, references []serviceReference) string {
+			return rewriteServiceReferences(value, references, g.consulDNSAddress)
+		}
+	case g.usesTemplates():
+		names = g.otherServiceNames(service)
+		rewrite = func(value string, references []serviceReference) string {
+			return rewriteServiceReferences(value, references, g.templateAddress)
+		}
+	default:
+		for key, value := range service.Environment {
+			plan.Env[key] = value
+		}
+		return plan
+	}
+
+	var referenced []serviceReference
+	for key, value := range service.Environment {
+		references := findServiceReferences(value, names)
+		if len(references) == 1 && isBareHostname(references[0], value) && !looksLikeHostKey(key) {
+			// MODE=web is a word, not the web service
+			references = nil
+		}
+		referenced = append(referenced, references...)
+		switch {
+		case len(references) == 0:
+			plan.Env[key] = value
+		case g.usesTemplates():
+			escaped := escapeTemplateDelimiters(value)
+			plan.TemplateEnv[key] = rewrite(escaped, findServiceReferences(escaped, names))
+		default:
+			plan.Env[key] = rewrite(value, references)
+		}
+	}
+
+	plan.rewriteArg = func(arg string) string {
+		references := findServiceReferences(arg, names)
+		if len(references) == 0 {
+			return arg
+		}
+		if !g.usesTemplates() {
+			return rewrite(arg, references)
+		}
+		// Args can't hold template expressions, so render the address into the
+		// env template and interpolate it
+		return rewriteServiceReferences(arg, references, func(reference serviceReference) string {
+			variable := addressVariable(reference)
+			plan.TemplateEnv[variable] = g.templateAddress(reference)
+			return "${" + variable + "}"
+		})
+	}
+
+	plan.Files = g.configFileTemplates(service, names, func(value string, references []serviceReference) string {
+		referenced = append(referenced, references...)
+		return rewrite(value, references)
+	})
+
+	if !g.meshEnabled() {
+		// Upstreams are checked by meshUpstreams
+		for _, arg := range g.buildTaskCommand(service).Args {
+			referenced = append(referenced, findServiceReferences(arg, names)...)
+		}
+		plan.Notes = g.unresolvedAddresses(service, referenced)
+	}
+
+	return plan
+}
+
+// unresolvedAddresses describes references to services that aren't registered,
+// or to ports they aren't registered on; their rewritten addresses won't connect
+func (g *NomadGenerator) unresolvedAddresses(service types.EnhancedServiceConfig, references []serviceReference) []string {
+	var notes []string
+	seen := make(map[string]bool)
+	for _, reference := range references {
+		destination := g.catalog[reference.Service]
+		var note string
+		switch {
+		case len(destination.ResolvedPorts) == 0:
+			note = fmt.Sprintf("%s publishes no ports, so it isn't registered and its address can't be resolved; publish its port or use --mesh consul-connect", reference.Service)
+		case reference.Port == 0:
+		case g.usesTemplates() && destination.ResolvedPorts[0].Container != reference.Port:
+			note = fmt.Sprintf("%s is registered with port %d, so {{ .Port }} won't be %d", reference.Service, destination.ResolvedPorts[0].Container, reference.Port)
+		case !g.usesTemplates() && !publishesPort(destination, reference.Port):
+			note = fmt.Sprintf("%s doesn't publish port %d, so %s.service.consul:%d won't reach it", reference.Service, reference.Port, reference.Service, reference.Port)
+		}
+		if note == "" || seen[note] {
+			continue
+		}
+		seen[note] = true
+		notes = append(notes, note)
+		g.warn("%s: %s", service.Name, note)
+	}
+	return notes
+}
+
+// publishesPort reports whether a service publishes a container port
+func publishesPort(service types.EnhancedServiceConfig, containerPort int) bool {
+	for _, port := range service.ResolvedPorts {
+		if port.Container == containerPort {
+			return true
+		}
+	}
+	return false
+}
+
+// consulDNSAddress points a reference at the service's Consul DNS name,
+// translating the compose container port to the published static port
+func (g *NomadGenerator) consulDNSAddress(reference serviceReference) string {
+	address := reference.Service + ".service.consul"
+	if reference.Port > 0 {
+		port := g.publishedPort(reference.Service, reference.Port)
+		if port == 0 {
+			g.warn("%s: port %d is allocated dynamically, so Consul DNS can't carry it; use --address-rewrite consul-template", reference.Service, reference.Port)
+			return address
+		}
+		address += fmt.Sprintf(":%d", port)
+	}
+	return address
+}
+
+// templateAddress renders a reference as a consul-template lookup of the
+// service's first instance, so the value stays a single address at any count
+func (g *NomadGenerator) templateAddress(reference serviceReference) string {
+	function := "service"
+	if g.options.AddressRewrite == AddressRewriteNomadTemplate {
+		function = "nomadService"
+	}
+
+	if reference.Port > 0 {
+		return fmt.Sprintf(`{{ with index (%s %q) 0 }}{{ .Address }}:{{ .Port }}{{ end }}`, function, reference.Service)
+	}
+	return fmt.Sprintf(`{{ with index (%s %q) 0 }}{{ .Address }}{{ end }}`, function, reference.Service)
+}
+
+// publishedPort maps a destination's container port to the host port it is
+// registered on, 0 when Nomad allocates it; unpublished ports are kept and
+// reported by unresolvedAddresses
+func (g *NomadGenerator) publishedPort(serviceName string, containerPort int) int {
+	for _, port := range g.catalog[serviceName].ResolvedPorts {
+		if port.Container == containerPort {
+			return port.Host
+		}
+	}
+	return containerPort
+}
+
+// configFileTemplates re-renders bind-mounted files that mention other services
+func (g *NomadGenerator) configFileTemplates(service types.EnhancedServiceConfig, names []string, rewrite func(string, []serviceReference) string) []configFileTemplate {
+	var templates []configFileTemplate
+	// The env template is written next to the config files
+	taken := map[string]bool{"local/addresses.env": true}
+
+	for _, volume := range service.OriginalService.Volumes {
+		parts := strings.Split(volume, ":")
+		if len(parts) < 2 || !isBindSource(parts[0]) {
+			continue
+		}
+
+		source := parts[0]
+		if !filepath.IsAbs(source) && service.SourceFile != "" {
+			source = filepath.Join(filepath.Dir(service.SourceFile), source)
+		}
+
+		info, err := os.Stat(source)
+		if err != nil || info.IsDir() || info.Size() > maxConfigTemplateSize {
+			continue
+		}
+		data, err := os.ReadFile(source)
+		if err != nil || strings.ContainsRune(string(data), 0) {
+			continue
+		}
+
+		// Template delimiters already in the file stay literal
+		content := escapeTemplateDelimiters(string(data))
+		references := findServiceReferences(content, names)
+		if len(references) == 0 {
+			continue
+		}
+
+		destination := templateDestination(source, taken)
+		taken[destination] = true
+		templates = append(templates, configFileTemplate{
+			Source:      parts[0],
+			Destination: destination,
+			Target:      parts[1],
+			ReadOnly:    len(parts) > 2 && strings.Contains(parts[2], "ro"),
+			Data:        rewrite(content, references),
+		})
+	}
+
+	return templates
+}
+
+// templateDestination picks a task directory path for a config file,
+// prefixing the parent directory, then a number, when the name is taken
+func templateDestination(source string, taken map[string]bool) string {
+	name := filepath.Base(source)
+	destination := "local/" + name
+	if !taken[destination] {
+		return destination
+	}
+	destination = "local/" + filepath.Base(filepath.Dir(source)) + "-" + name
+	for i := 2; taken[destination]; i++ {
+		destination = fmt.Sprintf("local/%d-%s", i, name)
+	}
+	return destination
+}
+
+// escapeTemplateDelimiters keeps {{ and }} literal when the text is rendered
+// by consul-template or Nomad
+func escapeTemplateDelimiters(value string) string {
+	return templateDelimiterEscaper.Replace(value)
+}
+
+var templateDelimiterEscaper = strings.NewReplacer("{{", `{{"{{"}}`, "}}", `{{"}}"}}`)
+
+// templateBlocks renders the env template and config file templates
+func (g *NomadGenerator) templateBlocks(plan addressPlan) []hclBlock {
+	var blocks []hclBlock
+
+	if len(plan.TemplateEnv) > 0 {
+		var data strings.Builder
+		for _, key := range sortedKeys(plan.TemplateEnv) {
+			data.WriteString(key + "=" + plan.TemplateEnv[key] + "\n")
+		}
+		blocks = append(blocks, hclBlock{
+			Header: "template",
+			Attrs: []hclAttribute{
+				{"data", heredoc(data.String())},
+				{"destination", quote("local/addresses.env")},
+				{"env", "true"},
+			},
+		})
+	}
+
+	for _, file := range plan.Files {
+		blocks = append(blocks, hclBlock{
+			Header: "template",
+			Attrs: []hclAttribute{
+				{"data", heredoc(file.Data)},
+				{"destination", quote(file.Destination)},
+			},
+		})
+	}
+
+	return blocks
+}
+
+// fileMounts mounts rendered config files where compose bind-mounted the originals
+func (g *NomadGenerator) fileMounts(plan addressPlan) []hclBlock {
+	var mounts []hclBlock
+	for _, file := range plan.Files {
+		mount := hclBlock{
+			Header: "mount",
+			Attrs: []hclAttribute{
+				{"type", quote("bind")},
+				{"source", quote(file.Destination)},
+				{"target", quote(file.Target)},
+			},
+		}
+		if file.ReadOnly {
+			mount.Attrs = append(mount.Attrs, hclAttribute{"readonly", "true"})
+		}
+		mounts = append(mounts, mount)
+	}
+	return mounts
+}
+
+// isBareHostname reports whether the reference is the whole value, without a port
+func isBareHostname(reference serviceReference, value string) bool {
+	return reference.Start == 0 && reference.End == len(value) && reference.Port == 0
+}
+
+// looksLikeHostKey reports whether an env var name suggests it holds an address
+func looksLikeHostKey(key string) bool {
+	key = strings.ToUpper(key)
+	for _, hint := range []string{"HOST", "ADDR", "SERVER", "URL", "URI", "ENDPOINT", "DSN", "BROKER", "UPSTREAM", "BACKEND"} {
+		if strings.Contains(key, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// addressVariable names the env var carrying a referenced address into args
+func addressVariable(reference serviceReference) string {
+	return "NOMPOSE_ADDR_" + strings.ToUpper(envName(reference.Service))
+}
+
+// isBindSource reports whether a volume source is a host path rather than a named volume
+func isBindSource(source string) bool {
+	return strings.HasPrefix(source, ".") || strings.HasPrefix(source, "/") || strings.HasPrefix(source, "~")
+}
+
+// heredoc renders multi-line content as an HCL heredoc, escaping HCL template sequences
+func heredoc(content string) string {
+	content = strings.ReplaceAll(content, "${", "$${")
+	content = strings.ReplaceAll(content, "%{", "%%{")
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+
+	marker := "EOH"
+	for strings.Contains(content, "\n"+marker+"\n") || strings.HasPrefix(content, marker+"\n") {
+		marker += "_"
+	}
+	return "<<" + marker + "\n" + content + marker
+}
@@ -0,0 +1,220 @@
+package generator
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+// Job types accepted in x-nomad.type
+var jobTypes = map[string]bool{"service": true, "batch": true, "system": true, "sysbatch": true}
+
+// singletonBlocks may appear once in a job, group or task, so an x-nomad.hcl
+// snippet can't add one the job already has
+var singletonBlocks = map[string]bool{
+	"config": true, "env": true, "ephemeral_disk": true, "lifecycle": true, "logs": true, "meta": true,
+	"migrate": true, "network": true, "parameterized": true, "periodic": true, "resources": true,
+	"reschedule": true, "restart": true, "update": true, "vault": true,
+}
+
+// hclItem matches an attribute or block at the start of a line
+var hclItem = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_-]*)\s*(=|\{|")`)
+
+// extension returns the service's x-nomad settings, merged with the top-level ones
+func (g *NomadGenerator) extension(service types.EnhancedServiceConfig) types.NomadExtension {
+	if service.OriginalService.XNomad == nil {
+		return types.NomadExtension{}
+	}
+	return *service.OriginalService.XNomad
+}
+
+// portLabel names the network port of a port mapping; x-nomad.ports wins
+// over the default "http" for the main port and port_<host> for the others,
+// port_<container> when the host port is dynamic
+func (g *NomadGenerator) portLabel(service types.EnhancedServiceConfig, index int) string {
+	if index >= len(service.ResolvedPorts) {
+		return "http"
+	}
+	port := service.ResolvedPorts[index]
+	if label := g.extension(service).Ports[strconv.Itoa(port.Container)]; label != "" {
+		return label
+	}
+	if index == 0 {
+		return "http"
+	}
+	if port.Host == 0 {
+		return fmt.Sprintf("port_%d", port.Container)
+	}
+	return fmt.Sprintf("port_%d", port.Host)
+}
+
+// applyExtensionPolicy applies x-nomad.type and x-nomad.update over the converted policy
+func (g *NomadGenerator) applyExtensionPolicy(policy *schedulingPolicy, service types.EnhancedServiceConfig) {
+	ext := g.extension(service)
+
+	if ext.Type != "" {
+		if jobTypes[ext.Type] {
+			policy.JobType = ext.Type
+			policy.Notes = append(policy.Notes, fmt.Sprintf("x-nomad.type → type = %q", ext.Type))
+		} else {
+			g.warn("%s: ignoring unknown x-nomad.type %q (supported: service, batch, system, sysbatch)", service.Name, ext.Type)
+		}
+	}
+
+	if policy.JobType == "system" || policy.JobType == "sysbatch" {
+		// Nomad rejects reschedule stanzas on system and sysbatch jobs
+		policy.Reschedule = nil
+	}
+
+	if ext.Periodic != nil && policy.JobType != "batch" && policy.JobType != "sysbatch" {
+		policy.JobType = "batch"
+		policy.Notes = append(policy.Notes, "x-nomad.periodic → type = \"batch\"")
+	}
+
+	if policy.JobType == "batch" || policy.JobType == "sysbatch" {
+		// Nomad rejects update stanzas on batch jobs
+		policy.Update = nil
+		return
+	}
+
+	update := ext.Update
+	if update == nil {
+		return
+	}
+	if policy.Update == nil {
+		policy.Update = &updatePolicy{MaxParallel: 1}
+	}
+	if update.MaxParallel > 0 {
+		policy.Update.MaxParallel = update.MaxParallel
+	}
+	policy.Update.Stagger = valueOrDefault(update.Stagger, policy.Update.Stagger)
+	policy.Update.MinHealthyTime = valueOrDefault(update.MinHealthyTime, policy.Update.MinHealthyTime)
+	policy.Update.HealthyDeadline = valueOrDefault(update.HealthyDeadline, policy.Update.HealthyDeadline)
+	policy.Update.ProgressDeadline = valueOrDefault(update.ProgressDeadline, policy.Update.ProgressDeadline)
+	if update.AutoRevert != nil {
+		policy.Update.AutoRevert = *update.AutoRevert
+	}
+	if update.Canary > 0 {
+		policy.Update.Canary = update.Canary
+	}
+	if update.AutoPromote != nil {
+		policy.Update.AutoPromote = *update.AutoPromote
+	}
+	policy.Notes = append(policy.Notes, "x-nomad.update → update stanza")
+}
+
+// constraintBlocks renders x-nomad.constraints
+func (g *NomadGenerator) constraintBlocks(service types.EnhancedServiceConfig) []hclBlock {
+	var blocks []hclBlock
+	for _, constraint := range g.extension(service).Constraints {
+		var attrs []hclAttribute
+		if constraint.Attribute != "" {
+			attrs = append(attrs, hclAttribute{"attribute", quote(constraint.Attribute)})
+		}
+		if constraint.Operator != "" {
+			attrs = append(attrs, hclAttribute{"operator", quote(constraint.Operator)})
+		}
+		if constraint.Value != "" {
+			attrs = append(attrs, hclAttribute{"value", quote(constraint.Value)})
+		}
+		blocks = append(blocks, hclBlock{Header: "constraint", Attrs: attrs})
+	}
+	return blocks
+}
+
+// periodicBlock schedules a batch job from x-nomad.periodic
+func (g *NomadGenerator) periodicBlock(service types.EnhancedServiceConfig) *hclBlock {
+	periodic := g.extension(service).Periodic
+	if periodic == nil {
+		return nil
+	}
+	if periodic.Cron == "" {
+		g.warn("%s: x-nomad.periodic has no cron schedule; the job isn't periodic", service.Name)
+		return nil
+	}
+
+	block := &hclBlock{Header: "periodic", Attrs: []hclAttribute{{"crons", quoteList([]string{periodic.Cron})}}}
+	if periodic.ProhibitOverlap {
+		block.Attrs = append(block.Attrs, hclAttribute{"prohibit_overlap", "true"})
+	}
+	if periodic.TimeZone != "" {
+		block.Attrs = append(block.Attrs, hclAttribute{"time_zone", quote(periodic.TimeZone)})
+	}
+	return block
+}
+
+// rawHCL indents an x-nomad.hcl snippet into a block body
+func rawHCL(indent, snippet string) string {
+	snippet = strings.TrimRight(snippet, "\n")
+	if strings.TrimSpace(snippet) == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(indent + "# x-nomad.hcl\n")
+	for _, line := range strings.Split(snippet, "\n") {
+		if strings.TrimSpace(line) == "" {
+			b.WriteString("\n")
+			continue
+		}
+		b.WriteString(indent + line + "\n")
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// checkRawHCL rejects x-nomad.hcl snippets that set an attribute or a
+// single-use block the generated job already has at the same level
+func (g *NomadGenerator) checkRawHCL(service types.EnhancedServiceConfig, job string) error {
+	ext := g.extension(service)
+	levels := []struct {
+		name, snippet, body, indent string
+	}{
+		{"job", ext.HCL.Job, job, "  "},
+		{"group", ext.HCL.Group, blockBody(job, "  group "), "    "},
+		{"task", ext.HCL.Task, blockBody(job, "    task \"app\" "), "      "},
+	}
+	for _, level := range levels {
+		generated := hclItems(level.body, level.indent)
+		for name, count := range hclItems(level.snippet, "") {
+			if generated[name] > count {
+				return fmt.Errorf("x-nomad.hcl.%s sets %s, which the job already generates; set it through x-nomad or drop it from the snippet", level.name, name)
+			}
+		}
+	}
+	return nil
+}
+
+// hclItems counts the attributes and single-use blocks written at exactly indent
+func hclItems(text, indent string) map[string]int {
+	items := make(map[string]int)
+	for _, line := range strings.Split(text, "\n") {
+		rest, ok := strings.CutPrefix(line, indent)
+		if !ok {
+			continue
+		}
+		match := hclItem.FindStringSubmatch(rest)
+		if match == nil || (match[2] != "=" && !singletonBlocks[match[1]]) {
+			continue
+		}
+		items[match[1]]++
+	}
+	return items
+}
+
+// blockBody returns the lines of the first block opened by header, up to its closing brace
+func blockBody(job, header string) string {
+	start := strings.Index(job, "\n"+header)
+	if start < 0 {
+		return ""
+	}
+	indent := header[:len(header)-len(strings.TrimLeft(header, " "))]
+	body := job[start+1:]
+	if end := strings.Index(body, "\n"+indent+"}\n"); end >= 0 {
+		body = body[:end]
+	}
+	return body
+}
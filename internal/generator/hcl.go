@@ -0,0 +1,119 @@
+package generator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// runtimeInterpolation matches the references Nomad resolves when it places a
+// task: NOMAD_* variables, nompose's own NOMPOSE_* variables, node attributes
+// and metadata. quote keeps them; any other ${ or %{ is escaped.
+var runtimeInterpolation = regexp.MustCompile(`\$\{(?:NOMAD_\w+|NOMPOSE_\w+|attr\.[^}"]+|node\.[^}"]+|meta\.[^}"]+)\}`)
+
+// hclAttribute is a single `key = value` line, value already rendered as HCL
+type hclAttribute struct {
+	Key   string
+	Value string
+}
+
+// hclBlock is a block with attributes and nested blocks
+type hclBlock struct {
+	Header string
+	Attrs  []hclAttribute
+	Blocks []hclBlock
+}
+
+// writeBlock writes a block with its attributes aligned on the equals sign
+func writeBlock(b *strings.Builder, indent, header string, attrs []hclAttribute) {
+	writeNestedBlock(b, indent, hclBlock{Header: header, Attrs: attrs})
+	b.WriteString("\n")
+}
+
+// writeNestedBlock writes a block, its attributes and its nested blocks,
+// separating nested blocks with a blank line
+func writeNestedBlock(b *strings.Builder, indent string, block hclBlock) {
+	b.WriteString(indent + block.Header + " {\n")
+	writeAttributes(b, indent+"  ", block.Attrs)
+	for i, nested := range block.Blocks {
+		if i > 0 || len(block.Attrs) > 0 {
+			b.WriteString("\n")
+		}
+		writeNestedBlock(b, indent+"  ", nested)
+	}
+	b.WriteString(indent + "}\n")
+}
+
+// writeAttributes writes attributes aligned on the equals sign
+func writeAttributes(b *strings.Builder, indent string, attrs []hclAttribute) {
+	width := 0
+	for _, attr := range attrs {
+		if len(attr.Key) > width {
+			width = len(attr.Key)
+		}
+	}
+	for _, attr := range attrs {
+		b.WriteString(indent + attr.Key + strings.Repeat(" ", width-len(attr.Key)) + " = " + attr.Value + "\n")
+	}
+}
+
+// quote renders a string as an HCL string literal. Template sequences are
+// escaped so user values stay literal, and non-printable characters use the
+// \uXXXX escapes HCL understands.
+func quote(value string) string {
+	keep := make(map[int]int)
+	for _, match := range runtimeInterpolation.FindAllStringIndex(value, -1) {
+		keep[match[0]] = match[1]
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(value); {
+		if end, ok := keep[i]; ok {
+			b.WriteString(value[i:end])
+			i = end
+			continue
+		}
+		if strings.HasPrefix(value[i:], "${") || strings.HasPrefix(value[i:], "%{") {
+			b.WriteString(value[i:i+1] + value[i:i+2])
+			i += 2
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(value[i:])
+		i += size
+		switch {
+		case r == '"':
+			b.WriteString(`\"`)
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == utf8.RuneError && size == 1:
+			b.WriteString(`\ufffd`)
+		case unicode.IsPrint(r):
+			b.WriteRune(r)
+		case r > 0xFFFF:
+			fmt.Fprintf(&b, `\U%08x`, r)
+		default:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// quoteList renders strings as an HCL list literal
+func quoteList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = quote(value)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
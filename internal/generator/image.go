@@ -0,0 +1,26 @@
+package generator
+
+import (
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/image"
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+// checkImage warns about images that aren't valid references or that
+// float on :latest, so redeploys could run a different image
+func (g *NomadGenerator) checkImage(service types.EnhancedServiceConfig) {
+	if service.ResolvedImage == "" || strings.HasPrefix(service.ResolvedImage, "{{") {
+		return
+	}
+
+	ref, err := image.ParseReference(service.ResolvedImage)
+	switch {
+	case err != nil:
+		g.warn("%s: %v", service.Name, err)
+	case ref.Untagged():
+		g.warn("%s: image %s has no tag and pulls :latest; pin a version or digest for repeatable deploys", service.Name, service.ResolvedImage)
+	case ref.Mutable():
+		g.warn("%s: image %s uses the :latest tag; pin a version or digest for repeatable deploys", service.Name, service.ResolvedImage)
+	}
+}
@@ -0,0 +1,187 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+// Supported ingress modes
+const (
+	IngressTraefik       = "traefik"        // traefik.* service tags
+	IngressFabio         = "fabio"          // urlprefix- service tags
+	IngressConsulGateway = "consul-gateway" // Consul API gateway config entries
+)
+
+// Name of the Consul API gateway routes attach to
+const apiGatewayName = "api-gateway"
+
+// ingressTags returns the service tags that expose a service through the ingress
+func (g *NomadGenerator) ingressTags(service types.EnhancedServiceConfig) []string {
+	switch g.options.IngressMode {
+	case IngressTraefik:
+		return g.traefikTags(service)
+	case IngressFabio:
+		return g.fabioTags(service)
+	}
+	return nil
+}
+
+// traefikTags converts compose traefik.* labels into tags, adding a router
+// rule for the confirmed host/path when the labels don't define one. Services
+// with neither an ingress nor traefik labels aren't exposed.
+func (g *NomadGenerator) traefikTags(service types.EnhancedServiceConfig) []string {
+	labels := service.OriginalService.Labels
+	if labels["traefik.enable"] == "false" {
+		return nil
+	}
+
+	var labelTags []string
+	hasRule := false
+	for _, key := range sortedKeys(labels) {
+		if !strings.HasPrefix(key, "traefik.") || key == "traefik.enable" {
+			continue
+		}
+		if strings.HasPrefix(key, "traefik.http.routers.") && strings.HasSuffix(key, ".rule") {
+			hasRule = true
+		}
+		labelTags = append(labelTags, key+"="+labels[key])
+	}
+
+	_, enabled := labels["traefik.enable"]
+	if service.Ingress == nil && len(labelTags) == 0 && !enabled {
+		return nil
+	}
+	tags := append([]string{"traefik.enable=true"}, labelTags...)
+
+	if !hasRule && service.Ingress != nil {
+		var rules []string
+		if service.Ingress.Host != "" {
+			rules = append(rules, fmt.Sprintf("Host(`%s`)", service.Ingress.Host))
+		}
+		if service.Ingress.Path != "" && service.Ingress.Path != "/" {
+			rules = append(rules, fmt.Sprintf("PathPrefix(`%s`)", service.Ingress.Path))
+		}
+		if len(rules) > 0 {
+			tags = append(tags, fmt.Sprintf("traefik.http.routers.%s.rule=%s", service.Name, strings.Join(rules, " && ")))
+		}
+	}
+
+	return tags
+}
+
+// fabioTags builds a urlprefix- tag from the confirmed host and path
+func (g *NomadGenerator) fabioTags(service types.EnhancedServiceConfig) []string {
+	if service.Ingress == nil || (service.Ingress.Host == "" && service.Ingress.Path == "") {
+		return nil
+	}
+	return []string{"urlprefix-" + service.Ingress.Host + ingressPath(service.Ingress)}
+}
+
+// recordGatewayRoute remembers a service routed through the Consul API gateway
+func (g *NomadGenerator) recordGatewayRoute(service types.EnhancedServiceConfig) {
+	if g.options.IngressMode != IngressConsulGateway || service.Ingress == nil {
+		return
+	}
+	g.gatewayRoutes = append(g.gatewayRoutes, service)
+
+	// The gateway is a mesh client of every routed service
+	if g.intentions == nil {
+		g.intentions = make(map[string][]string)
+	}
+	g.intentions[service.Name] = append(g.intentions[service.Name], apiGatewayName)
+}
+
+// writeGatewayConfig writes the api-gateway config entry and one http-route per service
+func (g *NomadGenerator) writeGatewayConfig() ([]string, error) {
+	if len(g.gatewayRoutes) == 0 {
+		return nil, nil
+	}
+
+	var gateway strings.Builder
+	gateway.WriteString("# Generated by Nompose - Consul API gateway\n")
+	gateway.WriteString("# Apply with: consul config write " + apiGatewayName + ".hcl\n\n")
+	writeAttributes(&gateway, "", []hclAttribute{
+		{"Kind", quote("api-gateway")},
+		{"Name", quote(apiGatewayName)},
+	})
+	gateway.WriteString("Listeners = [\n  {\n")
+	writeAttributes(&gateway, "    ", []hclAttribute{
+		{"Name", quote("http")},
+		{"Port", "8080"},
+		{"Protocol", quote("http")},
+	})
+	gateway.WriteString("  },\n]\n")
+
+	filename, err := g.writeFile(apiGatewayName+".hcl", gateway.String())
+	if err != nil {
+		return nil, err
+	}
+	files := []string{filename}
+
+	routes := append([]types.EnhancedServiceConfig{}, g.gatewayRoutes...)
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Name < routes[j].Name })
+
+	for _, service := range routes {
+		var route strings.Builder
+		route.WriteString("# Generated by Nompose - HTTP route for " + service.Name + "\n")
+		route.WriteString("# Apply with: consul config write " + service.Name + ".http-route.hcl\n\n")
+		writeAttributes(&route, "", []hclAttribute{
+			{"Kind", quote("http-route")},
+			{"Name", quote(service.Name)},
+		})
+		if service.Ingress.Host != "" {
+			writeAttributes(&route, "", []hclAttribute{{"Hostnames", quoteList([]string{service.Ingress.Host})}})
+		}
+		route.WriteString("\nParents = [\n  {\n")
+		writeAttributes(&route, "    ", []hclAttribute{
+			{"Kind", quote("api-gateway")},
+			{"Name", quote(apiGatewayName)},
+			{"SectionName", quote("http")},
+		})
+		route.WriteString("  },\n]\n\nRules = [\n  {\n")
+		route.WriteString("    Matches = [\n      {\n        Path = {\n")
+		writeAttributes(&route, "          ", []hclAttribute{
+			{"Match", quote("prefix")},
+			{"Value", quote(ingressPath(service.Ingress))},
+		})
+		route.WriteString("        }\n      },\n    ]\n")
+		route.WriteString("    Services = [\n      {\n")
+		writeAttributes(&route, "        ", []hclAttribute{{"Name", quote(service.Name)}})
+		route.WriteString("      },\n    ]\n  },\n]\n")
+
+		filename, err := g.writeFile(service.Name+".http-route.hcl", route.String())
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, filename)
+
+		// HTTP routes only attach to services speaking the http protocol
+		var defaults strings.Builder
+		defaults.WriteString("# Generated by Nompose - service defaults for " + service.Name + "\n")
+		defaults.WriteString("# Apply with: consul config write " + service.Name + ".service-defaults.hcl\n\n")
+		writeAttributes(&defaults, "", []hclAttribute{
+			{"Kind", quote("service-defaults")},
+			{"Name", quote(service.Name)},
+			{"Protocol", quote("http")},
+		})
+
+		filename, err = g.writeFile(service.Name+".service-defaults.hcl", defaults.String())
+		if err != nil {
+			return nil, err
+		}
+		// Defaults must exist before the route referencing them
+		files = append(files[:len(files)-1], filename, files[len(files)-1])
+	}
+
+	return files, nil
+}
+
+func ingressPath(ingress *types.IngressRoute) string {
+	if ingress.Path == "" {
+		return "/"
+	}
+	return ingress.Path
+}
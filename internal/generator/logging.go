@@ -0,0 +1,90 @@
+package generator
+
+import (
+	"strconv"
+
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+// Drivers whose rotation settings map onto Nomad's own log collection
+var rotatingLogDrivers = map[string]bool{
+	"":          true,
+	"json-file": true,
+	"local":     true,
+}
+
+// effectiveLogging returns the logging config for a service, letting the
+// global --log-driver option take precedence over the compose file
+func (g *NomadGenerator) effectiveLogging(service types.EnhancedServiceConfig) *types.LoggingConfig {
+	serviceLogging := service.OriginalService.Logging
+
+	if g.options.LogDriver == "" {
+		return serviceLogging
+	}
+
+	if serviceLogging != nil && serviceLogging.Driver != "" && serviceLogging.Driver != g.options.LogDriver {
+		g.warn("%s: logging driver %q replaced by the global %q driver", service.Name, serviceLogging.Driver, g.options.LogDriver)
+	}
+	return &types.LoggingConfig{
+		Driver:  g.options.LogDriver,
+		Options: g.options.LogOptions,
+	}
+}
+
+// logsBlock maps json-file/local max-size and max-file onto the task logs stanza
+func (g *NomadGenerator) logsBlock(service types.EnhancedServiceConfig, logging *types.LoggingConfig) *hclBlock {
+	if logging == nil || !rotatingLogDrivers[logging.Driver] {
+		return nil
+	}
+
+	var attrs []hclAttribute
+
+	if maxFile := logging.Options["max-file"]; maxFile != "" {
+		if files, err := strconv.Atoi(maxFile); err == nil {
+			attrs = append(attrs, hclAttribute{"max_files", strconv.Itoa(files)})
+		} else {
+			g.warn("%s: ignoring logging max-file %q", service.Name, maxFile)
+		}
+	}
+
+	if maxSize := logging.Options["max-size"]; maxSize != "" {
+		if size, err := parseBytes(maxSize); err == nil {
+			// Nomad counts in whole megabytes
+			megabytes := (size + (1<<20 - 1)) >> 20
+			if megabytes < 1 {
+				megabytes = 1
+			}
+			attrs = append(attrs, hclAttribute{"max_file_size", strconv.FormatInt(megabytes, 10)})
+		} else {
+			g.warn("%s: ignoring logging max-size: %v", service.Name, err)
+		}
+	}
+
+	if len(attrs) == 0 {
+		return nil
+	}
+	return &hclBlock{Header: "logs", Attrs: attrs}
+}
+
+// dockerLoggingBlock passes other drivers (syslog, fluentd, gelf, journald, ...)
+// through to the docker driver's logging block
+func (g *NomadGenerator) dockerLoggingBlock(logging *types.LoggingConfig) *hclBlock {
+	if logging == nil || rotatingLogDrivers[logging.Driver] {
+		return nil
+	}
+
+	block := &hclBlock{
+		Header: "logging",
+		Attrs:  []hclAttribute{{"type", quote(logging.Driver)}},
+	}
+
+	if len(logging.Options) > 0 {
+		config := hclBlock{Header: "config"}
+		for _, key := range sortedKeys(logging.Options) {
+			config.Attrs = append(config.Attrs, hclAttribute{key, quote(logging.Options[key])})
+		}
+		block.Blocks = append(block.Blocks, config)
+	}
+
+	return block
+}
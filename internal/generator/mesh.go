@@ -0,0 +1,227 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+// Supported service mesh modes
+const (
+	MeshConsulConnect = "consul-connect"
+)
+
+// Upstream address styles used when rewriting hostnames for the mesh
+const (
+	UpstreamAddressLocalhost = "localhost" // localhost:<local_bind_port>
+	UpstreamAddressEnv       = "env"       // ${NOMAD_UPSTREAM_ADDR_<service>}
+)
+
+// meshUpstream is a Connect upstream for one destination service
+type meshUpstream struct {
+	Service       string
+	LocalBindPort int
+}
+
+func (g *NomadGenerator) meshEnabled() bool {
+	return g.options.Mesh == MeshConsulConnect
+}
+
+// meshUpstreams returns an upstream for every dependency and every service
+// referenced by host:port in the environment, each on its own local port.
+// A referenced port is kept as the local port so rewritten URLs change as
+// little as possible.
+func (g *NomadGenerator) meshUpstreams(service types.EnhancedServiceConfig) []meshUpstream {
+	destinations := make(map[string]int)
+	for _, dependency := range service.Dependencies {
+		if _, ok := g.catalog[dependency]; ok {
+			destinations[dependency] = 0
+		}
+	}
+	for _, key := range sortedKeys(service.Environment) {
+		for _, reference := range findServiceReferences(service.Environment[key], g.otherServiceNames(service)) {
+			if reference.Port > 0 && destinations[reference.Service] == 0 {
+				destinations[reference.Service] = reference.Port
+			}
+		}
+	}
+
+	names := make([]string, 0, len(destinations))
+	for name := range destinations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// The service's own ports are bound inside the same network namespace
+	used := make(map[int]bool)
+	for _, port := range service.ResolvedPorts {
+		used[port.Container] = true
+	}
+
+	var upstreams []meshUpstream
+	for _, name := range names {
+		port := destinations[name]
+		if port == 0 {
+			port = g.upstreamPort(g.catalog[name])
+		}
+		for used[port] {
+			port++
+		}
+		used[port] = true
+		upstreams = append(upstreams, meshUpstream{Service: name, LocalBindPort: port})
+	}
+	return upstreams
+}
+
+// upstreamPort picks the local port for a destination no one references by
+// port, matching the port it serves on
+func (g *NomadGenerator) upstreamPort(destination types.EnhancedServiceConfig) int {
+	if port := g.meshPort(destination); port > 0 {
+		return port
+	}
+	g.warn("%s: no port detected, its Connect upstream uses local port 8080", destination.Name)
+	return 8080
+}
+
+// meshPort is the port a service's Connect sidecar forwards to: its first
+// published port, else its first expose port, else the port other services
+// reach it on; 0 when there is none
+func (g *NomadGenerator) meshPort(service types.EnhancedServiceConfig) int {
+	if len(service.ResolvedPorts) > 0 {
+		return service.ResolvedPorts[0].Container
+	}
+	for _, expose := range service.OriginalService.Expose {
+		if port, err := strconv.Atoi(strings.SplitN(expose, "/", 2)[0]); err == nil && port > 0 {
+			return port
+		}
+	}
+	for _, other := range g.services {
+		if other.Name == service.Name {
+			continue
+		}
+		for _, key := range sortedKeys(other.Environment) {
+			for _, reference := range findServiceReferences(other.Environment[key], []string{service.Name}) {
+				if reference.Port > 0 {
+					return reference.Port
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// rewriteForMesh replaces upstream hostnames with their local Connect listener
+func (g *NomadGenerator) rewriteForMesh(value string, references []serviceReference, upstreams []meshUpstream) string {
+	bindPorts := make(map[string]int)
+	for _, upstream := range upstreams {
+		bindPorts[upstream.Service] = upstream.LocalBindPort
+	}
+
+	return rewriteServiceReferences(value, references, func(reference serviceReference) string {
+		withPort := reference.Port > 0 || reference.End < len(value) || reference.Start > 0
+
+		if g.options.UpstreamAddr == UpstreamAddressEnv {
+			if withPort {
+				return "${NOMAD_UPSTREAM_ADDR_" + envName(reference.Service) + "}"
+			}
+			return "${NOMAD_UPSTREAM_IP_" + envName(reference.Service) + "}"
+		}
+
+		if withPort {
+			return fmt.Sprintf("localhost:%d", bindPorts[reference.Service])
+		}
+		return "localhost"
+	})
+}
+
+// connectBlock renders the sidecar and its upstreams
+func (g *NomadGenerator) connectBlock(upstreams []meshUpstream) hclBlock {
+	proxy := hclBlock{Header: "proxy"}
+	for _, upstream := range upstreams {
+		proxy.Blocks = append(proxy.Blocks, hclBlock{
+			Header: "upstreams",
+			Attrs: []hclAttribute{
+				{"destination_name", quote(upstream.Service)},
+				{"local_bind_port", fmt.Sprintf("%d", upstream.LocalBindPort)},
+			},
+		})
+	}
+
+	sidecar := hclBlock{Header: "sidecar_service"}
+	if len(proxy.Blocks) > 0 {
+		sidecar.Blocks = append(sidecar.Blocks, proxy)
+	}
+
+	return hclBlock{Header: "connect", Blocks: []hclBlock{sidecar}}
+}
+
+// recordIntentions remembers which sources may call each destination
+func (g *NomadGenerator) recordIntentions(service types.EnhancedServiceConfig, upstreams []meshUpstream) {
+	if g.intentions == nil {
+		g.intentions = make(map[string][]string)
+	}
+	for _, upstream := range upstreams {
+		g.intentions[upstream.Service] = append(g.intentions[upstream.Service], service.Name)
+	}
+}
+
+// writeIntentions writes one Consul service-intentions config entry per destination
+func (g *NomadGenerator) writeIntentions() ([]string, error) {
+	destinations := make([]string, 0, len(g.intentions))
+	for destination := range g.intentions {
+		destinations = append(destinations, destination)
+	}
+	sort.Strings(destinations)
+
+	var files []string
+	for _, destination := range destinations {
+		var entry strings.Builder
+		entry.WriteString("# Generated by Nompose - Consul intentions for " + destination + "\n")
+		entry.WriteString("# Apply with: consul config write " + destination + ".intentions.hcl\n\n")
+		writeAttributes(&entry, "", []hclAttribute{
+			{"Kind", quote("service-intentions")},
+			{"Name", quote(destination)},
+		})
+		entry.WriteString("Sources = [\n")
+		for _, source := range g.intentions[destination] {
+			entry.WriteString("  {\n")
+			writeAttributes(&entry, "    ", []hclAttribute{
+				{"Name", quote(source)},
+				{"Action", quote("allow")},
+			})
+			entry.WriteString("  },\n")
+		}
+		entry.WriteString("]\n")
+
+		filename, err := g.writeFile(destination+".intentions.hcl", entry.String())
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, filename)
+	}
+	return files, nil
+}
+
+// otherServiceNames lists every generated service except the given one
+func (g *NomadGenerator) otherServiceNames(service types.EnhancedServiceConfig) []string {
+	var names []string
+	for _, other := range g.services {
+		if other.Name != service.Name {
+			names = append(names, other.Name)
+		}
+	}
+	return names
+}
+
+// envName sanitizes a service name the way Nomad does for environment variables
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
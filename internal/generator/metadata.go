@@ -0,0 +1,66 @@
+package generator
+
+import (
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+// jobMeta returns the nompose.* metadata tracing a job back to its source
+func (g *NomadGenerator) jobMeta(service types.EnhancedServiceConfig) map[string]string {
+	meta := map[string]string{"nompose.service": service.Name}
+	if service.SourceFile != "" {
+		meta["nompose.source_file"] = service.SourceFile
+	}
+	if service.SourceHash != "" {
+		meta["nompose.source_hash"] = service.SourceHash
+	}
+	if service.SourceRepo != "" {
+		meta["nompose.source_repo"] = service.SourceRepo
+	}
+	if service.SourceCommit != "" {
+		meta["nompose.source_commit"] = service.SourceCommit
+	}
+	return meta
+}
+
+// labelTags turns labels matching a --label-tag-prefix rule into service tags
+func (g *NomadGenerator) labelTags(service types.EnhancedServiceConfig) []string {
+	labels := service.OriginalService.Labels
+	var tags []string
+
+	for _, key := range sortedKeys(labels) {
+		for _, prefix := range g.options.LabelTagPrefixes {
+			if prefix == "" || !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			// traefik.* labels are already tags in traefik ingress mode
+			if g.options.IngressMode == IngressTraefik && strings.HasPrefix(key, "traefik.") {
+				break
+			}
+			if labels[key] == "" {
+				tags = append(tags, key)
+			} else {
+				tags = append(tags, key+"="+labels[key])
+			}
+			break
+		}
+	}
+
+	return tags
+}
+
+// hclMap renders a map as an HCL map expression; keys are quoted so labels
+// like com.example.team stay valid
+func hclMap(indent string, values map[string]string) string {
+	var b strings.Builder
+	attrs := make([]hclAttribute, 0, len(values))
+	for _, key := range sortedKeys(values) {
+		attrs = append(attrs, hclAttribute{quote(key), quote(values[key])})
+	}
+
+	b.WriteString("{\n")
+	writeAttributes(&b, indent+"  ", attrs)
+	b.WriteString(indent + "}")
+	return b.String()
+}
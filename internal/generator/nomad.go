@@ -2,6 +2,7 @@ package generator
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -12,26 +13,58 @@ import (
 // NomadGenerator creates Nomad job files
 type NomadGenerator struct {
 	outputDir string
+	options   types.GenerateOptions
+	warnings  []string
+	plugin    pluginRequirements
+
+	services   []types.EnhancedServiceConfig          // Every service in this run
+	catalog    map[string]types.EnhancedServiceConfig // Services by name
+	intentions map[string][]string                    // Mesh destination → allowed sources
+
+	gatewayRoutes []types.EnhancedServiceConfig // Services routed through the Consul API gateway
+
+	progress io.Writer       // Progress and summaries, stdout unless set by ReportTo
+	stream   io.Writer       // Set by StreamTo; files go here instead of outputDir
+	pending  []generatedFile // Files written by flush once every job is generated
 }
 
 // NewNomadGenerator creates a new Nomad job generator
-func NewNomadGenerator(outputDir string) *NomadGenerator {
+func NewNomadGenerator(outputDir string, options types.GenerateOptions) *NomadGenerator {
 	if outputDir == "" {
 		outputDir = "."
 	}
 	return &NomadGenerator{
 		outputDir: outputDir,
+		options:   options,
+		progress:  os.Stdout,
 	}
 }
 
+// ReportTo sends progress and summaries to w instead of stdout
+func (g *NomadGenerator) ReportTo(w io.Writer) {
+	g.progress = w
+}
+
 // GenerateJobs creates enhanced Nomad job files for multiple services
 func (g *NomadGenerator) GenerateJobs(services []types.EnhancedServiceConfig) error {
-	fmt.Printf("📝 Generating production-ready Nomad job files...\n")
+	fmt.Fprintf(g.progress, "📝 Generating production-ready Nomad job files...\n")
 
 	var generatedFiles []string
+	g.warnings = nil
+	g.plugin = pluginRequirements{}
+	g.intentions = nil
+	g.gatewayRoutes = nil
+	g.pending = nil
+	services = g.applyOverrides(services)
+	g.services = services
+	g.catalog = make(map[string]types.EnhancedServiceConfig)
+	for _, service := range services {
+		g.catalog[service.Name] = service
+	}
 
 	for i, service := range services {
-		fmt.Printf("Processing service %d/%d: %s\n", i+1, len(services), service.Name)
+		fmt.Fprintf(g.progress, "Processing service %d/%d: %s\n", i+1, len(services), service.Name)
+		g.checkImage(service)
 
 		filename, err := g.generateEnhancedJob(service)
 		if err != nil {
@@ -40,41 +73,125 @@ func (g *NomadGenerator) GenerateJobs(services []types.EnhancedServiceConfig) er
 		generatedFiles = append(generatedFiles, filename)
 	}
 
-	// Show comprehensive summary
-	fmt.Printf("✅ Generated %d Nomad job files:\n", len(generatedFiles))
-	for i, file := range generatedFiles {
-		fmt.Printf("   %d. %s\n", i+1, file)
+	var consulFiles []string
+	if g.meshEnabled() {
+		gatewayFiles, err := g.writeGatewayConfig()
+		if err != nil {
+			return fmt.Errorf("failed to write Consul API gateway config: %w", err)
+		}
+		intentionFiles, err := g.writeIntentions()
+		if err != nil {
+			return fmt.Errorf("failed to write Consul intentions: %w", err)
+		}
+		consulFiles = append(gatewayFiles, intentionFiles...)
+	}
+
+	buildFiles, err := g.writeBuildFiles()
+	if err != nil {
+		return fmt.Errorf("failed to write build files: %w", err)
+	}
+
+	if err := g.flush(); err != nil {
+		return err
+	}
+
+	// Show comprehensive summary; a dry run already listed the files
+	if !g.options.DryRun {
+		fmt.Fprintf(g.progress, "✅ Generated %d Nomad job files:\n", len(generatedFiles))
+		for i, file := range generatedFiles {
+			fmt.Fprintf(g.progress, "   %d. %s\n", i+1, file)
+		}
+	}
+
+	if len(g.warnings) > 0 {
+		fmt.Fprintf(g.progress, "\n⚠️  Warnings (%d):\n", len(g.warnings))
+		for _, warning := range g.warnings {
+			fmt.Fprintf(g.progress, "   - %s\n", warning)
+		}
+	}
+
+	if !g.plugin.empty() {
+		g.plugin.print(g.progress)
 	}
 
-	fmt.Println("\n🚀 Next steps:")
-	fmt.Println("   Deploy services:")
+	if g.stream != nil || g.options.DryRun {
+		return nil
+	}
+
+	fmt.Fprintln(g.progress, "\n🚀 Next steps:")
+	if len(consulFiles) > 0 {
+		fmt.Fprintln(g.progress, "   Apply Consul config entries:")
+		for _, file := range consulFiles {
+			fmt.Fprintf(g.progress, "   consul config write %s\n", filepath.Join(g.outputDir, file))
+		}
+	}
+	if len(buildFiles) > 0 {
+		fmt.Fprintln(g.progress, "   Build and push images:")
+		fmt.Fprintf(g.progress, "   PUSH=1 %s\n", filepath.Join(g.outputDir, buildScriptFile))
+		if len(buildFiles) > 1 {
+			fmt.Fprintf(g.progress, "   (or: docker buildx bake --push -f %s)\n", filepath.Join(g.outputDir, bakeFile))
+		}
+	}
+	fmt.Fprintln(g.progress, "   Deploy services:")
 	for _, file := range generatedFiles {
-		fmt.Printf("   nomad job run %s\n", file)
+		fmt.Fprintf(g.progress, "   nomad job run %s\n", filepath.Join(g.outputDir, file))
 	}
 
 	return nil
 }
 
+// warn records a warning that is shown once all jobs are generated
+func (g *NomadGenerator) warn(format string, args ...interface{}) {
+	warning := fmt.Sprintf(format, args...)
+	for _, existing := range g.warnings {
+		if existing == warning {
+			return
+		}
+	}
+	g.warnings = append(g.warnings, warning)
+}
+
 // generateEnhancedJob creates an enhanced Nomad job file
 func (g *NomadGenerator) generateEnhancedJob(service types.EnhancedServiceConfig) (string, error) {
 	// Generate enhanced job content
 	jobContent := g.createEnhancedJobContent(service)
+	if err := g.checkRawHCL(service, jobContent); err != nil {
+		return "", err
+	}
 
 	// Create filename with .nomad.hcl extension
 	filename := fmt.Sprintf("%s.nomad.hcl", service.Name)
-	filepath := filepath.Join(g.outputDir, filename)
 
-	// Write file
-	if err := os.WriteFile(filepath, []byte(jobContent), 0644); err != nil {
-		return "", fmt.Errorf("failed to write job file: %w", err)
+	return g.writeFile(filename, jobContent)
+}
+
+// writeFile queues a generated file for the output directory, adding the
+// checksum header; files are written by flush once every job is generated
+func (g *NomadGenerator) writeFile(filename, content string) (string, error) {
+	for _, file := range g.pending {
+		if file.Name == filename {
+			return "", fmt.Errorf("%s is generated twice", filename)
+		}
 	}
 
+	g.pending = append(g.pending, generatedFile{Name: filename, Content: withChecksum(content)})
 	return filename, nil
 }
 
 // createEnhancedJobContent generates enhanced HCL content
 func (g *NomadGenerator) createEnhancedJobContent(service types.EnhancedServiceConfig) string {
 	var content strings.Builder
+	ext := g.extension(service)
+	policy := g.buildSchedulingPolicy(service)
+	logging := g.effectiveLogging(service)
+
+	var upstreams []meshUpstream
+	if g.meshEnabled() {
+		upstreams = g.meshUpstreams(service)
+		g.recordIntentions(service, upstreams)
+		g.recordGatewayRoute(service)
+	}
+	addresses := g.planAddresses(service, upstreams)
 
 	// Job header with metadata
 	content.WriteString(fmt.Sprintf(`# Generated by Nompose - Production Ready
@@ -82,62 +199,112 @@ func (g *NomadGenerator) createEnhancedJobContent(service types.EnhancedServiceC
 # Image: %s
 # Ports: %s
 # Environment: %d variables
+`,
+		service.Name,
+		service.ResolvedImage,
+		g.getPortsDescription(service),
+		len(service.Environment)))
 
-job "%s" {
-  datacenters = ["dc1"]
-  type        = "service"
+	for _, note := range policy.Notes {
+		content.WriteString("# Policy: " + note + "\n")
+	}
+	for _, note := range addresses.Notes {
+		content.WriteString("# Addresses: " + note + "\n")
+	}
 
+	content.WriteString("\njob " + quote(service.Name) + " {\n")
+	writeAttributes(&content, "  ", append([]hclAttribute{
+		{"datacenters", quoteList(g.datacenters(service))},
+		{"type", quote(policy.JobType)},
+	}, g.jobPlacement(service)...))
+	content.WriteString("\n")
+
+	// Trace the job back to its source
+	writeAttributes(&content, "  ", []hclAttribute{{"meta", hclMap("  ", g.jobMeta(service))}})
+
+	for _, constraint := range g.constraintBlocks(service) {
+		content.WriteString("\n")
+		writeNestedBlock(&content, "  ", constraint)
+	}
+
+	if periodic := g.periodicBlock(service); periodic != nil {
+		content.WriteString("\n")
+		writeNestedBlock(&content, "  ", *periodic)
+	}
+
+	content.WriteString(fmt.Sprintf(`
   group "%s" {
     count = %d
 
 `,
-		service.Name,
-		service.ResolvedImage,
-		g.getPortsDescription(service),
-		len(service.Environment),
-		service.Name,
 		service.Name,
 		g.getReplicas(service)))
 
+	// Compose labels are available to the task as NOMAD_META_*
+	if labels := service.OriginalService.Labels; len(labels) > 0 {
+		writeAttributes(&content, "    ", []hclAttribute{{"meta", hclMap("    ", labels)}})
+		content.WriteString("\n")
+	}
+
+	// Restart, reschedule and update policies
+	content.WriteString(g.generatePolicyConfig(policy))
+
 	// Enhanced network configuration with multiple ports
 	content.WriteString(g.generateNetworkConfig(service))
 
-	// Task configuration
-	content.WriteString(`    task "app" {
-      driver = "docker"
-
-      config {
-        image = "` + service.ResolvedImage + `"`)
+	// Named volumes
+	for _, volume := range g.groupVolumeBlocks(service) {
+		writeNestedBlock(&content, "    ", volume)
+		content.WriteString("\n")
+	}
 
-	// Add all ports
-	if len(service.ResolvedPorts) > 0 {
-		content.WriteString(`
-        ports = [` + g.getPortNames(service) + `]`)
+	// Connect services must be registered at the group level
+	if g.meshEnabled() && (g.meshPort(service) > 0 || len(upstreams) > 0) {
+		content.WriteString(g.generateServiceConfig(service, "    ", upstreams))
 	}
 
-	content.WriteString(`
-      }
+	// Task configuration
+	content.WriteString(g.generateTaskHeader(service))
+	content.WriteString(g.generateDockerConfig(service, logging, addresses))
+
+	for _, block := range append(g.volumeMountBlocks(service), g.integrationBlocks()...) {
+		writeNestedBlock(&content, "      ", block)
+		content.WriteString("\n")
+	}
 
-      resources {
-        cpu    = ` + fmt.Sprintf("%d", g.getSmartCPU(service)) + `
-        memory = ` + fmt.Sprintf("%d", g.getSmartMemory(service)) + `
-      }
+	resources := []hclAttribute{
+		{"cpu", fmt.Sprintf("%d", g.getSmartCPU(service))},
+		{"memory", fmt.Sprintf("%d", g.getSmartMemory(service))},
+	}
+	if ext.Resources != nil && ext.Resources.MemoryMax > 0 {
+		resources = append(resources, hclAttribute{"memory_max", fmt.Sprintf("%d", ext.Resources.MemoryMax)})
+	}
+	writeBlock(&content, "      ", "resources", resources)
 
-`)
+	// Log rotation
+	if logs := g.logsBlock(service, logging); logs != nil {
+		writeBlock(&content, "      ", logs.Header, logs.Attrs)
+	}
 
 	// Add environment variables
-	if len(service.Environment) > 0 {
-		content.WriteString(g.generateEnvironmentConfig(service))
+	if len(addresses.Env) > 0 {
+		content.WriteString(g.generateEnvironmentConfig(addresses.Env))
+	}
+
+	// Service addresses rendered at runtime
+	for _, template := range g.templateBlocks(addresses) {
+		writeNestedBlock(&content, "      ", template)
+		content.WriteString("\n")
 	}
 
 	// Enhanced service registration
-	if len(service.ResolvedPorts) > 0 {
-		content.WriteString(g.generateServiceConfig(service))
+	if !g.meshEnabled() && len(service.ResolvedPorts) > 0 {
+		content.WriteString(g.generateServiceConfig(service, "      ", nil))
 	}
 
 	// Add comments for volumes and dependencies
-	if len(service.OriginalService.Volumes) > 0 {
-		content.WriteString("      # Volumes detected: " + strings.Join(service.OriginalService.Volumes, ", ") + "\n\n")
+	if volumes := g.unmappedVolumes(service, addresses); len(volumes) > 0 {
+		content.WriteString("      # Volumes detected: " + strings.Join(volumes, ", ") + "\n\n")
 	}
 
 	if len(service.Dependencies) > 0 {
@@ -145,16 +312,84 @@ job "%s" {
 		content.WriteString("      # Deploy dependencies first!\n\n")
 	}
 
-	// Close task and group
-	content.WriteString(`    }
-  }
-}`)
+	// Close task, group and job, appending x-nomad raw HCL to each
+	content.WriteString(rawHCL("      ", ext.HCL.Task))
+	content.WriteString("    }\n")
+
+	// Further tasks, e.g. init and sidecar containers
+	for _, task := range service.Tasks {
+		content.WriteString("\n" + g.generateExtraTask(service, task, logging))
+	}
+
+	if snippet := rawHCL("    ", ext.HCL.Group); snippet != "" {
+		content.WriteString("\n" + snippet)
+	}
+	content.WriteString("  }\n")
+	if snippet := rawHCL("  ", ext.HCL.Job); snippet != "" {
+		content.WriteString("\n" + snippet)
+	}
+	content.WriteString("}")
 
 	return content.String()
 }
 
+// generateTaskHeader opens the task block with its driver and user
+func (g *NomadGenerator) generateTaskHeader(service types.EnhancedServiceConfig) string {
+	return taskHeader("app", service.OriginalService.User)
+}
+
+// taskHeader opens a docker task block
+func taskHeader(name, user string) string {
+	var header strings.Builder
+	header.WriteString("    task " + quote(name) + " {\n")
+
+	attrs := []hclAttribute{{"driver", quote("docker")}}
+	if user != "" {
+		attrs = append(attrs, hclAttribute{"user", quote(user)})
+	}
+	writeAttributes(&header, "      ", attrs)
+	header.WriteString("\n")
+
+	return header.String()
+}
+
+// generateDockerConfig creates the docker driver config block
+func (g *NomadGenerator) generateDockerConfig(service types.EnhancedServiceConfig, logging *types.LoggingConfig, addresses addressPlan) string {
+	attrs := []hclAttribute{{"image", quote(service.ResolvedImage)}}
+
+	// Add all ports
+	if len(service.ResolvedPorts) > 0 {
+		attrs = append(attrs, hclAttribute{"ports", "[" + g.getPortNames(service) + "]"})
+	}
+
+	attrs = append(attrs, g.commandAttributes(service, addresses)...)
+
+	runtimeAttrs, runtimeBlocks := g.runtimeConfig(service)
+	attrs = append(attrs, runtimeAttrs...)
+
+	if labels := service.OriginalService.Labels; len(labels) > 0 {
+		attrs = append(attrs, hclAttribute{"labels", hclMap("        ", labels)})
+	}
+
+	runtimeBlocks = append(runtimeBlocks, g.dockerVolumeMounts(service)...)
+	runtimeBlocks = append(runtimeBlocks, g.fileMounts(addresses)...)
+
+	if loggingBlock := g.dockerLoggingBlock(logging); loggingBlock != nil {
+		runtimeBlocks = append(runtimeBlocks, *loggingBlock)
+	}
+
+	var config strings.Builder
+	writeNestedBlock(&config, "      ", hclBlock{Header: "config", Attrs: attrs, Blocks: runtimeBlocks})
+	config.WriteString("\n")
+	return config.String()
+}
+
 // generateNetworkConfig creates network configuration with multiple ports
 func (g *NomadGenerator) generateNetworkConfig(service types.EnhancedServiceConfig) string {
+	if g.meshEnabled() {
+		return g.generateBridgeNetworkConfig(service)
+	}
+
 	if len(service.ResolvedPorts) == 0 {
 		return ""
 	}
@@ -164,64 +399,127 @@ func (g *NomadGenerator) generateNetworkConfig(service types.EnhancedServiceConf
 
 	// Add all detected ports
 	for i, port := range service.ResolvedPorts {
-		portName := "http"
-		if i > 0 {
-			portName = fmt.Sprintf("port_%d", port.Host)
-		}
+		portName := g.portLabel(service, i)
 
-		config.WriteString(fmt.Sprintf(`      port "%s" {
-        static = %d
-      }
-`, portName, port.Host))
+		config.WriteString("      port " + quote(portName) + " {\n")
+		attrs := portAttributes(port)
+		if port.Host != port.Container {
+			attrs = append(attrs, hclAttribute{"to", fmt.Sprintf("%d", port.Container)})
+		}
+		writeAttributes(&config, "        ", attrs)
+		config.WriteString("      }\n")
 	}
 
 	config.WriteString("    }\n\n")
 	return config.String()
 }
 
+// generateBridgeNetworkConfig creates the bridge network Connect sidecars need,
+// mapping each port label to the container port
+func (g *NomadGenerator) generateBridgeNetworkConfig(service types.EnhancedServiceConfig) string {
+	network := hclBlock{
+		Header: "network",
+		Attrs:  []hclAttribute{{"mode", quote("bridge")}},
+	}
+
+	for i, port := range service.ResolvedPorts {
+		portName := g.portLabel(service, i)
+		network.Blocks = append(network.Blocks, hclBlock{
+			Header: "port " + quote(portName),
+			Attrs:  append(portAttributes(port), hclAttribute{"to", fmt.Sprintf("%d", port.Container)}),
+		})
+	}
+
+	var config strings.Builder
+	writeNestedBlock(&config, "    ", network)
+	config.WriteString("\n")
+	return config.String()
+}
+
+// portAttributes pins a port to its host port; ports without one are left
+// for Nomad to allocate
+func portAttributes(port types.PortMapping) []hclAttribute {
+	if port.Host == 0 {
+		return nil
+	}
+	return []hclAttribute{{"static", fmt.Sprintf("%d", port.Host)}}
+}
+
 // generateEnvironmentConfig creates environment variables
-func (g *NomadGenerator) generateEnvironmentConfig(service types.EnhancedServiceConfig) string {
+func (g *NomadGenerator) generateEnvironmentConfig(environment map[string]string) string {
 	var config strings.Builder
 
 	config.WriteString("      env {\n")
-	for key, value := range service.Environment {
-		// Escape quotes in values
-		escapedValue := strings.ReplaceAll(value, `"`, `\"`)
-		config.WriteString(fmt.Sprintf(`        %s = "%s"
-`, key, escapedValue))
+	for _, key := range sortedKeys(environment) {
+		config.WriteString(fmt.Sprintf("        %s = %s\n", key, quote(environment[key])))
 	}
 	config.WriteString("      }\n\n")
 
 	return config.String()
 }
 
-// generateServiceConfig creates service registration
-func (g *NomadGenerator) generateServiceConfig(service types.EnhancedServiceConfig) string {
-	return fmt.Sprintf(`      service {
-        name = "%s"
-        port = "http"
-        tags = ["docker", "%s", "nompose"]
-
-        check {
-          type     = "tcp"
-          interval = "30s"
-          timeout  = "3s"
-          port     = "http"
-        }
-      }
-
-`, service.Name, service.Name)
+// generateServiceConfig creates service registration, with a Connect sidecar in mesh mode
+func (g *NomadGenerator) generateServiceConfig(service types.EnhancedServiceConfig, indent string, upstreams []meshUpstream) string {
+	block := hclBlock{
+		Header: "service",
+		Attrs:  []hclAttribute{{"name", quote(service.Name)}},
+	}
+
+	hasPort := len(service.ResolvedPorts) > 0
+	if hasPort {
+		block.Attrs = append(block.Attrs, hclAttribute{"port", quote(g.portLabel(service, 0))})
+	} else if port := g.meshPort(service); g.meshEnabled() && port > 0 {
+		// Without a published port the sidecar forwards to the port in the namespace
+		block.Attrs = append(block.Attrs, hclAttribute{"port", quote(fmt.Sprintf("%d", port))})
+	}
+	block.Attrs = append(block.Attrs,
+		hclAttribute{"provider", quote(g.serviceProvider())},
+		hclAttribute{"tags", quoteList(g.serviceTags(service))})
+
+	if g.meshEnabled() {
+		block.Blocks = append(block.Blocks, g.connectBlock(upstreams))
+	}
+
+	if hasPort && g.healthCheckPath(service) != HealthCheckNone {
+		// Mesh services are registered at the group level
+		block.Blocks = append(block.Blocks, g.checkBlock(g.buildHealthCheck(service), g.meshEnabled()))
+	}
+
+	var config strings.Builder
+	writeNestedBlock(&config, indent, block)
+	config.WriteString("\n")
+	return config.String()
+}
+
+// serviceTags combines the default tags with label and ingress tags
+func (g *NomadGenerator) serviceTags(service types.EnhancedServiceConfig) []string {
+	tags := []string{"docker", service.Name, "nompose"}
+	tags = append(tags, g.labelTags(service)...)
+	tags = append(tags, g.extension(service).Tags...)
+	return append(tags, g.ingressTags(service)...)
 }
 
 // Helper functions
 func (g *NomadGenerator) getReplicas(service types.EnhancedServiceConfig) int {
+	if count := g.override(service).Count; count > 0 {
+		return count
+	}
+	if count := g.extension(service).Count; count > 0 {
+		return count
+	}
 	if service.OriginalService.Deploy != nil && service.OriginalService.Deploy.Replicas > 0 {
 		return service.OriginalService.Deploy.Replicas
 	}
-	return 1
+	return intOrDefault(g.options.Instances, 1)
 }
 
 func (g *NomadGenerator) getSmartCPU(service types.EnhancedServiceConfig) int {
+	if resources := g.extension(service).Resources; resources != nil && resources.CPU > 0 && g.override(service).CPU == 0 {
+		return resources.CPU
+	}
+	if cpu := intOrDefault(g.override(service).CPU, g.options.CPU); cpu > 0 {
+		return cpu
+	}
 	serviceName := strings.ToLower(service.Name)
 	switch {
 	case strings.Contains(serviceName, "db") || strings.Contains(serviceName, "database"):
@@ -238,6 +536,12 @@ func (g *NomadGenerator) getSmartCPU(service types.EnhancedServiceConfig) int {
 }
 
 func (g *NomadGenerator) getSmartMemory(service types.EnhancedServiceConfig) int {
+	if resources := g.extension(service).Resources; resources != nil && resources.Memory > 0 && g.override(service).Memory == 0 {
+		return resources.Memory
+	}
+	if memory := intOrDefault(g.override(service).Memory, g.options.Memory); memory > 0 {
+		return memory
+	}
 	serviceName := strings.ToLower(service.Name)
 	switch {
 	case strings.Contains(serviceName, "db") || strings.Contains(serviceName, "database"):
@@ -256,6 +560,10 @@ func (g *NomadGenerator) getPortsDescription(service types.EnhancedServiceConfig
 
 	var ports []string
 	for _, port := range service.ResolvedPorts {
+		if port.Host == 0 {
+			ports = append(ports, fmt.Sprintf("%d (dynamic)", port.Container))
+			continue
+		}
 		ports = append(ports, fmt.Sprintf("%d", port.Host))
 	}
 	return strings.Join(ports, ", ")
@@ -268,11 +576,7 @@ func (g *NomadGenerator) getPortNames(service types.EnhancedServiceConfig) strin
 
 	var names []string
 	for i := range service.ResolvedPorts {
-		if i == 0 {
-			names = append(names, `"http"`)
-		} else {
-			names = append(names, fmt.Sprintf(`"port_%d"`, service.ResolvedPorts[i].Host))
-		}
+		names = append(names, quote(g.portLabel(service, i)))
 	}
 	return strings.Join(names, ", ")
 }
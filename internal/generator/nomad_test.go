@@ -0,0 +1,1585 @@
+package generator
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Jassem-HCP/nompose/internal/parser"
+	"github.com/Jassem-HCP/nompose/internal/types"
+	"gopkg.in/yaml.v3"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+func TestGenerateJobsGolden(t *testing.T) {
+	tests := []struct {
+		name    string
+		options types.GenerateOptions
+	}{
+		{
+			name:    "basic",
+			options: types.GenerateOptions{AddressRewrite: AddressRewriteConsulDNS, LabelTagPrefixes: []string{"com.example."}},
+		},
+		{
+			name:    "mesh",
+			options: types.GenerateOptions{Mesh: MeshConsulConnect, UpstreamAddr: UpstreamAddressLocalhost},
+		},
+		{
+			name:    "extension",
+			options: types.GenerateOptions{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := filepath.Join("testdata", test.name)
+			got := generate(t, filepath.Join(dir, "docker-compose.yml"), test.options)
+
+			// Map iteration order changes between runs; output must not
+			for run := 0; run < 5; run++ {
+				again := generate(t, filepath.Join(dir, "docker-compose.yml"), test.options)
+				if !equalFiles(got, again) {
+					t.Fatalf("output differs between runs")
+				}
+			}
+
+			if *update {
+				writeGolden(t, dir, got)
+			}
+
+			for _, name := range sortedFileNames(got) {
+				want, err := os.ReadFile(filepath.Join(dir, name+".golden"))
+				if err != nil {
+					t.Fatalf("missing golden file for %s (run go test -update): %v", name, err)
+				}
+				if string(want) != got[name] {
+					t.Errorf("%s doesn't match %s.golden (run go test -update to accept)\n--- got ---\n%s", name, name, got[name])
+				}
+			}
+
+			golden, err := filepath.Glob(filepath.Join(dir, "*.golden"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(golden) != len(got) {
+				t.Errorf("generated %d files, testdata has %d golden files", len(got), len(golden))
+			}
+		})
+	}
+}
+
+func TestGenerateOutput(t *testing.T) {
+	services := []types.EnhancedServiceConfig{
+		{Name: "web", ResolvedImage: "example/web:1.0"},
+		{Name: "worker", ResolvedImage: "example/worker:1.0"},
+	}
+	run := func(outputDir string, options types.GenerateOptions, stream io.Writer) (string, error) {
+		t.Helper()
+		var progress bytes.Buffer
+		g := NewNomadGenerator(outputDir, options)
+		g.ReportTo(&progress)
+		if stream != nil {
+			g.StreamTo(stream)
+		}
+		err := g.GenerateJobs(services)
+		return progress.String(), err
+	}
+
+	outputDir := t.TempDir()
+	if _, err := run(outputDir, types.GenerateOptions{}, nil); err != nil {
+		t.Fatalf("failed to generate jobs: %v", err)
+	}
+	webPath := filepath.Join(outputDir, "web.nomad.hcl")
+	generated, err := os.ReadFile(webPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edited := strings.Replace(string(generated), "count = 1", "count = 5", 1)
+	if err := os.WriteFile(webPath, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	progress, err := run(outputDir, types.GenerateOptions{DryRun: true}, nil)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	for _, want := range []string{
+		"web.nomad.hcl (hand-edited, would need --force)",
+		"worker.nomad.hcl (unchanged)",
+	} {
+		if !strings.Contains(progress, want) {
+			t.Errorf("dry run output is missing %q:\n%s", want, progress)
+		}
+	}
+
+	if _, err := run(outputDir, types.GenerateOptions{}, nil); err == nil || !strings.Contains(err.Error(), "refusing to overwrite files edited since nompose generated them: web.nomad.hcl") {
+		t.Errorf("expected hand edits to be refused, got %v", err)
+	}
+	if current, _ := os.ReadFile(webPath); string(current) != edited {
+		t.Error("the hand-edited job was overwritten")
+	}
+
+	if _, err := run(outputDir, types.GenerateOptions{Force: true}, nil); err != nil {
+		t.Fatalf("--force failed: %v", err)
+	}
+	if current, _ := os.ReadFile(webPath); string(current) != string(generated) {
+		t.Errorf("--force didn't restore the generated job:\n%s", current)
+	}
+
+	var stream bytes.Buffer
+	streamDir := t.TempDir()
+	progress, err = run(streamDir, types.GenerateOptions{}, &stream)
+	if err != nil {
+		t.Fatalf("streaming failed: %v", err)
+	}
+	documents := strings.Split(stream.String(), "---\n")
+	if len(documents) != 2 {
+		t.Fatalf("streamed %d documents, want 2:\n%s", len(documents), stream.String())
+	}
+	if !strings.HasPrefix(documents[0], "# file: web.nomad.hcl\n# nompose-checksum: ") || !strings.HasPrefix(documents[1], "# file: worker.nomad.hcl\n") {
+		t.Errorf("streamed documents aren't named:\n%s", stream.String())
+	}
+	if strings.Contains(stream.String(), "Generating") || !strings.Contains(progress, "Generating") {
+		t.Errorf("progress leaked into the stream:\n%s", stream.String())
+	}
+	if entries, _ := os.ReadDir(streamDir); len(entries) > 0 {
+		t.Errorf("streaming wrote %d files to the output directory", len(entries))
+	}
+}
+
+func TestGenerateTasksAndPeriodic(t *testing.T) {
+	services := []types.EnhancedServiceConfig{
+		{
+			Name:          "web",
+			ResolvedImage: "example/web:1.2",
+			OriginalService: types.DockerComposeService{
+				XNomad: &types.NomadExtension{Volumes: map[string]types.NomadVolume{"data": {Type: "csi", PerAlloc: true}}},
+			},
+			Tasks: []types.Task{
+				{Name: "migrate", Image: "example/web:1.2", Lifecycle: "prestart", Service: types.DockerComposeService{Entrypoint: []interface{}{"./migrate"}}},
+				{Name: "metrics", Image: "prom/statsd-exporter", Memory: 64, Service: types.DockerComposeService{Volumes: []string{"data:/data"}}},
+			},
+		},
+		{
+			Name:          "report",
+			ResolvedImage: "example/report",
+			OriginalService: types.DockerComposeService{
+				XNomad: &types.NomadExtension{Periodic: &types.NomadPeriodic{Cron: "0 3 * * *", ProhibitOverlap: true}},
+			},
+		},
+	}
+
+	outputDir := t.TempDir()
+	if err := NewNomadGenerator(outputDir, types.GenerateOptions{}).GenerateJobs(services); err != nil {
+		t.Fatalf("failed to generate jobs: %v", err)
+	}
+
+	web, err := os.ReadFile(filepath.Join(outputDir, "web.nomad.hcl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"    task \"migrate\" {\n      driver = \"docker\"\n\n      lifecycle {\n        hook    = \"prestart\"\n        sidecar = false\n      }\n",
+		`entrypoint = ["./migrate"]`,
+		"    task \"metrics\" {\n",
+		"        memory = 64\n",
+		"      per_alloc       = true\n",
+		"      volume_mount {\n        volume      = \"data\"\n        destination = \"/data\"\n",
+	} {
+		if !strings.Contains(string(web), want) {
+			t.Errorf("web job is missing:\n%s\n--- got ---\n%s", want, web)
+		}
+	}
+
+	report, err := os.ReadFile(filepath.Join(outputDir, "report.nomad.hcl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`type        = "batch"`,
+		"  periodic {\n    crons            = [\"0 3 * * *\"]\n    prohibit_overlap = true\n  }\n",
+	} {
+		if !strings.Contains(string(report), want) {
+			t.Errorf("report job is missing:\n%s\n--- got ---\n%s", want, report)
+		}
+	}
+}
+
+func TestExtensionSystemJob(t *testing.T) {
+	for _, jobType := range []string{"system", "sysbatch"} {
+		service := types.EnhancedServiceConfig{
+			Name:          "agent",
+			ResolvedImage: "example/agent",
+			OriginalService: types.DockerComposeService{
+				XNomad: &types.NomadExtension{Type: jobType},
+			},
+		}
+		outputDir := t.TempDir()
+		if err := NewNomadGenerator(outputDir, types.GenerateOptions{}).GenerateJobs([]types.EnhancedServiceConfig{service}); err != nil {
+			t.Fatalf("failed to generate jobs: %v", err)
+		}
+		job, err := os.ReadFile(filepath.Join(outputDir, "agent.nomad.hcl"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(job), "type        = \""+jobType+"\"") {
+			t.Errorf("%s job has the wrong type:\n%s", jobType, job)
+		}
+		if strings.Contains(string(job), "reschedule {") {
+			t.Errorf("%s job must not have a reschedule block:\n%s", jobType, job)
+		}
+		if jobType == "sysbatch" && strings.Contains(string(job), "update {") {
+			t.Errorf("sysbatch job must not have an update block:\n%s", job)
+		}
+	}
+}
+
+func TestExtensionHCLConflicts(t *testing.T) {
+	tests := []struct {
+		name    string
+		hcl     types.NomadHCL
+		wantErr string
+	}{
+		{name: "task attribute", hcl: types.NomadHCL{Task: "kill_timeout = \"30s\""}},
+		{name: "group block", hcl: types.NomadHCL{Group: "ephemeral_disk {\n  size = 500\n}"}},
+		{name: "repeatable block", hcl: types.NomadHCL{Group: "constraint {\n  attribute = \"${attr.kernel.name}\"\n  value     = \"linux\"\n}"}},
+		{name: "task resources", hcl: types.NomadHCL{Task: "resources {\n  cpu = 100\n}"}, wantErr: "x-nomad.hcl.task sets resources"},
+		{name: "task driver", hcl: types.NomadHCL{Task: "driver = \"exec\""}, wantErr: "x-nomad.hcl.task sets driver"},
+		{name: "group network", hcl: types.NomadHCL{Group: "network {\n  mode = \"bridge\"\n}"}, wantErr: "x-nomad.hcl.group sets network"},
+		{name: "group count", hcl: types.NomadHCL{Group: "count = 2"}, wantErr: "x-nomad.hcl.group sets count"},
+		{name: "job type", hcl: types.NomadHCL{Job: "type = \"batch\""}, wantErr: "x-nomad.hcl.job sets type"},
+		{name: "job datacenters", hcl: types.NomadHCL{Job: "datacenters = [\"eu\"]"}, wantErr: "x-nomad.hcl.job sets datacenters"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := types.EnhancedServiceConfig{
+				Name:          "web",
+				ResolvedImage: "example/web",
+				ResolvedPorts: []types.PortMapping{{Host: 8080, Container: 8080, Protocol: "tcp"}},
+				OriginalService: types.DockerComposeService{
+					XNomad: &types.NomadExtension{HCL: test.hcl},
+				},
+			}
+			err := NewNomadGenerator(t.TempDir(), types.GenerateOptions{}).GenerateJobs([]types.EnhancedServiceConfig{service})
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("error = %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestMeshPortlessDependency(t *testing.T) {
+	services := []types.EnhancedServiceConfig{
+		{
+			Name:          "api",
+			ResolvedImage: "example/api:1.0",
+			ResolvedPorts: []types.PortMapping{{Host: 3000, Container: 3000, Protocol: "tcp"}},
+			Environment:   map[string]string{"DATABASE_URL": "postgres://app@db:5432/app", "CACHE": "cache"},
+			Dependencies:  []string{"cache", "db"},
+		},
+		{Name: "db", ResolvedImage: "postgres:16"},
+		{Name: "cache", ResolvedImage: "redis:7", OriginalService: types.DockerComposeService{Expose: []string{"6379/tcp"}}},
+	}
+
+	outputDir := t.TempDir()
+	options := types.GenerateOptions{Mesh: MeshConsulConnect, UpstreamAddr: UpstreamAddressLocalhost}
+	if err := NewNomadGenerator(outputDir, options).GenerateJobs(services); err != nil {
+		t.Fatalf("failed to generate jobs: %v", err)
+	}
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(outputDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	api := read("api.nomad.hcl")
+	for _, want := range []string{
+		"destination_name = \"db\"\n              local_bind_port  = 5432\n",
+		"destination_name = \"cache\"\n              local_bind_port  = 6379\n",
+		`DATABASE_URL = "postgres://app@localhost:5432/app"`,
+	} {
+		if !strings.Contains(api, want) {
+			t.Errorf("api job is missing:\n%s\n--- got ---\n%s", want, api)
+		}
+	}
+
+	// Destinations without published ports still register a Connect service
+	for name, port := range map[string]string{"db": "5432", "cache": "6379"} {
+		job := read(name + ".nomad.hcl")
+		for _, want := range []string{
+			"      name     = \"" + name + "\"\n      port     = \"" + port + "\"\n",
+			"      connect {\n        sidecar_service {\n        }\n      }\n",
+		} {
+			if !strings.Contains(job, want) {
+				t.Errorf("%s job is missing:\n%s\n--- got ---\n%s", name, want, job)
+			}
+		}
+		if !strings.Contains(read(name+".intentions.hcl"), `Name   = "api"`) {
+			t.Errorf("%s intentions should allow api", name)
+		}
+	}
+}
+
+func intPtr(value int) *int {
+	return &value
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{`plain`, `"plain"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`C:\dir`, `"C:\\dir"`},
+		{"line\nbreak\ttab", `"line\nbreak\ttab"`},
+		{"bell\a", `"bell\u0007"`},
+		{"nul\x00", `"nul\u0000"`},
+		{"bad\xff", `"bad\ufffd"`},
+		{"café ☕", `"café ☕"`},
+		{`--db ${DB_URL}`, `"--db $${DB_URL}"`},
+		{`%{ if true }`, `"%%{ if true }"`},
+		{`cost $5 and 50%`, `"cost $5 and 50%"`},
+		{`${NOMAD_PORT_http}`, `"${NOMAD_PORT_http}"`},
+		{`${NOMPOSE_ADDR_DB}/v1`, `"${NOMPOSE_ADDR_DB}/v1"`},
+		{`${attr.kernel.name}`, `"${attr.kernel.name}"`},
+		{`${node.unique.name}-${HOME}`, `"${node.unique.name}-$${HOME}"`},
+	}
+
+	for _, test := range tests {
+		if got := quote(test.value); got != test.want {
+			t.Errorf("quote(%q) = %s, want %s", test.value, got, test.want)
+		}
+	}
+}
+
+func TestGenerateEscapesTemplateSequences(t *testing.T) {
+	services := []types.EnhancedServiceConfig{{
+		Name:          "api",
+		ResolvedImage: "example/api",
+		Environment:   map[string]string{"GREETING": "hello ${USER}", "PORT": "${NOMAD_PORT_http}"},
+		OriginalService: types.DockerComposeService{
+			Command: `node server.js --db "${DB_URL}" --fmt %{x}`,
+			Labels:  map[string]string{"com.example.note": "${not-a-var}"},
+		},
+	}}
+
+	outputDir := t.TempDir()
+	options := types.GenerateOptions{LabelTagPrefixes: []string{"com.example."}}
+	if err := NewNomadGenerator(outputDir, options).GenerateJobs(services); err != nil {
+		t.Fatalf("failed to generate jobs: %v", err)
+	}
+	job, err := os.ReadFile(filepath.Join(outputDir, "api.nomad.hcl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"node server.js --db \"$${DB_URL}\" --fmt %%{x}"`,
+		`GREETING = "hello $${USER}"`,
+		`PORT = "${NOMAD_PORT_http}"`,
+		`$${not-a-var}`,
+	} {
+		if !strings.Contains(string(job), want) {
+			t.Errorf("job is missing %s\n--- got ---\n%s", want, job)
+		}
+	}
+}
+
+func renderConfig(attrs []hclAttribute, blocks []hclBlock) string {
+	var b strings.Builder
+	writeNestedBlock(&b, "", hclBlock{Header: "config", Attrs: attrs, Blocks: blocks})
+	return b.String()
+}
+
+func TestRuntimeConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		service  types.DockerComposeService
+		want     []string
+		warnings int
+	}{
+		{
+			name:    "ulimits",
+			service: types.DockerComposeService{Ulimits: map[string]interface{}{"nproc": 65535, "nofile": map[string]interface{}{"soft": 1024, "hard": 2048}, "core": map[string]interface{}{"soft": 0}}},
+			want:    []string{"  ulimit {\n    core   = \"0:0\"\n    nofile = \"1024:2048\"\n    nproc  = \"65535:65535\"\n  }\n"},
+		},
+		{
+			name:     "malformed ulimit",
+			service:  types.DockerComposeService{Ulimits: map[string]interface{}{"nofile": "lots"}},
+			warnings: 1,
+		},
+		{
+			name:    "sysctls",
+			service: types.DockerComposeService{Sysctls: types.ListOrDict{"net.core.somaxconn": "1024", "net.ipv4.tcp_syncookies": "0"}},
+			want:    []string{"  sysctl = {\n    \"net.core.somaxconn\"      = \"1024\"\n    \"net.ipv4.tcp_syncookies\" = \"0\"\n  }\n"},
+		},
+		{
+			name:    "shm_size with unit",
+			service: types.DockerComposeService{ShmSize: "64m"},
+			want:    []string{"shm_size = 67108864\n"},
+		},
+		{
+			name:    "shm_size in bytes",
+			service: types.DockerComposeService{ShmSize: 1024},
+			want:    []string{"shm_size = 1024\n"},
+		},
+		{
+			name:     "malformed shm_size",
+			service:  types.DockerComposeService{ShmSize: "lots"},
+			warnings: 1,
+		},
+		{
+			name:    "tmpfs string",
+			service: types.DockerComposeService{Tmpfs: "/run"},
+			want:    []string{"  mount {\n    type   = \"tmpfs\"\n    target = \"/run\"\n  }\n"},
+		},
+		{
+			name:     "tmpfs list with options",
+			service:  types.DockerComposeService{Tmpfs: []interface{}{"/cache:size=64m,mode=1777"}},
+			want:     []string{"    target = \"/cache\"\n", "    tmpfs_options {\n      size = 67108864\n    }\n"},
+			warnings: 1,
+		},
+		{
+			name: "devices",
+			service: types.DockerComposeService{Devices: []interface{}{
+				"/dev/ttyUSB0",
+				"/dev/sda:/dev/xvda:rwm",
+				map[string]interface{}{"source": "/dev/fuse", "target": "/dev/fuse"},
+				map[string]interface{}{"target": "/dev/missing"},
+			}},
+			want: []string{`devices = [{ host_path = "/dev/ttyUSB0" }, ` +
+				`{ host_path = "/dev/sda", container_path = "/dev/xvda", cgroup_permissions = "rwm" }, ` +
+				`{ host_path = "/dev/fuse", container_path = "/dev/fuse" }]`},
+			warnings: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewNomadGenerator(t.TempDir(), types.GenerateOptions{})
+			got := renderConfig(g.runtimeConfig(types.EnhancedServiceConfig{Name: "app", OriginalService: test.service}))
+			for _, want := range test.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("config is missing:\n%s\n--- got ---\n%s", want, got)
+				}
+			}
+			if len(g.warnings) != test.warnings {
+				t.Errorf("warnings = %q, want %d", g.warnings, test.warnings)
+			}
+		})
+	}
+}
+
+func TestLoggingConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		logging     *types.LoggingConfig
+		options     types.GenerateOptions
+		wantLogs    *hclBlock
+		wantDocker  *hclBlock
+		wantWarning bool
+	}{
+		{
+			name: "no logging",
+		},
+		{
+			name:     "json-file rotation",
+			logging:  &types.LoggingConfig{Driver: "json-file", Options: map[string]string{"max-size": "10m", "max-file": "3"}},
+			wantLogs: &hclBlock{Header: "logs", Attrs: []hclAttribute{{"max_files", "3"}, {"max_file_size", "10"}}},
+		},
+		{
+			name:     "default driver rounds sizes up to a megabyte",
+			logging:  &types.LoggingConfig{Options: map[string]string{"max-size": "200k"}},
+			wantLogs: &hclBlock{Header: "logs", Attrs: []hclAttribute{{"max_file_size", "1"}}},
+		},
+		{
+			name:        "malformed rotation options",
+			logging:     &types.LoggingConfig{Driver: "local", Options: map[string]string{"max-size": "huge", "max-file": "many"}},
+			wantWarning: true,
+		},
+		{
+			name:    "other drivers pass through",
+			logging: &types.LoggingConfig{Driver: "syslog", Options: map[string]string{"tag": "web", "syslog-address": "udp://10.0.0.1:514"}},
+			wantDocker: &hclBlock{
+				Header: "logging",
+				Attrs:  []hclAttribute{{"type", `"syslog"`}},
+				Blocks: []hclBlock{{Header: "config", Attrs: []hclAttribute{{"syslog-address", `"udp://10.0.0.1:514"`}, {"tag", `"web"`}}}},
+			},
+		},
+		{
+			name:        "global driver wins",
+			logging:     &types.LoggingConfig{Driver: "syslog"},
+			options:     types.GenerateOptions{LogDriver: "journald"},
+			wantDocker:  &hclBlock{Header: "logging", Attrs: []hclAttribute{{"type", `"journald"`}}},
+			wantWarning: true,
+		},
+		{
+			name:     "global rotation options",
+			options:  types.GenerateOptions{LogDriver: "json-file", LogOptions: map[string]string{"max-file": "5"}},
+			wantLogs: &hclBlock{Header: "logs", Attrs: []hclAttribute{{"max_files", "5"}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewNomadGenerator(t.TempDir(), test.options)
+			service := types.EnhancedServiceConfig{Name: "app", OriginalService: types.DockerComposeService{Logging: test.logging}}
+
+			logging := g.effectiveLogging(service)
+			if got := g.logsBlock(service, logging); !reflect.DeepEqual(got, test.wantLogs) {
+				t.Errorf("logs = %+v, want %+v", got, test.wantLogs)
+			}
+			if got := g.dockerLoggingBlock(logging); !reflect.DeepEqual(got, test.wantDocker) {
+				t.Errorf("docker logging = %+v, want %+v", got, test.wantDocker)
+			}
+			if got := len(g.warnings) > 0; got != test.wantWarning {
+				t.Errorf("warnings = %q, want a warning: %t", g.warnings, test.wantWarning)
+			}
+		})
+	}
+}
+
+func TestParseOverrides(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    map[string]types.ServiceOverride
+		wantErr string
+	}{
+		{
+			name:   "settings per service",
+			values: []string{"web.cpu=500", "web.memory=1024", "db.count=3", "web.health_check=/ready", "db.datacenters=eu-1, eu-2,"},
+			want: map[string]types.ServiceOverride{
+				"web": {CPU: 500, Memory: 1024, HealthCheck: "/ready"},
+				"db":  {Count: 3, Datacenters: []string{"eu-1", "eu-2"}},
+			},
+		},
+		{
+			name:   "aliases and later flags win",
+			values: []string{"api.instances=2", "api.instances=4", "api.node-pool=gpu", "api.health-check=none"},
+			want:   map[string]types.ServiceOverride{"api": {Count: 4, NodePool: "gpu", HealthCheck: HealthCheckNone}},
+		},
+		{
+			name:   "service names with dots",
+			values: []string{"my.app.port=8080", "my.app.namespace=team-a", "my.app.region=eu"},
+			want:   map[string]types.ServiceOverride{"my.app": {Port: 8080, Namespace: "team-a", Region: "eu"}},
+		},
+		{
+			name:   "value containing equals",
+			values: []string{"web.health_check=/status?full=1"},
+			want:   map[string]types.ServiceOverride{"web": {HealthCheck: "/status?full=1"}},
+		},
+		{name: "no value", values: []string{"web.cpu"}, wantErr: "expected <service>.<key>=<value>"},
+		{name: "no service", values: []string{"cpu=500"}, wantErr: "expected <service>.<key>=<value>"},
+		{name: "empty service", values: []string{".cpu=500"}, wantErr: "expected <service>.<key>=<value>"},
+		{name: "empty key", values: []string{"web.=500"}, wantErr: "expected <service>.<key>=<value>"},
+		{name: "unknown key", values: []string{"web.gpu=1"}, wantErr: `unknown setting "gpu"`},
+		{name: "not a number", values: []string{"web.memory=1g"}, wantErr: `memory must be a positive number, got "1g"`},
+		{name: "zero", values: []string{"web.count=0"}, wantErr: "count must be a positive number"},
+		{name: "negative", values: []string{"web.port=-1"}, wantErr: "port must be a positive number"},
+		{name: "priority too high", values: []string{"web.priority=101"}, wantErr: "priority must be between 1 and 100"},
+		{name: "no datacenters", values: []string{"web.datacenters=,"}, wantErr: "datacenters must list at least one datacenter"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseOverrides(test.values)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("overrides = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestOverridePrecedence(t *testing.T) {
+	service := types.EnhancedServiceConfig{
+		Name: "web",
+		OriginalService: types.DockerComposeService{
+			Deploy: &types.DeployConfig{Replicas: 2},
+			XNomad: &types.NomadExtension{Count: 3},
+		},
+	}
+	global := types.GenerateOptions{
+		Instances:   5,
+		Datacenter:  "dc2",
+		Datacenters: []string{"eu-1"},
+		Region:      "eu",
+		Namespace:   "apps",
+		HealthCheck: "/health",
+	}
+
+	tests := []struct {
+		name            string
+		options         types.GenerateOptions
+		service         types.EnhancedServiceConfig
+		wantCount       int
+		wantDatacenters []string
+		wantPlacement   []hclAttribute
+		wantHealthCheck string
+	}{
+		{
+			name:            "defaults",
+			service:         types.EnhancedServiceConfig{Name: "web"},
+			wantCount:       1,
+			wantDatacenters: []string{"dc1"},
+		},
+		{
+			name:            "global options",
+			options:         global,
+			service:         types.EnhancedServiceConfig{Name: "web"},
+			wantCount:       5,
+			wantDatacenters: []string{"eu-1"},
+			wantPlacement:   []hclAttribute{{"region", `"eu"`}, {"namespace", `"apps"`}},
+			wantHealthCheck: "/health",
+		},
+		{
+			name:            "x-nomad count beats deploy.replicas and --instances",
+			options:         global,
+			service:         service,
+			wantCount:       3,
+			wantDatacenters: []string{"eu-1"},
+			wantPlacement:   []hclAttribute{{"region", `"eu"`}, {"namespace", `"apps"`}},
+			wantHealthCheck: "/health",
+		},
+		{
+			name: "--set beats everything",
+			options: func() types.GenerateOptions {
+				options := global
+				options.Overrides = map[string]types.ServiceOverride{
+					"web": {Count: 7, Datacenters: []string{"us-1"}, Namespace: "team", NodePool: "gpu", Priority: 80, HealthCheck: HealthCheckNone},
+				}
+				return options
+			}(),
+			service:         service,
+			wantCount:       7,
+			wantDatacenters: []string{"us-1"},
+			wantPlacement:   []hclAttribute{{"region", `"eu"`}, {"namespace", `"team"`}, {"node_pool", `"gpu"`}, {"priority", "80"}},
+			wantHealthCheck: HealthCheckNone,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewNomadGenerator(t.TempDir(), test.options)
+			if got := g.getReplicas(test.service); got != test.wantCount {
+				t.Errorf("count = %d, want %d", got, test.wantCount)
+			}
+			if got := g.datacenters(test.service); !reflect.DeepEqual(got, test.wantDatacenters) {
+				t.Errorf("datacenters = %v, want %v", got, test.wantDatacenters)
+			}
+			if got := g.jobPlacement(test.service); !reflect.DeepEqual(got, test.wantPlacement) {
+				t.Errorf("placement = %v, want %v", got, test.wantPlacement)
+			}
+			if got := g.healthCheckPath(test.service); got != test.wantHealthCheck {
+				t.Errorf("health check = %q, want %q", got, test.wantHealthCheck)
+			}
+		})
+	}
+}
+
+func TestConfigFileTemplates(t *testing.T) {
+	dir := t.TempDir()
+	for path, content := range map[string]string{
+		"a/config.yml":       "database: db:5432\n",
+		"b/config.yml":       "cache: cache:6379\nbanner: \"{{ .Values.name }}\"\n",
+		"docker-compose.yml": "",
+	} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, path), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	services := []types.EnhancedServiceConfig{
+		{
+			Name:          "app",
+			ResolvedImage: "example/app",
+			SourceFile:    filepath.Join(dir, "docker-compose.yml"),
+			Environment:   map[string]string{"DB_URL": "postgres://db:5432/{{app}}"},
+			OriginalService: types.DockerComposeService{
+				Volumes: []string{"./a/config.yml:/etc/a/config.yml:ro", "./b/config.yml:/etc/b/config.yml"},
+			},
+		},
+		{Name: "db", ResolvedImage: "postgres:16", ResolvedPorts: []types.PortMapping{{Host: 5432, Container: 5432, Protocol: "tcp"}}},
+		{Name: "cache", ResolvedImage: "redis:7", ResolvedPorts: []types.PortMapping{{Host: 6379, Container: 6379, Protocol: "tcp"}}},
+	}
+
+	outputDir := t.TempDir()
+	options := types.GenerateOptions{AddressRewrite: AddressRewriteConsulTemplate}
+	if err := NewNomadGenerator(outputDir, options).GenerateJobs(services); err != nil {
+		t.Fatalf("failed to generate jobs: %v", err)
+	}
+	job, err := os.ReadFile(filepath.Join(outputDir, "app.nomad.hcl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`destination = "local/config.yml"`,
+		`destination = "local/b-config.yml"`,
+		`source   = "local/config.yml"`,
+		`source = "local/b-config.yml"`,
+		`banner: "{{"{{"}} .Values.name {{"}}"}}"`,
+		`cache: {{ with index (service "cache") 0 }}{{ .Address }}:{{ .Port }}{{ end }}`,
+		`DB_URL=postgres://{{ with index (service "db") 0 }}{{ .Address }}:{{ .Port }}{{ end }}/{{"{{"}}app{{"}}"}}`,
+	} {
+		if !strings.Contains(string(job), want) {
+			t.Errorf("job is missing %s\n--- got ---\n%s", want, job)
+		}
+	}
+}
+
+func TestUnresolvedAddresses(t *testing.T) {
+	tests := []struct {
+		name    string
+		rewrite string
+		env     string
+		want    string
+		note    string
+	}{
+		{
+			name:    "consul-dns to a published port",
+			rewrite: AddressRewriteConsulDNS,
+			env:     "http://api:8080",
+			want:    `URL = "http://api.service.consul:80"`,
+		},
+		{
+			name:    "consul-dns to a port the destination doesn't publish",
+			rewrite: AddressRewriteConsulDNS,
+			env:     "http://api:9090",
+			want:    `URL = "http://api.service.consul:9090"`,
+			note:    "# Addresses: api doesn't publish port 9090, so api.service.consul:9090 won't reach it\n",
+		},
+		{
+			name:    "consul-dns to a service without ports",
+			rewrite: AddressRewriteConsulDNS,
+			env:     "redis://cache:6379",
+			want:    `URL = "redis://cache.service.consul:6379"`,
+			note:    "# Addresses: cache publishes no ports, so it isn't registered and its address can't be resolved; publish its port or use --mesh consul-connect\n",
+		},
+		{
+			name:    "template to the registered port",
+			rewrite: AddressRewriteNomadTemplate,
+			env:     "http://api:8080",
+			want:    `URL=http://{{ with index (nomadService "api") 0 }}{{ .Address }}:{{ .Port }}{{ end }}`,
+		},
+		{
+			name:    "template to another port",
+			rewrite: AddressRewriteConsulTemplate,
+			env:     "http://api:9090",
+			want:    `URL=http://{{ with index (service "api") 0 }}{{ .Address }}:{{ .Port }}{{ end }}`,
+			note:    "# Addresses: api is registered with port 8080, so {{ .Port }} won't be 9090\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			services := []types.EnhancedServiceConfig{
+				{Name: "web", ResolvedImage: "example/web", Environment: map[string]string{"URL": test.env}},
+				{Name: "api", ResolvedImage: "example/api", ResolvedPorts: []types.PortMapping{{Host: 80, Container: 8080, Protocol: "tcp"}}},
+				{Name: "cache", ResolvedImage: "redis:7"},
+			}
+			outputDir := t.TempDir()
+			g := NewNomadGenerator(outputDir, types.GenerateOptions{AddressRewrite: test.rewrite})
+			if err := g.GenerateJobs(services); err != nil {
+				t.Fatalf("failed to generate jobs: %v", err)
+			}
+			job, err := os.ReadFile(filepath.Join(outputDir, "web.nomad.hcl"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(job), test.want) {
+				t.Errorf("job is missing %s\n--- got ---\n%s", test.want, job)
+			}
+			if test.note == "" && strings.Contains(string(job), "# Addresses:") {
+				t.Errorf("unexpected address note:\n%s", job)
+			}
+			if test.note != "" && !strings.Contains(string(job), test.note) {
+				t.Errorf("job header is missing %s\n--- got ---\n%s", test.note, job)
+			}
+		})
+	}
+}
+
+func TestServiceProvider(t *testing.T) {
+	scriptCheck := &types.HealthCheckConfig{Test: []interface{}{"CMD", "pg_isready", "-U", "app"}}
+	httpCheck := &types.HealthCheckConfig{Test: []interface{}{"CMD", "curl", "-f", "http://localhost:8080/health"}}
+
+	tests := []struct {
+		name        string
+		options     types.GenerateOptions
+		healthCheck *types.HealthCheckConfig
+		want        []string
+		wantWarning string
+	}{
+		{
+			name:        "consul runs script checks",
+			options:     types.GenerateOptions{},
+			healthCheck: scriptCheck,
+			want:        []string{`provider = "consul"`, `type     = "script"`, `command  = "pg_isready"`},
+		},
+		{
+			name:        "nomad falls back to tcp for script checks",
+			options:     types.GenerateOptions{ServiceProvider: ServiceProviderNomad},
+			healthCheck: scriptCheck,
+			want:        []string{`provider = "nomad"`, `type     = "tcp"`},
+			wantWarning: `web: healthcheck "pg_isready -U app" needs a script check, which the nomad provider doesn't support; using a TCP check instead`,
+		},
+		{
+			name:        "nomad keeps http checks",
+			options:     types.GenerateOptions{ServiceProvider: ServiceProviderNomad},
+			healthCheck: httpCheck,
+			want:        []string{`provider = "nomad"`, `type     = "http"`, `path     = "/health"`},
+		},
+		{
+			name:    "nomad templates look up nomad services",
+			options: types.GenerateOptions{ServiceProvider: ServiceProviderNomad, AddressRewrite: AddressRewriteNomadTemplate},
+			want: []string{
+				`provider = "nomad"`,
+				`DB_URL=postgres://{{ with index (nomadService "db") 0 }}{{ .Address }}:{{ .Port }}{{ end }}/app`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			services := []types.EnhancedServiceConfig{
+				{
+					Name:            "web",
+					ResolvedImage:   "example/web:1.0",
+					ResolvedPorts:   []types.PortMapping{{Host: 8080, Container: 8080, Protocol: "tcp"}},
+					Environment:     map[string]string{"DB_URL": "postgres://db:5432/app"},
+					OriginalService: types.DockerComposeService{HealthCheck: test.healthCheck},
+				},
+				{Name: "db", ResolvedImage: "postgres:16", ResolvedPorts: []types.PortMapping{{Host: 5432, Container: 5432, Protocol: "tcp"}}},
+			}
+			outputDir := t.TempDir()
+			g := NewNomadGenerator(outputDir, test.options)
+			g.ReportTo(io.Discard)
+			if err := g.GenerateJobs(services); err != nil {
+				t.Fatalf("failed to generate jobs: %v", err)
+			}
+			job, err := os.ReadFile(filepath.Join(outputDir, "web.nomad.hcl"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(string(job), want) {
+					t.Errorf("job is missing %s\n--- got ---\n%s", want, job)
+				}
+			}
+
+			found := test.wantWarning == ""
+			for _, warning := range g.warnings {
+				found = found || warning == test.wantWarning
+			}
+			if !found {
+				t.Errorf("expected warning %q, got %v", test.wantWarning, g.warnings)
+			}
+		})
+	}
+}
+
+func TestTraefikTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		ingress *types.IngressRoute
+		labels  map[string]string
+		want    []string
+	}{
+		{
+			name: "no ingress and no labels",
+			want: nil,
+		},
+		{
+			name:   "unrelated labels",
+			labels: map[string]string{"com.example.team": "data"},
+			want:   nil,
+		},
+		{
+			name:    "confirmed ingress",
+			ingress: &types.IngressRoute{Host: "app.example.com", Path: "/api"},
+			want:    []string{"traefik.enable=true", "traefik.http.routers.web.rule=Host(`app.example.com`) && PathPrefix(`/api`)"},
+		},
+		{
+			name:   "traefik labels without ingress",
+			labels: map[string]string{"traefik.http.routers.web.rule": "Host(`web.local`)", "traefik.enable": "true"},
+			want:   []string{"traefik.enable=true", "traefik.http.routers.web.rule=Host(`web.local`)"},
+		},
+		{
+			name:    "disabled by label",
+			ingress: &types.IngressRoute{Host: "app.example.com"},
+			labels:  map[string]string{"traefik.enable": "false"},
+			want:    nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewNomadGenerator(t.TempDir(), types.GenerateOptions{WithIngress: true, IngressMode: IngressTraefik})
+			service := types.EnhancedServiceConfig{
+				Name:            "web",
+				Ingress:         test.ingress,
+				ResolvedPorts:   []types.PortMapping{{Host: 8080, Container: 8080, Protocol: "tcp"}},
+				OriginalService: types.DockerComposeService{Labels: test.labels},
+			}
+			if got := g.ingressTags(service); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("tags = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestVolumeType(t *testing.T) {
+	tests := []struct {
+		name       string
+		volumeType string
+		mappings   map[string]types.NomadVolume
+		want       []string
+		unwanted   []string
+	}{
+		{
+			name:     "unset lists volumes in a comment",
+			want:     []string{"# Volumes detected: data:/data, ./conf:/etc/conf"},
+			unwanted: []string{`volume "data"`, "volume_mount"},
+		},
+		{
+			name:       "host",
+			volumeType: VolumeTypeHost,
+			want:       []string{"    volume \"data\" {\n      type   = \"host\"\n      source = \"data\"\n    }\n", "      volume_mount {\n        volume      = \"data\"\n", "# Volumes detected: ./conf:/etc/conf"},
+		},
+		{
+			name:       "docker",
+			volumeType: VolumeTypeDocker,
+			want:       []string{"type   = \"volume\"\n          source = \"data\"\n          target = \"/data\""},
+			unwanted:   []string{`volume "data"`},
+		},
+		{
+			name:     "x-nomad mapping without --volume-type",
+			mappings: map[string]types.NomadVolume{"data": {Type: "csi", Source: "app-data"}},
+			want:     []string{"type            = \"csi\"\n      source          = \"app-data\"", "volume_mount"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := types.EnhancedServiceConfig{
+				Name:          "app",
+				ResolvedImage: "example/app",
+				OriginalService: types.DockerComposeService{
+					Volumes: []string{"data:/data", "./conf:/etc/conf"},
+					XNomad:  &types.NomadExtension{Volumes: test.mappings},
+				},
+			}
+			outputDir := t.TempDir()
+			if err := NewNomadGenerator(outputDir, types.GenerateOptions{VolumeType: test.volumeType}).GenerateJobs([]types.EnhancedServiceConfig{service}); err != nil {
+				t.Fatalf("failed to generate jobs: %v", err)
+			}
+			job, err := os.ReadFile(filepath.Join(outputDir, "app.nomad.hcl"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(string(job), want) {
+					t.Errorf("job is missing:\n%s\n--- got ---\n%s", want, job)
+				}
+			}
+			for _, unwanted := range test.unwanted {
+				if strings.Contains(string(job), unwanted) {
+					t.Errorf("job should not contain %s\n--- got ---\n%s", unwanted, job)
+				}
+			}
+		})
+	}
+}
+
+func TestKubernetesPortsAndClaims(t *testing.T) {
+	manifest := filepath.Join(t.TempDir(), "app.yaml")
+	if err := os.WriteFile(manifest, []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata: {name: web}
+spec:
+  replicas: 3
+  template:
+    metadata:
+      labels: {app: web}
+    spec:
+      containers:
+        - name: web
+          image: example/web:1.0
+          ports:
+            - {name: http, containerPort: 8080}
+            - {name: metrics, containerPort: 9090}
+          volumeMounts: [{name: data, mountPath: /data}]
+      volumes:
+        - name: data
+          persistentVolumeClaim: {claimName: web-data}
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata: {name: web-data}
+spec:
+  accessModes: [ReadWriteOnce]
+---
+apiVersion: v1
+kind: Service
+metadata: {name: web}
+spec:
+  selector: {app: web}
+  ports: [{port: 80, targetPort: http}]
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	services, err := parser.NewKubernetesParser().Parse(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outputDir := t.TempDir()
+	g := NewNomadGenerator(outputDir, types.GenerateOptions{})
+	if err := g.GenerateJobs(services); err != nil {
+		t.Fatalf("failed to generate jobs: %v", err)
+	}
+	job, err := os.ReadFile(filepath.Join(outputDir, "web.nomad.hcl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"      port \"http\" {\n        static = 80\n        to     = 8080\n      }\n",
+		"      port \"port_9090\" {\n        to = 9090\n      }\n",
+		"# Ports: 80, 9090 (dynamic)\n",
+	} {
+		if !strings.Contains(string(job), want) {
+			t.Errorf("job is missing:\n%s\n--- got ---\n%s", want, job)
+		}
+	}
+
+	found := false
+	for _, warning := range g.warnings {
+		found = found || strings.Contains(warning, `CSI volume "web-data" is single-node-writer but count is 3`)
+	}
+	if !found {
+		t.Errorf("expected a warning about a single-node-writer claim shared by 3 allocations, got %v", g.warnings)
+	}
+}
+
+func TestSchedulingPolicyRestart(t *testing.T) {
+	tests := []struct {
+		name        string
+		restart     string
+		policy      *types.RestartPolicyConfig
+		wantType    string
+		wantRestart *restartPolicy
+		wantNote    string
+	}{
+		{
+			name:        "no",
+			restart:     "no",
+			wantType:    "batch",
+			wantRestart: &restartPolicy{Attempts: 0, Mode: "fail"},
+		},
+		{
+			name:        "unless-stopped",
+			restart:     "unless-stopped",
+			wantType:    "service",
+			wantRestart: &restartPolicy{Attempts: 3, Interval: "5m", Delay: "15s", Mode: "delay"},
+		},
+		{
+			name:        "on-failure with attempts",
+			restart:     "on-failure:5",
+			wantType:    "service",
+			wantRestart: &restartPolicy{Attempts: 5, Interval: "30m", Delay: "15s", Mode: "fail"},
+		},
+		{
+			name:        "unset",
+			wantType:    "service",
+			wantRestart: nil,
+		},
+		{
+			name:        "restart_policy none wins over restart",
+			restart:     "always",
+			policy:      &types.RestartPolicyConfig{Condition: "none"},
+			wantType:    "batch",
+			wantRestart: &restartPolicy{Attempts: 0, Mode: "fail"},
+		},
+		{
+			name:        "restart_policy on-failure",
+			policy:      &types.RestartPolicyConfig{Condition: "on-failure", Delay: "10s", MaxAttempts: 4, Window: "2m"},
+			wantType:    "service",
+			wantRestart: &restartPolicy{Attempts: 4, Interval: "2m", Delay: "10s", Mode: "fail"},
+		},
+		{
+			name:        "restart_policy any with max_attempts",
+			policy:      &types.RestartPolicyConfig{Condition: "any", MaxAttempts: 2},
+			wantType:    "service",
+			wantRestart: &restartPolicy{Attempts: 2, Interval: "5m", Delay: "15s", Mode: "fail"},
+		},
+		{
+			name:        "window shorter than attempts",
+			policy:      &types.RestartPolicyConfig{Condition: "on-failure", Delay: "30s", MaxAttempts: 5, Window: "1m"},
+			wantType:    "service",
+			wantRestart: &restartPolicy{Attempts: 5, Interval: "2m30s", Delay: "30s", Mode: "fail"},
+			wantNote:    "restart_policy.window: 1m raised to 2m30s to fit 5 attempts × 30s delay",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := types.EnhancedServiceConfig{Name: "app", OriginalService: types.DockerComposeService{Restart: test.restart}}
+			if test.policy != nil {
+				service.OriginalService.Deploy = &types.DeployConfig{RestartPolicy: test.policy}
+			}
+
+			g := NewNomadGenerator(t.TempDir(), types.GenerateOptions{})
+			policy := g.buildSchedulingPolicy(service)
+			if policy.JobType != test.wantType {
+				t.Errorf("job type = %q, want %q", policy.JobType, test.wantType)
+			}
+			if !reflect.DeepEqual(policy.Restart, test.wantRestart) {
+				t.Errorf("restart = %+v, want %+v", policy.Restart, test.wantRestart)
+			}
+			if !strings.Contains(strings.Join(policy.Notes, "\n"), test.wantNote) {
+				t.Errorf("notes = %q, want %q", policy.Notes, test.wantNote)
+			}
+		})
+	}
+}
+
+func TestSchedulingPolicyUpdate(t *testing.T) {
+	tests := []struct {
+		name     string
+		replicas int
+		update   *types.UpdateConfig
+		rollback *types.UpdateConfig
+		restart  string
+		want     *updatePolicy
+	}{
+		{
+			name:     "no update_config",
+			replicas: 3,
+			want:     nil,
+		},
+		{
+			name:     "parallelism unset defaults to 1",
+			replicas: 4,
+			update:   &types.UpdateConfig{Delay: "10s"},
+			want:     &updatePolicy{MaxParallel: 1, Stagger: "10s"},
+		},
+		{
+			name:     "parallelism 0 updates all at once",
+			replicas: 4,
+			update:   &types.UpdateConfig{Parallelism: intPtr(0)},
+			want:     &updatePolicy{MaxParallel: 4},
+		},
+		{
+			name:     "parallelism",
+			replicas: 4,
+			update:   &types.UpdateConfig{Parallelism: intPtr(2), Monitor: "30s"},
+			want:     &updatePolicy{MaxParallel: 2, MinHealthyTime: "30s"},
+		},
+		{
+			name:     "start-first with unset parallelism",
+			replicas: 4,
+			update:   &types.UpdateConfig{Order: "start-first"},
+			want:     &updatePolicy{MaxParallel: 1, Canary: 1, AutoPromote: true},
+		},
+		{
+			name:     "failure_action rollback",
+			replicas: 2,
+			update:   &types.UpdateConfig{Parallelism: intPtr(1), FailureAction: "rollback"},
+			want:     &updatePolicy{MaxParallel: 1, AutoRevert: true},
+		},
+		{
+			name:     "long monitor raises deadlines",
+			replicas: 2,
+			update:   &types.UpdateConfig{Monitor: "6m"},
+			want:     &updatePolicy{MaxParallel: 1, MinHealthyTime: "6m", HealthyDeadline: "11m", ProgressDeadline: "16m"},
+		},
+		{
+			name:     "rollback_config only",
+			replicas: 2,
+			rollback: &types.UpdateConfig{Parallelism: intPtr(1)},
+			want:     &updatePolicy{MaxParallel: 1, AutoRevert: true},
+		},
+		{
+			name:     "batch jobs get no update",
+			replicas: 2,
+			restart:  "no",
+			update:   &types.UpdateConfig{Parallelism: intPtr(1)},
+			want:     nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := types.EnhancedServiceConfig{
+				Name: "app",
+				OriginalService: types.DockerComposeService{
+					Restart: test.restart,
+					Deploy:  &types.DeployConfig{Replicas: test.replicas, UpdateConfig: test.update, RollbackConfig: test.rollback},
+				},
+			}
+			policy := NewNomadGenerator(t.TempDir(), types.GenerateOptions{}).buildSchedulingPolicy(service)
+			if !reflect.DeepEqual(policy.Update, test.want) {
+				t.Errorf("update = %+v, want %+v", policy.Update, test.want)
+			}
+		})
+	}
+}
+
+func TestComposeRoundTrip(t *testing.T) {
+	golden, err := filepath.Glob(filepath.Join("testdata", "basic", "*.nomad.hcl.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jobs []types.NomadJob
+	for _, path := range golden {
+		job, err := parser.NewNomadJobParser().Parse(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jobs = append(jobs, *job)
+	}
+
+	composeGenerator := NewComposeGenerator()
+	compose, err := composeGenerator.Generate(jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var file composeFile
+	if err := yaml.Unmarshal([]byte(compose), &file); err != nil {
+		t.Fatalf("invalid compose file: %v\n%s", err, compose)
+	}
+	if len(file.Services) != 4 {
+		t.Fatalf("expected 4 services, got %d:\n%s", len(file.Services), compose)
+	}
+
+	api := file.Services["api"]
+	if api == nil || api.Image != "example/api:2.1.0" || !reflect.DeepEqual(api.Ports, []string{"3000:3000"}) {
+		t.Fatalf("unexpected api service %+v", api)
+	}
+	if api.Environment["REDIS_HOST"] != "cache" || api.Environment["DATABASE_URL"] != "postgres://app@db:5432/app" {
+		t.Errorf("Consul addresses should point at compose services, got %v", api.Environment)
+	}
+	if api.Healthcheck == nil || !reflect.DeepEqual(api.Healthcheck.Test, []string{"CMD", "curl", "-f", "http://localhost:3000/health"}) {
+		t.Errorf("unexpected healthcheck %+v", api.Healthcheck)
+	}
+	if api.Deploy == nil || api.Deploy.Replicas != 2 {
+		t.Errorf("unexpected deploy %+v", api.Deploy)
+	}
+
+	web := file.Services["web"]
+	if want := map[string]composeDependency{"api": {Condition: "service_healthy"}}; !reflect.DeepEqual(web.DependsOn, want) {
+		t.Errorf("depends_on = %v, want %v", web.DependsOn, want)
+	}
+	if len(composeGenerator.Notes()) == 0 {
+		t.Errorf("tcp checks should be listed as not converted")
+	}
+}
+
+func TestComposeTasksAndTemplates(t *testing.T) {
+	jobs := []types.NomadJob{
+		{
+			Name: "shop",
+			Type: "service",
+			Groups: []types.JobGroup{{
+				Name:    "shop",
+				Count:   1,
+				Ports:   []types.JobPort{{Label: "http", To: 8000}},
+				Volumes: map[string]types.JobVolume{"data": {Type: "host", Source: "shop-data"}},
+				Tasks: []types.JobTask{
+					{Name: "migrate", Driver: "docker", Image: "example/shop:1.0", Command: "./migrate", Lifecycle: "prestart"},
+					{
+						Name:         "web",
+						Driver:       "docker",
+						Image:        "example/shop:1.0",
+						Ports:        []string{"http"},
+						Args:         []string{"--port", "${NOMAD_PORT_http}"},
+						Mounts:       []string{"local/app.conf:/etc/app.conf"},
+						VolumeMounts: []types.JobVolumeMount{{Volume: "data", Destination: "/data"}},
+						Env:          map[string]string{"ALLOC": "${NOMAD_ALLOC_ID}"},
+						Templates: []types.JobTemplate{
+							{Destination: "local/app.conf", Data: "cost = $5\n"},
+							{Destination: "secrets/env", Env: true, Data: "DB_HOST={{ range service \"pg\" }}{{ .Address }}{{ end }}\n"},
+						},
+					},
+					{Name: "agent", Driver: "exec"},
+				},
+			}},
+		},
+		{
+			Name:   "pg",
+			Type:   "service",
+			Groups: []types.JobGroup{{Name: "pg", Count: 1, Services: []types.JobService{{Name: "pg"}}, Tasks: []types.JobTask{{Name: "pg", Driver: "docker", Image: "postgres:16"}}}},
+		},
+	}
+
+	composeGenerator := NewComposeGenerator()
+	compose, err := composeGenerator.Generate(jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file composeFile
+	if err := yaml.Unmarshal([]byte(compose), &file); err != nil {
+		t.Fatalf("invalid compose file: %v\n%s", err, compose)
+	}
+
+	web, migrate := file.Services["shop"], file.Services["shop-migrate"]
+	if web == nil || migrate == nil || file.Services["shop-agent"] != nil {
+		t.Fatalf("unexpected services:\n%s", compose)
+	}
+	if migrate.Restart != "no" {
+		t.Errorf("init task restart = %q, want no", migrate.Restart)
+	}
+	if !reflect.DeepEqual(web.Command, []string{"--port", "8000"}) || !reflect.DeepEqual(web.Ports, []string{"8000"}) {
+		t.Errorf("unexpected command %v or ports %v", web.Command, web.Ports)
+	}
+	if web.Environment["DB_HOST"] != "pg" || web.Environment["ALLOC"] != "$${NOMAD_ALLOC_ID}" {
+		t.Errorf("environment = %v", web.Environment)
+	}
+	want := map[string]composeDependency{
+		"pg":           {Condition: "service_started"},
+		"shop-migrate": {Condition: "service_completed_successfully"},
+	}
+	if !reflect.DeepEqual(web.DependsOn, want) {
+		t.Errorf("depends_on = %v, want %v", web.DependsOn, want)
+	}
+	if len(web.Configs) != 1 || web.Configs[0].Target != "/etc/app.conf" || file.Configs[web.Configs[0].Source].Content != "cost = $$5\n" {
+		t.Errorf("unexpected configs %+v, %+v", web.Configs, file.Configs)
+	}
+	if !reflect.DeepEqual(web.Volumes, []string{"shop-data:/data"}) || file.Volumes["shop-data"] == nil {
+		t.Errorf("volumes = %v, want the named volume only; template mounts become configs", web.Volumes)
+	}
+
+	notes := strings.Join(composeGenerator.Notes(), "\n")
+	for _, want := range []string{"agent uses the exec driver", "${NOMAD_ALLOC_ID}"} {
+		if !strings.Contains(notes, want) {
+			t.Errorf("notes should mention %q, got:\n%s", want, notes)
+		}
+	}
+}
+
+func TestComposeEscapesDollars(t *testing.T) {
+	jobs := []types.NomadJob{{
+		Name: "shop",
+		Type: "service",
+		Groups: []types.JobGroup{{
+			Name:    "shop",
+			Count:   1,
+			Volumes: map[string]types.JobVolume{"data": {Type: "host", Source: "data-$ENV"}},
+			Tasks: []types.JobTask{
+				{
+					Name:         "web",
+					Driver:       "docker",
+					Image:        "example/${NOMAD_JOB_NAME}:$TAG",
+					WorkDir:      "/srv/$APP",
+					Labels:       map[string]string{"price": "$5"},
+					Mounts:       []string{"/var/$USER:/home", "cache-$ENV:/cache"},
+					VolumeMounts: []types.JobVolumeMount{{Volume: "data", Destination: "/data"}},
+				},
+			},
+		}},
+	}}
+
+	compose, err := NewComposeGenerator().Generate(jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file composeFile
+	if err := yaml.Unmarshal([]byte(compose), &file); err != nil {
+		t.Fatalf("invalid compose file: %v\n%s", err, compose)
+	}
+
+	web := file.Services["shop"]
+	if web == nil {
+		t.Fatalf("unexpected services:\n%s", compose)
+	}
+	if web.Image != "example/shop:$$TAG" {
+		t.Errorf("image = %q, want runtime variables resolved and the rest escaped", web.Image)
+	}
+	if web.WorkingDir != "/srv/$$APP" || web.Labels["price"] != "$$5" {
+		t.Errorf("working_dir = %q, labels = %v", web.WorkingDir, web.Labels)
+	}
+	want := []string{"data-$$ENV:/data", "/var/$$USER:/home", "cache-$$ENV:/cache"}
+	if !reflect.DeepEqual(web.Volumes, want) {
+		t.Errorf("volumes = %v, want %v", web.Volumes, want)
+	}
+}
+
+func TestGenerateBuildFiles(t *testing.T) {
+	services := []types.EnhancedServiceConfig{
+		{
+			Name:          "api",
+			ResolvedImage: "registry.example.com/api:abc123",
+			Build: &types.BuildConfig{
+				Context:    "services/api",
+				Dockerfile: "Dockerfile.prod",
+				Target:     "runtime",
+				Args:       map[string]string{"VERSION": "1.2", "GREETING": "hello world"},
+				Platforms:  []string{"linux/amd64", "linux/arm64"},
+				Secrets:    []types.BuildSecret{{ID: "token", Environment: "API_TOKEN"}},
+			},
+		},
+		{
+			Name:          "shop",
+			ResolvedImage: "registry.example.com/shop:abc123",
+			Build:         &types.BuildConfig{Builder: types.BuilderBuildpacks, Context: "shop"},
+		},
+		{
+			Name:          "shop-worker",
+			ResolvedImage: "registry.example.com/shop:abc123",
+			Build:         &types.BuildConfig{Builder: types.BuilderBuildpacks, Context: "shop"},
+		},
+		{Name: "db", ResolvedImage: "postgres:16"},
+	}
+
+	outputDir := t.TempDir()
+	if err := NewNomadGenerator(outputDir, types.GenerateOptions{}).GenerateJobs(services); err != nil {
+		t.Fatalf("failed to generate jobs: %v", err)
+	}
+
+	script, err := os.ReadFile(filepath.Join(outputDir, "build.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(script), "#!/bin/sh\n"+checksumPrefix) {
+		t.Errorf("build.sh should start with the shebang, then the checksum:\n%s", script)
+	}
+	for _, want := range []string{
+		"docker buildx build $output \\\n  --file services/api/Dockerfile.prod \\\n  --target runtime \\\n" +
+			"  --build-arg 'GREETING=hello world' \\\n  --build-arg VERSION=1.2 \\\n  --platform linux/amd64,linux/arm64 \\\n" +
+			"  --secret id=token,env=API_TOKEN \\\n  --tag registry.example.com/api:abc123 \\\n  services/api\n",
+		"pack build registry.example.com/shop:abc123 $publish \\\n  --path shop \\\n",
+	} {
+		if !strings.Contains(string(script), want) {
+			t.Errorf("build.sh is missing:\n%s\n--- got ---\n%s", want, script)
+		}
+	}
+	if strings.Count(string(script), "pack build") != 1 {
+		t.Errorf("services sharing an image should be built once:\n%s", script)
+	}
+	if info, err := os.Stat(filepath.Join(outputDir, "build.sh")); err != nil || info.Mode().Perm()&0100 == 0 {
+		t.Errorf("build.sh should be executable")
+	}
+
+	bake, err := os.ReadFile(filepath.Join(outputDir, "docker-bake.hcl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`targets = ["api"]`,
+		`context    = "services/api"`,
+		`dockerfile = "Dockerfile.prod"`,
+		`tags       = ["registry.example.com/api:abc123"]`,
+		`secret     = ["id=token,env=API_TOKEN"]`,
+		"# shop is built with buildpacks; see build.sh",
+	} {
+		if !strings.Contains(string(bake), want) {
+			t.Errorf("docker-bake.hcl is missing %q:\n%s", want, bake)
+		}
+	}
+
+	// A second run recognises its own files, shebang and all
+	status, err := checkExisting(filepath.Join(outputDir, "build.sh"), string(script)+"# edited\n")
+	if err != nil || status != fileUpdated {
+		t.Errorf("checkExisting = %q, %v; want %q", status, err, fileUpdated)
+	}
+}
+
+// generate parses a compose file and returns every generated file by name
+func generate(t *testing.T, composeFile string, options types.GenerateOptions) map[string]string {
+	t.Helper()
+
+	services, err := parser.NewDockerComposeParser().Parse(composeFile)
+	if err != nil {
+		t.Fatalf("failed to parse %s: %v", composeFile, err)
+	}
+
+	outputDir := t.TempDir()
+	if err := NewNomadGenerator(outputDir, options).GenerateJobs(services); err != nil {
+		t.Fatalf("failed to generate jobs: %v", err)
+	}
+
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(outputDir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[entry.Name()] = string(data)
+	}
+	return files
+}
+
+func writeGolden(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	stale, err := filepath.Glob(filepath.Join(dir, "*.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range stale {
+		if err := os.Remove(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name+".golden"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func equalFiles(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, content := range a {
+		if b[name] != content {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedFileNames(files map[string]string) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
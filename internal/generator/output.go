@@ -0,0 +1,153 @@
+package generator
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// First line of every generated file; the checksum covers everything after it
+const checksumPrefix = "# nompose-checksum: sha256:"
+
+// Separates files when every job is streamed to one output
+const streamSeparator = "---\n"
+
+// Names the file each streamed document would be written to
+const streamFileMarker = "# file: "
+
+// generatedFile is a file waiting to be written by flush
+type generatedFile struct {
+	Name    string
+	Content string
+}
+
+// fileStatus is what flush will do with a generated file
+type fileStatus string
+
+const (
+	fileNew       fileStatus = "new"
+	fileUpdated   fileStatus = "updated"
+	fileUnchanged fileStatus = "unchanged"
+	fileEdited    fileStatus = "hand-edited"
+)
+
+// StreamTo sends every generated file to w, separated by "---" lines and
+// each preceded by a "# file: <name>" line, instead of writing them into
+// the output directory
+func (g *NomadGenerator) StreamTo(w io.Writer) {
+	g.stream = w
+}
+
+// withChecksum prefixes content with the checksum header used to detect hand
+// edits; a script's #! line stays first
+func withChecksum(content string) string {
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	shebang, content := splitShebang(content)
+	return fmt.Sprintf("%s%s%x\n%s", shebang, checksumPrefix, sha256.Sum256([]byte(content)), content)
+}
+
+// splitShebang separates a leading #! line from the rest of content
+func splitShebang(content string) (string, string) {
+	if !strings.HasPrefix(content, "#!") {
+		return "", content
+	}
+	line, rest, _ := strings.Cut(content, "\n")
+	return line + "\n", rest
+}
+
+// checkExisting compares a file on disk with the content about to replace it
+func checkExisting(path, content string) (fileStatus, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fileNew, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	existing := string(data)
+	if existing == content {
+		return fileUnchanged, nil
+	}
+
+	_, existing = splitShebang(existing)
+	header, body, found := strings.Cut(existing, "\n")
+	if !found || !strings.HasPrefix(header, checksumPrefix) {
+		// Written by hand, or by a nompose version without checksums
+		return fileEdited, nil
+	}
+	if strings.TrimPrefix(header, checksumPrefix) != fmt.Sprintf("%x", sha256.Sum256([]byte(body))) {
+		return fileEdited, nil
+	}
+	return fileUpdated, nil
+}
+
+// flush writes every pending file, refusing to overwrite hand-edited files
+// unless forced. Nothing is written when any file would be refused.
+func (g *NomadGenerator) flush() error {
+	if g.stream != nil {
+		out := bufio.NewWriter(g.stream)
+		for i, file := range g.pending {
+			if i > 0 {
+				out.WriteString(streamSeparator)
+			}
+			out.WriteString(streamFileMarker + file.Name + "\n")
+			out.WriteString(file.Content)
+		}
+		return out.Flush()
+	}
+
+	statuses := make([]fileStatus, len(g.pending))
+	var refused []string
+	for i, file := range g.pending {
+		status, err := checkExisting(filepath.Join(g.outputDir, file.Name), file.Content)
+		if err != nil {
+			return err
+		}
+		statuses[i] = status
+		if status == fileEdited && !g.options.Force {
+			refused = append(refused, file.Name)
+		}
+	}
+
+	if g.options.DryRun {
+		fmt.Fprintf(g.progress, "\n🔎 Dry run - nothing written to %s:\n", g.outputDir)
+		for i, file := range g.pending {
+			action := string(statuses[i])
+			if statuses[i] == fileEdited {
+				action += ", would need --force"
+			}
+			fmt.Fprintf(g.progress, "   %s (%s)\n", filepath.Join(g.outputDir, file.Name), action)
+		}
+		return nil
+	}
+
+	if len(refused) > 0 {
+		return fmt.Errorf("refusing to overwrite files edited since nompose generated them: %s (use --force to overwrite)", strings.Join(refused, ", "))
+	}
+
+	if err := os.MkdirAll(g.outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	for i, file := range g.pending {
+		if statuses[i] == fileUnchanged {
+			continue
+		}
+		mode := os.FileMode(0644)
+		if strings.HasPrefix(file.Content, "#!") {
+			mode = 0755
+		}
+		if err := os.WriteFile(filepath.Join(g.outputDir, file.Name), []byte(file.Content), mode); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.Name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,344 @@
+package generator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+// Restart, reschedule and update policy conversion.
+//
+// Docker Compose describes restart behaviour in two places: the top-level
+// `restart` key and the swarm-style `deploy.restart_policy`. When both are
+// present, deploy.restart_policy wins, as it does in Compose itself.
+//
+//   restart: "no" / condition: none
+//       → type = "batch", restart { attempts = 0, mode = "fail" } and
+//         reschedule { attempts = 0, unlimited = false }: the task runs once.
+//   restart: always / unless-stopped / condition: any
+//       → restart { mode = "delay" } so the task is restarted in place
+//         forever, plus unlimited exponential rescheduling.
+//   restart: on-failure[:N] / condition: on-failure
+//       → restart { attempts = N (default 3), mode = "fail" } followed by
+//         unlimited exponential rescheduling.
+//   restart_policy.delay → restart.delay
+//   restart_policy.max_attempts → restart.attempts
+//   restart_policy.window → restart.interval
+//
+// deploy.update_config becomes the group `update` stanza:
+//
+//   parallelism → max_parallel (compose defaults to 1; 0 means all at once)
+//   delay → stagger
+//   monitor → min_healthy_time (healthy_deadline is raised to fit)
+//   failure_action: rollback → auto_revert = true
+//   order: start-first → canary = max_parallel, auto_promote = true
+//
+// deploy.rollback_config has no Nomad equivalent: Nomad rolls back with the
+// update stanza settings, so its presence only turns on auto_revert.
+// Batch jobs never get an update stanza because Nomad rejects it.
+
+// Nomad's default healthy_deadline and progress_deadline
+const (
+	defaultHealthyDeadline  = 5 * time.Minute
+	defaultProgressDeadline = 10 * time.Minute
+)
+
+// schedulingPolicy holds the converted restart, reschedule and update stanzas
+type schedulingPolicy struct {
+	JobType    string
+	Restart    *restartPolicy
+	Reschedule *reschedulePolicy
+	Update     *updatePolicy
+	Notes      []string // Human readable mapping, written to the job header
+}
+
+type restartPolicy struct {
+	Attempts int
+	Interval string
+	Delay    string
+	Mode     string
+}
+
+type reschedulePolicy struct {
+	Attempts      int
+	Delay         string
+	DelayFunction string
+	MaxDelay      string
+	Unlimited     bool
+}
+
+type updatePolicy struct {
+	MaxParallel      int
+	Stagger          string
+	MinHealthyTime   string
+	HealthyDeadline  string
+	ProgressDeadline string
+	AutoRevert       bool
+	Canary           int
+	AutoPromote      bool
+}
+
+// buildSchedulingPolicy converts compose restart/deploy settings into Nomad policies
+func (g *NomadGenerator) buildSchedulingPolicy(service types.EnhancedServiceConfig) schedulingPolicy {
+	policy := schedulingPolicy{JobType: "service"}
+	original := service.OriginalService
+
+	var restartPolicyConfig *types.RestartPolicyConfig
+	if original.Deploy != nil {
+		restartPolicyConfig = original.Deploy.RestartPolicy
+	}
+
+	switch {
+	case restartPolicyConfig != nil:
+		g.applyDeployRestartPolicy(&policy, restartPolicyConfig)
+		g.fitRestartWindow(&policy)
+	case original.Restart != "":
+		g.applyComposeRestart(&policy, original.Restart)
+	}
+
+	if policy.JobType == "service" && original.Deploy != nil {
+		policy.Update = g.buildUpdatePolicy(&policy, original.Deploy, g.getReplicas(service))
+	}
+
+	g.applyExtensionPolicy(&policy, service)
+
+	return policy
+}
+
+// applyComposeRestart maps the top-level `restart` key
+func (g *NomadGenerator) applyComposeRestart(policy *schedulingPolicy, restart string) {
+	condition, maxAttempts := restart, 0
+	if strings.HasPrefix(restart, "on-failure:") {
+		condition = "on-failure"
+		maxAttempts, _ = strconv.Atoi(strings.TrimPrefix(restart, "on-failure:"))
+	}
+
+	switch condition {
+	case "no", "none":
+		g.applyRunOnce(policy)
+		policy.Notes = append(policy.Notes, fmt.Sprintf(`restart: "%s" → batch job, no restarts`, restart))
+	case "always", "unless-stopped":
+		g.applyRestartAlways(policy, "", "")
+		policy.Notes = append(policy.Notes, fmt.Sprintf(`restart: %s → restart mode "delay", unlimited reschedule`, restart))
+	case "on-failure":
+		g.applyRestartOnFailure(policy, maxAttempts, "", "")
+		policy.Notes = append(policy.Notes, fmt.Sprintf(`restart: %s → %d restart attempts, then reschedule`, restart, policy.Restart.Attempts))
+	default:
+		policy.Notes = append(policy.Notes, fmt.Sprintf(`restart: %s → not recognised, Nomad defaults kept`, restart))
+	}
+}
+
+// applyDeployRestartPolicy maps deploy.restart_policy
+func (g *NomadGenerator) applyDeployRestartPolicy(policy *schedulingPolicy, config *types.RestartPolicyConfig) {
+	switch config.Condition {
+	case "none":
+		g.applyRunOnce(policy)
+		policy.Notes = append(policy.Notes, "restart_policy: none → batch job, no restarts")
+	case "on-failure":
+		g.applyRestartOnFailure(policy, config.MaxAttempts, config.Delay, config.Window)
+		policy.Notes = append(policy.Notes, fmt.Sprintf(`restart_policy: on-failure → %d restart attempts, then reschedule`, policy.Restart.Attempts))
+	default:
+		// "any" is the compose default condition
+		g.applyRestartAlways(policy, config.Delay, config.Window)
+		if config.MaxAttempts > 0 {
+			policy.Restart.Attempts = config.MaxAttempts
+			policy.Restart.Mode = "fail"
+		}
+		policy.Notes = append(policy.Notes, fmt.Sprintf(`restart_policy: any → restart mode "%s", unlimited reschedule`, policy.Restart.Mode))
+	}
+}
+
+// fitRestartWindow raises the restart interval to fit the restart attempts;
+// Nomad rejects a restart block whose attempts don't fit in its interval
+func (g *NomadGenerator) fitRestartWindow(policy *schedulingPolicy) {
+	restart := policy.Restart
+	if restart == nil || restart.Attempts == 0 {
+		return
+	}
+	delay, err := time.ParseDuration(restart.Delay)
+	if err != nil {
+		return
+	}
+	interval, err := time.ParseDuration(restart.Interval)
+	if err != nil {
+		return
+	}
+	if needed := delay * time.Duration(restart.Attempts); interval < needed {
+		policy.Notes = append(policy.Notes, fmt.Sprintf("restart_policy.window: %s raised to %s to fit %d attempts × %s delay",
+			restart.Interval, formatDuration(needed), restart.Attempts, restart.Delay))
+		restart.Interval = formatDuration(needed)
+	}
+}
+
+func (g *NomadGenerator) applyRunOnce(policy *schedulingPolicy) {
+	policy.JobType = "batch"
+	policy.Restart = &restartPolicy{Attempts: 0, Mode: "fail"}
+	policy.Reschedule = &reschedulePolicy{Attempts: 0, Unlimited: false}
+}
+
+func (g *NomadGenerator) applyRestartAlways(policy *schedulingPolicy, delay, window string) {
+	policy.Restart = &restartPolicy{
+		Attempts: 3,
+		Interval: valueOrDefault(window, "5m"),
+		Delay:    valueOrDefault(delay, "15s"),
+		Mode:     "delay",
+	}
+	policy.Reschedule = defaultReschedulePolicy()
+}
+
+func (g *NomadGenerator) applyRestartOnFailure(policy *schedulingPolicy, attempts int, delay, window string) {
+	if attempts <= 0 {
+		attempts = 3
+	}
+	policy.Restart = &restartPolicy{
+		Attempts: attempts,
+		Interval: valueOrDefault(window, "30m"),
+		Delay:    valueOrDefault(delay, "15s"),
+		Mode:     "fail",
+	}
+	policy.Reschedule = defaultReschedulePolicy()
+}
+
+func defaultReschedulePolicy() *reschedulePolicy {
+	return &reschedulePolicy{
+		Delay:         "30s",
+		DelayFunction: "exponential",
+		MaxDelay:      "1h",
+		Unlimited:     true,
+	}
+}
+
+// buildUpdatePolicy maps deploy.update_config and deploy.rollback_config
+func (g *NomadGenerator) buildUpdatePolicy(policy *schedulingPolicy, deploy *types.DeployConfig, count int) *updatePolicy {
+	if deploy.UpdateConfig == nil && deploy.RollbackConfig == nil {
+		return nil
+	}
+
+	update := &updatePolicy{MaxParallel: 1}
+
+	if config := deploy.UpdateConfig; config != nil {
+		if parallelism := config.Parallelism; parallelism != nil && *parallelism > 0 {
+			update.MaxParallel = *parallelism
+		} else if parallelism != nil && count > 1 {
+			// parallelism: 0 means "all at once" in compose
+			update.MaxParallel = count
+		}
+		update.Stagger = config.Delay
+		update.MinHealthyTime = config.Monitor
+
+		if monitor, err := time.ParseDuration(config.Monitor); err == nil && monitor >= defaultHealthyDeadline {
+			healthy := monitor + defaultHealthyDeadline
+			update.HealthyDeadline = formatDuration(healthy)
+			if healthy >= defaultProgressDeadline {
+				update.ProgressDeadline = formatDuration(healthy + defaultHealthyDeadline)
+			}
+		}
+
+		switch config.FailureAction {
+		case "rollback":
+			update.AutoRevert = true
+			policy.Notes = append(policy.Notes, "update_config.failure_action: rollback → auto_revert = true")
+		case "pause", "continue":
+			policy.Notes = append(policy.Notes, fmt.Sprintf("update_config.failure_action: %s → deployment fails and waits for an operator", config.FailureAction))
+		}
+
+		if config.Order == "start-first" {
+			update.Canary = update.MaxParallel
+			update.AutoPromote = true
+			policy.Notes = append(policy.Notes, fmt.Sprintf("update_config.order: start-first → %d auto-promoted canaries", update.Canary))
+		}
+	}
+
+	if deploy.RollbackConfig != nil {
+		if !update.AutoRevert {
+			policy.Notes = append(policy.Notes, "rollback_config → auto_revert = true (Nomad reverts with the update settings)")
+		}
+		update.AutoRevert = true
+	}
+
+	return update
+}
+
+// generatePolicyConfig renders the restart, reschedule and update stanzas for a group
+func (g *NomadGenerator) generatePolicyConfig(policy schedulingPolicy) string {
+	var config strings.Builder
+
+	if restart := policy.Restart; restart != nil {
+		attrs := []hclAttribute{{"attempts", fmt.Sprintf("%d", restart.Attempts)}}
+		if restart.Interval != "" {
+			attrs = append(attrs, hclAttribute{"interval", quote(restart.Interval)})
+		}
+		if restart.Delay != "" {
+			attrs = append(attrs, hclAttribute{"delay", quote(restart.Delay)})
+		}
+		attrs = append(attrs, hclAttribute{"mode", quote(restart.Mode)})
+		writeBlock(&config, "    ", "restart", attrs)
+	}
+
+	if reschedule := policy.Reschedule; reschedule != nil {
+		var attrs []hclAttribute
+		if reschedule.Unlimited {
+			attrs = []hclAttribute{
+				{"delay", quote(reschedule.Delay)},
+				{"delay_function", quote(reschedule.DelayFunction)},
+				{"max_delay", quote(reschedule.MaxDelay)},
+				{"unlimited", "true"},
+			}
+		} else {
+			attrs = []hclAttribute{
+				{"attempts", fmt.Sprintf("%d", reschedule.Attempts)},
+				{"unlimited", "false"},
+			}
+		}
+		writeBlock(&config, "    ", "reschedule", attrs)
+	}
+
+	if update := policy.Update; update != nil {
+		attrs := []hclAttribute{{"max_parallel", fmt.Sprintf("%d", update.MaxParallel)}}
+		if update.Stagger != "" {
+			attrs = append(attrs, hclAttribute{"stagger", quote(update.Stagger)})
+		}
+		if update.MinHealthyTime != "" {
+			attrs = append(attrs, hclAttribute{"min_healthy_time", quote(update.MinHealthyTime)})
+		}
+		if update.HealthyDeadline != "" {
+			attrs = append(attrs, hclAttribute{"healthy_deadline", quote(update.HealthyDeadline)})
+		}
+		if update.ProgressDeadline != "" {
+			attrs = append(attrs, hclAttribute{"progress_deadline", quote(update.ProgressDeadline)})
+		}
+		if update.AutoRevert {
+			attrs = append(attrs, hclAttribute{"auto_revert", "true"})
+		}
+		if update.Canary > 0 {
+			attrs = append(attrs,
+				hclAttribute{"canary", fmt.Sprintf("%d", update.Canary)},
+				hclAttribute{"auto_promote", fmt.Sprintf("%t", update.AutoPromote)})
+		}
+		writeBlock(&config, "    ", "update", attrs)
+	}
+
+	return config.String()
+}
+
+func valueOrDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// formatDuration renders a duration the way a person would write it ("11m" not "11m0s")
+func formatDuration(d time.Duration) string {
+	formatted := d.String()
+	if strings.HasSuffix(formatted, "m0s") {
+		formatted = strings.TrimSuffix(formatted, "0s")
+	}
+	if strings.HasSuffix(formatted, "h0m") {
+		formatted = strings.TrimSuffix(formatted, "0m")
+	}
+	return formatted
+}
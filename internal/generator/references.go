@@ -0,0 +1,124 @@
+package generator
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// serviceReference is an occurrence of another compose service's hostname in a value
+type serviceReference struct {
+	Service string
+	Port    int // Explicit port after the hostname, 0 when absent
+	Start   int // Offset of the hostname
+	End     int // Offset just past the hostname and its port, if any
+}
+
+// findServiceReferences finds compose service hostnames in a value.
+// To avoid rewriting ordinary words, a name only counts as a hostname when it is
+// the entire value, is followed by an explicit port (`db:5432`), or is the host
+// part of a URL (`postgres://user:pass@db/app`, `http://api/`).
+func findServiceReferences(value string, services []string) []serviceReference {
+	var references []serviceReference
+
+	// Longest names first so "api-gateway" wins over "api"
+	names := append([]string{}, services...)
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+
+	taken := make([]bool, len(value))
+
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		for offset := 0; offset < len(value); {
+			index := strings.Index(value[offset:], name)
+			if index < 0 {
+				break
+			}
+			start := offset + index
+			end := start + len(name)
+			offset = end
+
+			if taken[start] || !isHostBoundary(value, start-1) || !isHostBoundary(value, end) {
+				continue
+			}
+
+			reference := serviceReference{Service: name, Start: start, End: end}
+			port, portEnd := parsePortAfter(value, end)
+
+			switch {
+			case start == 0 && end == len(value):
+				// Whole value is the hostname
+			case port > 0:
+				reference.Port = port
+				reference.End = portEnd
+			case isURLHost(value, start, end):
+				// URL host without an explicit port
+			default:
+				continue
+			}
+
+			for i := reference.Start; i < reference.End; i++ {
+				taken[i] = true
+			}
+			references = append(references, reference)
+		}
+	}
+
+	sort.Slice(references, func(i, j int) bool { return references[i].Start < references[j].Start })
+	return references
+}
+
+// rewriteServiceReferences replaces every reference using the replace callback
+func rewriteServiceReferences(value string, references []serviceReference, replace func(serviceReference) string) string {
+	if len(references) == 0 {
+		return value
+	}
+
+	var rewritten strings.Builder
+	last := 0
+	for _, reference := range references {
+		rewritten.WriteString(value[last:reference.Start])
+		rewritten.WriteString(replace(reference))
+		last = reference.End
+	}
+	rewritten.WriteString(value[last:])
+	return rewritten.String()
+}
+
+// isHostBoundary reports whether the character at index can't be part of a hostname
+func isHostBoundary(value string, index int) bool {
+	if index < 0 || index >= len(value) {
+		return true
+	}
+	ch := value[index]
+	isNameChar := ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || ch == '-' || ch == '_' || ch == '.'
+	return !isNameChar
+}
+
+// parsePortAfter parses ":<digits>" starting at index
+func parsePortAfter(value string, index int) (int, int) {
+	if index >= len(value) || value[index] != ':' {
+		return 0, index
+	}
+	end := index + 1
+	for end < len(value) && value[end] >= '0' && value[end] <= '9' {
+		end++
+	}
+	port, err := strconv.Atoi(value[index+1 : end])
+	if err != nil || !isHostBoundary(value, end) {
+		return 0, index
+	}
+	return port, end
+}
+
+// isURLHost reports whether value[start:end] is the host part of a URL
+func isURLHost(value string, start, end int) bool {
+	afterScheme := start >= 2 && value[start-2:start] == "//"
+	afterCredentials := start >= 1 && value[start-1] == '@'
+	if !afterScheme && !afterCredentials {
+		return false
+	}
+	return end == len(value) || strings.ContainsRune("/?#", rune(value[end]))
+}
@@ -0,0 +1,342 @@
+package generator
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+// defaultAllowedCaps is the docker driver's default allow_caps list
+var defaultAllowedCaps = []string{
+	"audit_write", "chown", "dac_override", "fowner", "fsetid", "kill", "mknod",
+	"net_bind_service", "setfcap", "setgid", "setpcap", "setuid", "sys_chroot",
+}
+
+// pluginRequirements tracks options that only work once the Nomad client's
+// docker plugin config allows them
+type pluginRequirements struct {
+	privileged []string            // Services needing allow_privileged
+	caps       map[string][]string // Capability → services needing it in allow_caps
+}
+
+// requirePrivileged records that a service needs allow_privileged
+func (r *pluginRequirements) requirePrivileged(serviceName string) {
+	r.privileged = append(r.privileged, serviceName)
+}
+
+// requireCap records that a service adds a capability outside the default allow_caps
+func (r *pluginRequirements) requireCap(serviceName, capability string) {
+	for _, allowed := range defaultAllowedCaps {
+		if capability == allowed {
+			return
+		}
+	}
+	if r.caps == nil {
+		r.caps = make(map[string][]string)
+	}
+	r.caps[capability] = append(r.caps[capability], serviceName)
+}
+
+func (r *pluginRequirements) empty() bool {
+	return len(r.privileged) == 0 && len(r.caps) == 0
+}
+
+// print shows which services need client-side plugin config and a snippet to apply
+func (r *pluginRequirements) print(w io.Writer) {
+	fmt.Fprintln(w, "\n🔐 Some options need the Nomad client's docker plugin to allow them:")
+	if len(r.privileged) > 0 {
+		fmt.Fprintf(w, "   - privileged = true (%s) → allow_privileged = true\n", strings.Join(r.privileged, ", "))
+	}
+
+	extraCaps := make([]string, 0, len(r.caps))
+	for capability := range r.caps {
+		extraCaps = append(extraCaps, capability)
+	}
+	sort.Strings(extraCaps)
+	for _, capability := range extraCaps {
+		fmt.Fprintf(w, "   - cap_add %s (%s) → allow_caps must include %q\n", capability, strings.Join(r.caps[capability], ", "), capability)
+	}
+
+	var attrs []hclAttribute
+	if len(r.privileged) > 0 {
+		attrs = append(attrs, hclAttribute{"allow_privileged", "true"})
+	}
+	if len(extraCaps) > 0 {
+		attrs = append(attrs, hclAttribute{"allow_caps", quoteList(append(append([]string{}, defaultAllowedCaps...), extraCaps...))})
+	}
+
+	var snippet strings.Builder
+	writeNestedBlock(&snippet, "   ", hclBlock{
+		Header: `plugin "docker"`,
+		Blocks: []hclBlock{{Header: "config", Attrs: attrs}},
+	})
+	fmt.Fprintln(w, "\n   Add this to the client configuration of the nodes running these jobs:")
+	fmt.Fprint(w, snippet.String())
+}
+
+// runtimeConfig converts compose runtime options into docker driver attributes and blocks
+func (g *NomadGenerator) runtimeConfig(service types.EnhancedServiceConfig) ([]hclAttribute, []hclBlock) {
+	original := service.OriginalService
+	var attrs []hclAttribute
+	var blocks []hclBlock
+
+	if original.Hostname != "" {
+		attrs = append(attrs, hclAttribute{"hostname", quote(original.Hostname)})
+	}
+
+	if original.Privileged {
+		attrs = append(attrs, hclAttribute{"privileged", "true"})
+		g.plugin.requirePrivileged(service.Name)
+	}
+
+	if len(original.CapAdd) > 0 {
+		caps := normalizeCaps(original.CapAdd)
+		attrs = append(attrs, hclAttribute{"cap_add", quoteList(caps)})
+		for _, capability := range caps {
+			g.plugin.requireCap(service.Name, capability)
+		}
+	}
+
+	if len(original.CapDrop) > 0 {
+		attrs = append(attrs, hclAttribute{"cap_drop", quoteList(normalizeCaps(original.CapDrop))})
+	}
+
+	if len(original.SecurityOpt) > 0 {
+		attrs = append(attrs, hclAttribute{"security_opt", quoteList(original.SecurityOpt)})
+	}
+
+	if original.ReadOnly {
+		attrs = append(attrs, hclAttribute{"readonly_rootfs", "true"})
+	}
+
+	if original.Init != nil && *original.Init {
+		attrs = append(attrs, hclAttribute{"init", "true"})
+	}
+
+	if original.ShmSize != nil {
+		if size, err := parseBytes(fmt.Sprintf("%v", original.ShmSize)); err == nil {
+			attrs = append(attrs, hclAttribute{"shm_size", strconv.FormatInt(size, 10)})
+		} else {
+			g.warn("%s: ignoring shm_size: %v", service.Name, err)
+		}
+	}
+
+	if original.Ipc != "" {
+		attrs = append(attrs, hclAttribute{"ipc_mode", quote(original.Ipc)})
+	}
+
+	if original.Pid != "" {
+		attrs = append(attrs, hclAttribute{"pid_mode", quote(original.Pid)})
+	}
+
+	if dns := stringOrList(original.DNS); len(dns) > 0 {
+		attrs = append(attrs, hclAttribute{"dns_servers", quoteList(dns)})
+	}
+
+	if len(original.ExtraHosts) > 0 {
+		var hosts []string
+		for _, host := range sortedKeys(original.ExtraHosts) {
+			if ip := original.ExtraHosts[host]; ip != "" {
+				hosts = append(hosts, host+":"+ip)
+			} else {
+				// List form "host:ip" is kept whole
+				hosts = append(hosts, host)
+			}
+		}
+		attrs = append(attrs, hclAttribute{"extra_hosts", quoteList(hosts)})
+	}
+
+	if devices := g.devices(service); len(devices) > 0 {
+		attrs = append(attrs, hclAttribute{"devices", "[" + strings.Join(devices, ", ") + "]"})
+	}
+
+	if len(original.Sysctls) > 0 {
+		sysctl := hclBlock{Header: "sysctl ="}
+		for _, key := range sortedKeys(original.Sysctls) {
+			sysctl.Attrs = append(sysctl.Attrs, hclAttribute{quote(key), quote(original.Sysctls[key])})
+		}
+		blocks = append(blocks, sysctl)
+	}
+
+	if ulimit := g.ulimits(service); len(ulimit.Attrs) > 0 {
+		blocks = append(blocks, ulimit)
+	}
+
+	blocks = append(blocks, g.tmpfsMounts(service)...)
+
+	return attrs, blocks
+}
+
+// devices converts short ("/dev/x:/dev/y:rwm") and long form devices
+func (g *NomadGenerator) devices(service types.EnhancedServiceConfig) []string {
+	var devices []string
+
+	for _, device := range service.OriginalService.Devices {
+		var hostPath, containerPath, permissions string
+
+		switch value := device.(type) {
+		case string:
+			parts := strings.Split(value, ":")
+			hostPath = parts[0]
+			if len(parts) > 1 {
+				containerPath = parts[1]
+			}
+			if len(parts) > 2 {
+				permissions = parts[2]
+			}
+		case map[string]interface{}:
+			hostPath, _ = value["source"].(string)
+			containerPath, _ = value["target"].(string)
+			permissions, _ = value["permissions"].(string)
+		}
+
+		if hostPath == "" {
+			g.warn("%s: ignoring device entry %v", service.Name, device)
+			continue
+		}
+
+		fields := []string{"host_path = " + quote(hostPath)}
+		if containerPath != "" {
+			fields = append(fields, "container_path = "+quote(containerPath))
+		}
+		if permissions != "" {
+			fields = append(fields, "cgroup_permissions = "+quote(permissions))
+		}
+		devices = append(devices, "{ "+strings.Join(fields, ", ")+" }")
+	}
+
+	return devices
+}
+
+// ulimits converts `nofile: 1024` and `nofile: {soft: 1024, hard: 2048}` forms
+func (g *NomadGenerator) ulimits(service types.EnhancedServiceConfig) hclBlock {
+	block := hclBlock{Header: "ulimit"}
+	ulimits := service.OriginalService.Ulimits
+
+	names := make([]string, 0, len(ulimits))
+	for name := range ulimits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		switch value := ulimits[name].(type) {
+		case int:
+			block.Attrs = append(block.Attrs, hclAttribute{name, quote(fmt.Sprintf("%d:%d", value, value))})
+		case map[string]interface{}:
+			soft, hard := value["soft"], value["hard"]
+			if hard == nil {
+				hard = soft
+			}
+			block.Attrs = append(block.Attrs, hclAttribute{name, quote(fmt.Sprintf("%v:%v", soft, hard))})
+		default:
+			g.warn("%s: ignoring ulimit %s=%v", service.Name, name, value)
+		}
+	}
+
+	return block
+}
+
+// tmpfsMounts converts `tmpfs: /run` or `tmpfs: ["/run:size=64m"]` into tmpfs mounts
+func (g *NomadGenerator) tmpfsMounts(service types.EnhancedServiceConfig) []hclBlock {
+	var mounts []hclBlock
+
+	for _, entry := range stringOrList(service.OriginalService.Tmpfs) {
+		target, options, _ := strings.Cut(entry, ":")
+		mount := hclBlock{
+			Header: "mount",
+			Attrs: []hclAttribute{
+				{"type", quote("tmpfs")},
+				{"target", quote(target)},
+			},
+		}
+
+		for _, option := range strings.Split(options, ",") {
+			if option == "" {
+				continue
+			}
+			key, value, _ := strings.Cut(option, "=")
+			if key != "size" {
+				g.warn("%s: tmpfs option %q on %s is not supported by the docker driver and was dropped", service.Name, option, target)
+				continue
+			}
+			size, err := parseBytes(value)
+			if err != nil {
+				g.warn("%s: ignoring tmpfs size on %s: %v", service.Name, target, err)
+				continue
+			}
+			mount.Blocks = append(mount.Blocks, hclBlock{
+				Header: "tmpfs_options",
+				Attrs:  []hclAttribute{{"size", strconv.FormatInt(size, 10)}},
+			})
+		}
+
+		mounts = append(mounts, mount)
+	}
+
+	return mounts
+}
+
+// normalizeCaps lower-cases capabilities and strips the CAP_ prefix, as the docker driver expects
+func normalizeCaps(caps []string) []string {
+	normalized := make([]string, len(caps))
+	for i, capability := range caps {
+		normalized[i] = strings.TrimPrefix(strings.ToLower(capability), "cap_")
+	}
+	return normalized
+}
+
+// stringOrList flattens compose fields that accept a string or a list of strings
+func stringOrList(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			result = append(result, fmt.Sprintf("%v", item))
+		}
+		return result
+	}
+	return nil
+}
+
+// sortedKeys returns map keys in sorted order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parseBytes parses compose byte sizes such as 1024, "64m", "1gb" or "512k"
+func parseBytes(value string) (int64, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	number := strings.TrimRight(value, "bkmg")
+	unit := strings.TrimSuffix(value[len(number):], "b")
+
+	multiplier := map[string]float64{
+		"":  1,
+		"k": 1 << 10,
+		"m": 1 << 20,
+		"g": 1 << 30,
+	}[unit]
+	if multiplier == 0 {
+		return 0, fmt.Errorf("unknown size unit in %q", value)
+	}
+
+	size, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return int64(size * multiplier), nil
+}
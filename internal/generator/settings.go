@@ -0,0 +1,196 @@
+package generator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+// HealthCheckNone disables the service check
+const HealthCheckNone = "none"
+
+// override returns the --set values for a service
+func (g *NomadGenerator) override(service types.EnhancedServiceConfig) types.ServiceOverride {
+	return g.options.Overrides[service.Name]
+}
+
+// applyOverrides applies the settings other services depend on, such as the
+// published port, before the catalog is built
+func (g *NomadGenerator) applyOverrides(services []types.EnhancedServiceConfig) []types.EnhancedServiceConfig {
+	result := make([]types.EnhancedServiceConfig, len(services))
+	for i, service := range services {
+		override, ok := g.options.Overrides[service.Name]
+		if len(services) == 1 {
+			if g.options.ServiceName != "" && g.options.ServiceName != service.Name {
+				service.Name = g.options.ServiceName
+				if ok {
+					g.options.Overrides = copyOverrides(g.options.Overrides)
+					g.options.Overrides[service.Name] = override
+				}
+			}
+			service = withPort(service, g.options.Port)
+		}
+		service = withPort(service, override.Port)
+		result[i] = service
+	}
+	return result
+}
+
+func copyOverrides(overrides map[string]types.ServiceOverride) map[string]types.ServiceOverride {
+	copied := make(map[string]types.ServiceOverride, len(overrides))
+	for name, override := range overrides {
+		copied[name] = override
+	}
+	return copied
+}
+
+// withPort publishes the main port on the given host port
+func withPort(service types.EnhancedServiceConfig, port int) types.EnhancedServiceConfig {
+	if port <= 0 {
+		return service
+	}
+	ports := append([]types.PortMapping{}, service.ResolvedPorts...)
+	if len(ports) == 0 {
+		ports = append(ports, types.PortMapping{Container: port, Protocol: "tcp"})
+	}
+	ports[0].Host = port
+	service.ResolvedPorts = ports
+	return service
+}
+
+// datacenters returns the datacenters a job may run in
+func (g *NomadGenerator) datacenters(service types.EnhancedServiceConfig) []string {
+	switch {
+	case len(g.override(service).Datacenters) > 0:
+		return g.override(service).Datacenters
+	case len(g.options.Datacenters) > 0:
+		return g.options.Datacenters
+	case g.options.Datacenter != "":
+		return []string{g.options.Datacenter}
+	}
+	return []string{"dc1"}
+}
+
+// jobPlacement returns the optional region, namespace, node_pool and priority attributes
+func (g *NomadGenerator) jobPlacement(service types.EnhancedServiceConfig) []hclAttribute {
+	override := g.override(service)
+	var attrs []hclAttribute
+
+	if region := valueOrDefault(override.Region, g.options.Region); region != "" {
+		attrs = append(attrs, hclAttribute{"region", quote(region)})
+	}
+	if namespace := valueOrDefault(override.Namespace, g.options.Namespace); namespace != "" {
+		attrs = append(attrs, hclAttribute{"namespace", quote(namespace)})
+	}
+	if pool := valueOrDefault(override.NodePool, g.options.NodePool); pool != "" {
+		attrs = append(attrs, hclAttribute{"node_pool", quote(pool)})
+	}
+	if priority := intOrDefault(override.Priority, g.options.Priority); priority > 0 {
+		attrs = append(attrs, hclAttribute{"priority", strconv.Itoa(priority)})
+	}
+
+	return attrs
+}
+
+// healthCheckPath returns the HTTP path checked when compose defines no healthcheck
+func (g *NomadGenerator) healthCheckPath(service types.EnhancedServiceConfig) string {
+	return valueOrDefault(g.override(service).HealthCheck, g.options.HealthCheck)
+}
+
+// integrationBlocks returns the consul and vault blocks requested by --with-consul and --with-vault
+func (g *NomadGenerator) integrationBlocks() []hclBlock {
+	var blocks []hclBlock
+	if g.options.WithConsul {
+		blocks = append(blocks, hclBlock{Header: "consul"})
+	}
+	if g.options.WithVault {
+		blocks = append(blocks, hclBlock{Header: "vault"})
+	}
+	return blocks
+}
+
+// ParseOverrides parses --set <service>.<key>=<value> flags; later flags for
+// the same setting win
+func ParseOverrides(values []string) (map[string]types.ServiceOverride, error) {
+	overrides := make(map[string]types.ServiceOverride)
+	for _, value := range values {
+		setting, settingValue, found := strings.Cut(value, "=")
+		// Keys never contain dots, service names may
+		dot := strings.LastIndex(setting, ".")
+		if !found || dot <= 0 || dot == len(setting)-1 {
+			return nil, fmt.Errorf("invalid --set %q: expected <service>.<key>=<value>", value)
+		}
+		service, key := setting[:dot], setting[dot+1:]
+
+		override := overrides[service]
+		if err := ParseOverride(&override, key, settingValue); err != nil {
+			return nil, fmt.Errorf("invalid --set %q: %w", value, err)
+		}
+		overrides[service] = override
+	}
+	return overrides, nil
+}
+
+// ParseOverride sets one `--set <service>.<key>=<value>` setting
+func ParseOverride(override *types.ServiceOverride, key, value string) error {
+	number := func() (int, error) {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("%s must be a positive number, got %q", key, value)
+		}
+		return n, nil
+	}
+
+	var err error
+	switch key {
+	case "cpu":
+		override.CPU, err = number()
+	case "memory":
+		override.Memory, err = number()
+	case "count", "instances":
+		override.Count, err = number()
+	case "port":
+		override.Port, err = number()
+	case "priority":
+		override.Priority, err = number()
+		if err == nil && override.Priority > 100 {
+			err = fmt.Errorf("priority must be between 1 and 100, got %d", override.Priority)
+		}
+	case "health_check", "health-check":
+		override.HealthCheck = value
+	case "namespace":
+		override.Namespace = value
+	case "region":
+		override.Region = value
+	case "node_pool", "node-pool":
+		override.NodePool = value
+	case "datacenters", "datacenter":
+		override.Datacenters = splitList(value)
+		if len(override.Datacenters) == 0 {
+			err = fmt.Errorf("%s must list at least one datacenter", key)
+		}
+	default:
+		return fmt.Errorf("unknown setting %q (supported: cpu, memory, count, port, priority, health_check, namespace, region, node_pool, datacenters)", key)
+	}
+	return err
+}
+
+// splitList splits a comma separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func intOrDefault(value, fallback int) int {
+	if value > 0 {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,58 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+// taskService describes a further task as a service, so the main task's
+// config and volume helpers render it too; volumes map through the service's x-nomad
+func taskService(service types.EnhancedServiceConfig, task types.Task) types.EnhancedServiceConfig {
+	original := task.Service
+	original.Image = task.Image
+	original.XNomad = service.OriginalService.XNomad
+	return types.EnhancedServiceConfig{
+		Name:            service.Name,
+		OriginalService: original,
+		ResolvedImage:   task.Image,
+		Environment:     task.Environment,
+		SourceFile:      service.SourceFile,
+	}
+}
+
+// generateExtraTask renders a further task of the service's group, with a
+// lifecycle block for prestart and sidecar tasks
+func (g *NomadGenerator) generateExtraTask(service types.EnhancedServiceConfig, task types.Task, logging *types.LoggingConfig) string {
+	taskConfig := taskService(service, task)
+
+	var content strings.Builder
+	content.WriteString(taskHeader(task.Name, task.Service.User))
+
+	if task.Lifecycle != "" {
+		writeBlock(&content, "      ", "lifecycle", []hclAttribute{
+			{"hook", quote(task.Lifecycle)},
+			{"sidecar", fmt.Sprintf("%t", task.Sidecar)},
+		})
+	}
+
+	content.WriteString(g.generateDockerConfig(taskConfig, logging, addressPlan{}))
+
+	for _, block := range g.volumeMountBlocks(taskConfig) {
+		writeNestedBlock(&content, "      ", block)
+		content.WriteString("\n")
+	}
+
+	writeBlock(&content, "      ", "resources", []hclAttribute{
+		{"cpu", fmt.Sprintf("%d", intOrDefault(task.CPU, 100))},
+		{"memory", fmt.Sprintf("%d", intOrDefault(task.Memory, 128))},
+	})
+
+	if len(task.Environment) > 0 {
+		content.WriteString(g.generateEnvironmentConfig(task.Environment))
+	}
+
+	content.WriteString("    }\n")
+	return content.String()
+}
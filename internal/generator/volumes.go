@@ -0,0 +1,185 @@
+package generator
+
+import (
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+// How compose named volumes are mounted
+const (
+	VolumeTypeHost   = "host"   // Nomad host volume declared in the client config
+	VolumeTypeCSI    = "csi"    // Registered CSI volume
+	VolumeTypeDocker = "docker" // Docker-managed volume on the client
+)
+
+// namedVolume is a compose `name:/path[:ro]` volume and the Nomad volume it maps to
+type namedVolume struct {
+	Name           string
+	Target         string
+	ReadOnly       bool
+	Type           string // host, csi or docker
+	Source         string
+	AccessMode     string // csi only
+	AttachmentMode string // csi only
+	PerAlloc       bool   // csi only
+}
+
+// volumeType returns the type of volumes x-nomad maps without one
+func (g *NomadGenerator) volumeType() string {
+	if g.options.VolumeType == "" {
+		return VolumeTypeHost
+	}
+	return g.options.VolumeType
+}
+
+// namedVolumes returns the service's named volumes, mapped by x-nomad.volumes
+// or --volume-type; bind mounts, anonymous volumes and, without
+// --volume-type, unmapped volumes are left out
+func (g *NomadGenerator) namedVolumes(service types.EnhancedServiceConfig) []namedVolume {
+	mappings := g.extension(service).Volumes
+
+	var volumes []namedVolume
+	for _, volume := range service.OriginalService.Volumes {
+		parts := strings.Split(volume, ":")
+		if len(parts) < 2 || isBindSource(parts[0]) {
+			continue
+		}
+
+		mapping, mapped := mappings[parts[0]]
+		if !mapped && g.options.VolumeType == "" {
+			// Listed in the job as a comment
+			continue
+		}
+		named := namedVolume{
+			Name:           parts[0],
+			Target:         parts[1],
+			ReadOnly:       mapping.ReadOnly || len(parts) > 2 && strings.Contains(parts[2], "ro"),
+			Type:           valueOrDefault(mapping.Type, g.volumeType()),
+			Source:         valueOrDefault(mapping.Source, parts[0]),
+			AccessMode:     valueOrDefault(mapping.AccessMode, "single-node-writer"),
+			AttachmentMode: valueOrDefault(mapping.AttachmentMode, "file-system"),
+			PerAlloc:       mapping.PerAlloc,
+		}
+		switch named.Type {
+		case VolumeTypeHost, VolumeTypeCSI, VolumeTypeDocker:
+		default:
+			g.warn("%s: unknown x-nomad volume type %q for %s, using %s", service.Name, named.Type, named.Name, g.volumeType())
+			named.Type = g.volumeType()
+		}
+		volumes = append(volumes, named)
+	}
+	return volumes
+}
+
+// unmappedVolumes lists volumes that aren't mounted by a volume or template
+func (g *NomadGenerator) unmappedVolumes(service types.EnhancedServiceConfig, addresses addressPlan) []string {
+	mapped := make(map[string]bool)
+	for _, volume := range g.namedVolumes(service) {
+		mapped[volume.Target] = true
+	}
+	for _, file := range addresses.Files {
+		mapped[file.Target] = true
+	}
+
+	var unmapped []string
+	for _, volume := range service.OriginalService.Volumes {
+		parts := strings.Split(volume, ":")
+		if len(parts) >= 2 && mapped[parts[1]] {
+			continue
+		}
+		unmapped = append(unmapped, volume)
+	}
+	return unmapped
+}
+
+// groupVolumeBlocks declares the host and CSI volumes of all the group's tasks
+func (g *NomadGenerator) groupVolumeBlocks(service types.EnhancedServiceConfig) []hclBlock {
+	volumes := g.namedVolumes(service)
+	for _, task := range service.Tasks {
+		volumes = append(volumes, g.namedVolumes(taskService(service, task))...)
+	}
+
+	var blocks []hclBlock
+	seen := make(map[string]bool)
+	for _, volume := range volumes {
+		if volume.Type == VolumeTypeDocker || seen[volume.Name] {
+			continue
+		}
+		seen[volume.Name] = true
+
+		block := hclBlock{
+			Header: "volume " + quote(volume.Name),
+			Attrs: []hclAttribute{
+				{"type", quote(volume.Type)},
+				{"source", quote(volume.Source)},
+			},
+		}
+		if volume.Type == VolumeTypeCSI {
+			block.Attrs = append(block.Attrs,
+				hclAttribute{"attachment_mode", quote(volume.AttachmentMode)},
+				hclAttribute{"access_mode", quote(volume.AccessMode)})
+			if volume.PerAlloc {
+				block.Attrs = append(block.Attrs, hclAttribute{"per_alloc", "true"})
+				g.warn("%s: register a CSI volume %q per allocation, %s[0] to %s[N-1], before deploying (nomad volume register)", service.Name, volume.Source, volume.Source, volume.Source)
+			} else {
+				g.warn("%s: register CSI volume %q before deploying (nomad volume register)", service.Name, volume.Source)
+				if count := g.getReplicas(service); count > 1 && strings.HasPrefix(volume.AccessMode, "single-node-") {
+					g.warn("%s: CSI volume %q is %s but count is %d; allocations on other nodes can't claim it (set x-nomad per_alloc or a multi-node access mode)", service.Name, volume.Source, volume.AccessMode, count)
+				}
+			}
+		} else {
+			g.warn("%s: host volume %q must be declared in the Nomad client config (host_volume %q { path = ... })", service.Name, volume.Source, volume.Source)
+		}
+		if volume.ReadOnly {
+			block.Attrs = append(block.Attrs, hclAttribute{"read_only", "true"})
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// volumeMountBlocks mounts group volumes into the task
+func (g *NomadGenerator) volumeMountBlocks(service types.EnhancedServiceConfig) []hclBlock {
+	var blocks []hclBlock
+	for _, volume := range g.namedVolumes(service) {
+		if volume.Type == VolumeTypeDocker {
+			continue
+		}
+		block := hclBlock{
+			Header: "volume_mount",
+			Attrs: []hclAttribute{
+				{"volume", quote(volume.Name)},
+				{"destination", quote(volume.Target)},
+			},
+		}
+		if volume.ReadOnly {
+			block.Attrs = append(block.Attrs, hclAttribute{"read_only", "true"})
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// dockerVolumeMounts mounts docker-managed volumes in the docker config
+func (g *NomadGenerator) dockerVolumeMounts(service types.EnhancedServiceConfig) []hclBlock {
+	var mounts []hclBlock
+	for _, volume := range g.namedVolumes(service) {
+		if volume.Type != VolumeTypeDocker {
+			continue
+		}
+		mount := hclBlock{
+			Header: "mount",
+			Attrs: []hclAttribute{
+				{"type", quote("volume")},
+				{"source", quote(volume.Source)},
+				{"target", quote(volume.Target)},
+			},
+		}
+		if volume.ReadOnly {
+			mount.Attrs = append(mount.Attrs, hclAttribute{"readonly", "true"})
+		}
+		mounts = append(mounts, mount)
+	}
+	return mounts
+}
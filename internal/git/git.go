@@ -0,0 +1,140 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// scpURL matches scp-like remotes such as git@github.com:org/repo.git
+var scpURL = regexp.MustCompile(`^[A-Za-z0-9._-]+@(?:[A-Za-z0-9-]+\.)+[A-Za-z0-9-]+:[^/]`)
+
+// Source is a repository, the ref to check out and the directory to analyze
+type Source struct {
+	URL    string
+	Ref    string // Branch, tag or commit; the default branch when empty
+	Subdir string // Path inside the repository
+}
+
+// ParseSource splits a docker-style `url#ref:subdir` source
+func ParseSource(source string) Source {
+	repo, fragment, _ := strings.Cut(source, "#")
+	ref, subdir, _ := strings.Cut(fragment, ":")
+	return Source{URL: repo, Ref: ref, Subdir: strings.Trim(subdir, "/")}
+}
+
+// IsURL reports whether source is a git remote URL
+func IsURL(source string) bool {
+	for _, scheme := range []string{"http://", "https://", "ssh://", "git://", "git+ssh://", "file://"} {
+		if strings.HasPrefix(source, scheme) {
+			return true
+		}
+	}
+	return scpURL.MatchString(source)
+}
+
+// IsLocal reports whether source is a repository on this machine, which
+// docker can't use as a build context the way it fetches remotes
+func IsLocal(source string) bool {
+	return !IsURL(source) || strings.HasPrefix(source, "file://")
+}
+
+// IsBareRepo reports whether dir is a bare repository
+func IsBareRepo(dir string) bool {
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// IsWorkTree reports whether dir is the top of a cloned working tree
+func IsWorkTree(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// Redact removes credentials from a URL, for output and job meta
+func Redact(remote string) string {
+	parsed, err := url.Parse(remote)
+	if err != nil || parsed.User == nil || parsed.Scheme == "" {
+		return remote
+	}
+	parsed.User = nil
+	return parsed.String()
+}
+
+// Clone clones source into dir with the git CLI, checks out its ref and
+// returns the commit checked out
+func Clone(ctx context.Context, source Source, dir string) (string, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return "", fmt.Errorf("git sources need the git command: %w", err)
+	}
+
+	if _, err := run(ctx, "", "clone", "--quiet", "--", source.URL, dir); err != nil {
+		return "", fmt.Errorf("failed to clone %s: %w", Redact(source.URL), err)
+	}
+
+	if source.Ref != "" {
+		commit, err := resolve(ctx, dir, source.Ref)
+		if err != nil {
+			return "", err
+		}
+		if _, err := run(ctx, dir, "checkout", "--quiet", "--detach", commit); err != nil {
+			return "", fmt.Errorf("failed to check out %s: %w", source.Ref, err)
+		}
+	}
+
+	commit, err := run(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to read the checked out commit: %w", err)
+	}
+	return commit, nil
+}
+
+// HeadCommit returns the commit checked out in the working tree containing dir
+func HeadCommit(ctx context.Context, dir string) (string, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return "", err
+	}
+	return run(ctx, dir, "rev-parse", "HEAD")
+}
+
+// resolve finds the commit a branch, tag or commit names; branches other
+// than the default one only exist as remote-tracking refs after a clone
+func resolve(ctx context.Context, dir, ref string) (string, error) {
+	for _, candidate := range []string{ref, "origin/" + ref} {
+		if commit, err := run(ctx, dir, "rev-parse", "--verify", "--quiet", candidate+"^{commit}"); err == nil {
+			return commit, nil
+		}
+	}
+	return "", fmt.Errorf("ref %q not found in the repository (branch, tag or commit)", ref)
+}
+
+// run runs git and returns its trimmed output; errors carry git's stderr
+func run(ctx context.Context, dir string, args ...string) (string, error) {
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	// Never wait on a credential prompt
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("%s", message)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
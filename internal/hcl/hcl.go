@@ -0,0 +1,619 @@
+// Package hcl reads the subset of HCL that Nomad job files are written in:
+// blocks, attributes, strings, heredocs, numbers, bools, lists and objects.
+// Expressions it can't evaluate, such as var.image or function calls, are
+// kept as their source text.
+package hcl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Body is the content of a file or block
+type Body struct {
+	Attributes []Attribute
+	Blocks     []*Block
+}
+
+// Attribute is a `name = value` line
+type Attribute struct {
+	Name  string
+	Value interface{}
+	Line  int
+}
+
+// Block is a `type "label" { ... }` block
+type Block struct {
+	Type   string
+	Labels []string
+	Body   *Body
+	Line   int
+}
+
+// Expression is a value that isn't a literal, e.g. var.image or file("x")
+type Expression struct {
+	Raw string
+}
+
+// Values are string, int, float64, bool, nil, []interface{},
+// map[string]interface{} or Expression
+
+// Parse reads an HCL file
+func Parse(data []byte) (*Body, error) {
+	p := &parser{src: string(data), line: 1}
+	return p.body(0)
+}
+
+// Attribute returns the value of an attribute
+func (b *Body) Attribute(name string) (interface{}, bool) {
+	for _, attr := range b.Attributes {
+		if attr.Name == name {
+			return attr.Value, true
+		}
+	}
+	return nil, false
+}
+
+// String returns a string attribute, or "" when it isn't a string
+func (b *Body) String(name string) string {
+	value, _ := b.Attribute(name)
+	s, _ := value.(string)
+	return s
+}
+
+// Int returns an integer attribute, or 0 when it isn't a number
+func (b *Body) Int(name string) int {
+	value, _ := b.Attribute(name)
+	switch n := value.(type) {
+	case int:
+		return n
+	case float64:
+		return int(n)
+	}
+	return 0
+}
+
+// Bool returns a bool attribute, or false when it isn't a bool
+func (b *Body) Bool(name string) bool {
+	value, _ := b.Attribute(name)
+	v, _ := value.(bool)
+	return v
+}
+
+// Strings returns a list attribute's string items
+func (b *Body) Strings(name string) []string {
+	value, _ := b.Attribute(name)
+	list, _ := value.([]interface{})
+	var items []string
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			items = append(items, s)
+		}
+	}
+	return items
+}
+
+// Map returns an object attribute's string values; other values are skipped
+func (b *Body) Map(name string) map[string]string {
+	value, _ := b.Attribute(name)
+	object, _ := value.(map[string]interface{})
+	if len(object) == 0 {
+		return nil
+	}
+	values := make(map[string]string, len(object))
+	for key, item := range object {
+		switch v := item.(type) {
+		case string:
+			values[key] = v
+		case int, float64, bool:
+			values[key] = fmt.Sprintf("%v", v)
+		}
+	}
+	return values
+}
+
+// BlocksOf returns the blocks of a type
+func (b *Body) BlocksOf(blockType string) []*Block {
+	var blocks []*Block
+	for _, block := range b.Blocks {
+		if block.Type == blockType {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// BlockOf returns the first block of a type, or nil
+func (b *Body) BlockOf(blockType string) *Block {
+	for _, block := range b.Blocks {
+		if block.Type == blockType {
+			return block
+		}
+	}
+	return nil
+}
+
+// Label returns the block's first label, or ""
+func (b *Block) Label() string {
+	if len(b.Labels) == 0 {
+		return ""
+	}
+	return b.Labels[0]
+}
+
+type parser struct {
+	src  string
+	pos  int
+	line int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *parser) advance() byte {
+	ch := p.src[p.pos]
+	p.pos++
+	if ch == '\n' {
+		p.line++
+	}
+	return ch
+}
+
+// skip skips whitespace and comments, and newlines when newlines is set
+func (p *parser) skip(newlines bool) {
+	for !p.eof() {
+		ch := p.peek()
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\r':
+			p.advance()
+		case ch == '\n' && newlines:
+			p.advance()
+		case ch == '#' || strings.HasPrefix(p.src[p.pos:], "//"):
+			for !p.eof() && p.peek() != '\n' {
+				p.advance()
+			}
+		case strings.HasPrefix(p.src[p.pos:], "/*"):
+			end := strings.Index(p.src[p.pos+2:], "*/")
+			if end < 0 {
+				p.pos = len(p.src)
+				return
+			}
+			for stop := p.pos + 2 + end + 2; p.pos < stop; {
+				p.advance()
+			}
+		default:
+			return
+		}
+	}
+}
+
+// body reads attributes and blocks up to the end byte, or the end of the
+// file when end is 0
+func (p *parser) body(end byte) (*Body, error) {
+	body := &Body{}
+	for {
+		p.skip(true)
+		if p.eof() {
+			if end != 0 {
+				return nil, p.errorf("missing %q", end)
+			}
+			return body, nil
+		}
+		if p.peek() == end {
+			p.advance()
+			return body, nil
+		}
+
+		line := p.line
+		name := p.identifier()
+		if name == "" && p.peek() == '"' {
+			// Quoted attribute names, e.g. in env blocks
+			quoted, err := p.quoted()
+			if err != nil {
+				return nil, err
+			}
+			name = quoted
+		}
+		if name == "" {
+			return nil, p.errorf("unexpected %q", p.peek())
+		}
+		p.skip(false)
+
+		if p.peek() == '=' {
+			p.advance()
+			p.skip(false)
+			value, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			body.Attributes = append(body.Attributes, Attribute{Name: name, Value: value, Line: line})
+			p.skip(false)
+			if !p.eof() && p.peek() != '\n' && p.peek() != end {
+				return nil, p.errorf("unexpected %q after %s", p.peek(), name)
+			}
+			continue
+		}
+
+		block := &Block{Type: name, Line: line}
+		for p.peek() != '{' {
+			switch {
+			case p.peek() == '"':
+				label, err := p.quoted()
+				if err != nil {
+					return nil, err
+				}
+				block.Labels = append(block.Labels, label)
+			case isIdentifierByte(p.peek()):
+				block.Labels = append(block.Labels, p.identifier())
+			default:
+				return nil, p.errorf("expected = or { after %s", name)
+			}
+			p.skip(false)
+		}
+		p.advance()
+		nested, err := p.body('}')
+		if err != nil {
+			return nil, err
+		}
+		block.Body = nested
+		body.Blocks = append(body.Blocks, block)
+	}
+}
+
+func isIdentifierByte(ch byte) bool {
+	return ch == '_' || ch == '-' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9'
+}
+
+func (p *parser) identifier() string {
+	start := p.pos
+	for !p.eof() && isIdentifierByte(p.peek()) {
+		p.advance()
+	}
+	return p.src[start:p.pos]
+}
+
+// expression reads a value; anything beyond a literal is kept as an Expression
+func (p *parser) expression() (interface{}, error) {
+	start, line := p.pos, p.line
+	value, literal, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if literal {
+		p.skip(false)
+		switch p.peek() {
+		case 0, '\n', ',', '}', ']', ')':
+			return value, nil
+		}
+	}
+
+	// Operators, traversals or calls: keep the source text
+	p.pos, p.line = start, line
+	return Expression{Raw: p.raw()}, nil
+}
+
+// primary reads a literal value; literal is false when the value starts an
+// expression it can't evaluate
+func (p *parser) primary() (interface{}, bool, error) {
+	switch ch := p.peek(); {
+	case ch == '"':
+		value, err := p.quoted()
+		return value, true, err
+	case strings.HasPrefix(p.src[p.pos:], "<<"):
+		value, err := p.heredoc()
+		return value, true, err
+	case ch == '[':
+		value, err := p.list()
+		return value, true, err
+	case ch == '{':
+		value, err := p.object()
+		return value, true, err
+	case ch == '-' || ch >= '0' && ch <= '9':
+		return p.number()
+	case isIdentifierByte(ch):
+		switch word := p.identifier(); word {
+		case "true":
+			return true, true, nil
+		case "false":
+			return false, true, nil
+		case "null":
+			return nil, true, nil
+		}
+		return nil, false, nil
+	case ch == 0:
+		return nil, false, p.errorf("missing value")
+	}
+	return nil, false, nil
+}
+
+// raw reads an expression's source text, up to the end of the line or the
+// list, object or call it is in
+func (p *parser) raw() string {
+	start := p.pos
+	depth := 0
+	for !p.eof() {
+		switch ch := p.peek(); ch {
+		case '"':
+			if _, err := p.quoted(); err != nil {
+				return strings.TrimSpace(p.src[start:p.pos])
+			}
+			continue
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			if depth == 0 {
+				return strings.TrimSpace(p.src[start:p.pos])
+			}
+			depth--
+		case ',', '\n', '#':
+			if depth == 0 {
+				return strings.TrimSpace(p.src[start:p.pos])
+			}
+		case '/':
+			// Comments after the expression are skipped, not kept
+			if depth == 0 && (strings.HasPrefix(p.src[p.pos:], "//") || strings.HasPrefix(p.src[p.pos:], "/*")) {
+				return strings.TrimSpace(p.src[start:p.pos])
+			}
+		}
+		p.advance()
+	}
+	return strings.TrimSpace(p.src[start:p.pos])
+}
+
+func (p *parser) number() (interface{}, bool, error) {
+	start := p.pos
+	if p.peek() == '-' {
+		p.advance()
+	}
+	for !p.eof() && (p.peek() >= '0' && p.peek() <= '9' || p.peek() == '.' || p.peek() == 'e' || p.peek() == 'E') {
+		p.advance()
+	}
+	text := p.src[start:p.pos]
+	if n, err := strconv.Atoi(text); err == nil {
+		return n, true, nil
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, false, nil
+	}
+	return f, true, nil
+}
+
+// quoted reads a quoted string; template sequences like ${NOMAD_PORT_http}
+// are kept as written, $${ and %%{ escapes are unescaped
+func (p *parser) quoted() (string, error) {
+	p.advance()
+	var b strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		ch := p.advance()
+		switch {
+		case ch == '"':
+			return b.String(), nil
+		case ch == '\\':
+			if p.eof() {
+				return "", p.errorf("unterminated string")
+			}
+			switch escaped := p.advance(); escaped {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'u', 'U':
+				digits := 4
+				if escaped == 'U' {
+					digits = 8
+				}
+				if p.pos+digits > len(p.src) {
+					return "", p.errorf("invalid \\%c escape", escaped)
+				}
+				code, err := strconv.ParseUint(p.src[p.pos:p.pos+digits], 16, 32)
+				if err != nil {
+					return "", p.errorf("invalid \\%c escape", escaped)
+				}
+				p.pos += digits
+				b.WriteRune(rune(code))
+			default:
+				b.WriteByte(escaped)
+			}
+		case (ch == '$' || ch == '%') && strings.HasPrefix(p.src[p.pos:], string(ch)+"{"):
+			p.advance()
+			b.WriteByte(ch)
+		case (ch == '$' || ch == '%') && p.peek() == '{':
+			// Keep the template sequence, which may hold quotes, as written
+			start := p.pos - 1
+			if err := p.template(); err != nil {
+				return "", err
+			}
+			b.WriteString(p.src[start:p.pos])
+		default:
+			b.WriteByte(ch)
+		}
+	}
+}
+
+// template skips a ${...} or %{...} sequence, whose braces may be nested and
+// whose strings may hold braces
+func (p *parser) template() error {
+	line, depth := p.line, 0
+	for !p.eof() {
+		switch p.advance() {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return nil
+			}
+		case '"':
+			for !p.eof() && p.peek() != '"' {
+				if p.advance() == '\\' && !p.eof() {
+					p.advance()
+				}
+			}
+			if !p.eof() {
+				p.advance()
+			}
+		}
+	}
+	p.line = line
+	return p.errorf("unterminated template sequence")
+}
+
+// heredoc reads <<EOF and <<-EOF strings; <<- strips the common indentation
+func (p *parser) heredoc() (string, error) {
+	line := p.line
+	p.pos += 2
+	indented := false
+	if p.peek() == '-' {
+		p.advance()
+		indented = true
+	}
+	marker := p.identifier()
+	if marker == "" {
+		return "", p.errorf("heredoc without a marker")
+	}
+	for !p.eof() && p.peek() != '\n' {
+		p.advance()
+	}
+	if p.eof() {
+		return "", p.errorf("unterminated heredoc %s", marker)
+	}
+	p.advance()
+
+	var lines []string
+	for {
+		if p.eof() {
+			p.line = line
+			return "", p.errorf("unterminated heredoc %s", marker)
+		}
+		end := strings.IndexByte(p.src[p.pos:], '\n')
+		if end < 0 {
+			end = len(p.src) - p.pos
+		}
+		line := p.src[p.pos : p.pos+end]
+		p.pos += end
+		if strings.TrimSpace(line) == marker {
+			break
+		}
+		lines = append(lines, line)
+		if !p.eof() {
+			p.advance()
+		}
+	}
+
+	if indented {
+		lines = dedent(lines)
+	}
+	text := strings.Join(lines, "\n")
+	if len(lines) > 0 {
+		text += "\n"
+	}
+	text = strings.ReplaceAll(text, "$${", "${")
+	return strings.ReplaceAll(text, "%%{", "%{"), nil
+}
+
+// dedent strips the indentation the non-blank lines share
+func dedent(lines []string) []string {
+	common := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if common < 0 || indent < common {
+			common = indent
+		}
+	}
+	if common <= 0 {
+		return lines
+	}
+	for i, line := range lines {
+		if len(line) >= common {
+			lines[i] = line[common:]
+		} else {
+			lines[i] = strings.TrimLeft(line, " \t")
+		}
+	}
+	return lines
+}
+
+func (p *parser) list() ([]interface{}, error) {
+	p.advance()
+	items := []interface{}{}
+	for {
+		p.skip(true)
+		if p.peek() == ']' {
+			p.advance()
+			return items, nil
+		}
+		item, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		p.skip(true)
+		switch p.peek() {
+		case ',':
+			p.advance()
+		case ']':
+		default:
+			return nil, p.errorf("expected , or ] in list")
+		}
+	}
+}
+
+func (p *parser) object() (map[string]interface{}, error) {
+	p.advance()
+	object := make(map[string]interface{})
+	for {
+		p.skip(true)
+		if p.peek() == '}' {
+			p.advance()
+			return object, nil
+		}
+
+		var key string
+		if p.peek() == '"' {
+			quoted, err := p.quoted()
+			if err != nil {
+				return nil, err
+			}
+			key = quoted
+		} else if key = p.identifier(); key == "" {
+			return nil, p.errorf("unexpected %q in object", p.peek())
+		}
+		p.skip(false)
+		if p.peek() != '=' && p.peek() != ':' {
+			return nil, p.errorf("expected = after %s", key)
+		}
+		p.advance()
+		p.skip(false)
+
+		value, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		object[key] = value
+		p.skip(false)
+		if p.peek() == ',' {
+			p.advance()
+		}
+	}
+}
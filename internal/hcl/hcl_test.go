@@ -0,0 +1,122 @@
+package hcl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseValues(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want interface{}
+	}{
+		{"hash comments", "# leading\nx = 1 # trailing\n", 1},
+		{"slash comments", "// leading\nx = \"a\" // trailing\n", "a"},
+		{"block comments", "/* spans\n   lines */ x = true /* inline */\n", true},
+		{"comment after an expression", "x = var.image # pinned\n", Expression{Raw: "var.image"}},
+		{"slash comment after a call", "x = upper(\"a\") // shout\n", Expression{Raw: `upper("a")`}},
+		{"number", "x = -1.5\n", -1.5},
+		{"null", "x = null\n", nil},
+		{"escapes", `x = "a\"b\\c\nd\te\r\u00e9\U0001F600"`, "a\"b\\c\nd\te\r\u00e9\U0001F600"},
+		{"escaped template sequences", `x = "$${a} %%{b} $$ %%"`, "${a} %{b} $$ %%"},
+		{"runtime interpolation", `x = "${NOMAD_PORT_http}"`, "${NOMAD_PORT_http}"},
+		{"nested braces and quotes", `x = "${lookup({ a = "}" }, "a", "{")}-y"`, `${lookup({ a = "}" }, "a", "{")}-y`},
+		{"escaped quote in a template", `x = "%{ if a == "\"}" }ok%{ endif }"`, `%{ if a == "\"}" }ok%{ endif }`},
+		{"heredoc", "x = <<EOF\n  a\n${b}\n$${c} %%{d}\nEOF\n", "  a\n${b}\n${c} %{d}\n"},
+		{"empty heredoc", "x = <<EOF\nEOF\n", ""},
+		{"heredoc at the end of the file", "x = <<EOF\na\nEOF", "a\n"},
+		{"indented heredoc", "x = <<-EOT\n    a\n      b\n\n    c\n    EOT\n", "a\n  b\n\nc\n"},
+		{"indented heredoc with tabs", "x = <<-EOT\n\t\ta\n\t\t\tb\n\t\tEOT\n", "a\n\tb\n"},
+		{"list with comments", "x = [1, \"a\", # first\n  true, // second\n]\n", []interface{}{1, "a", true}},
+		{"object", "x = { a = 1, \"b\" : \"c\"\n  d = [] }\n", map[string]interface{}{"a": 1, "b": "c", "d": []interface{}{}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body, err := Parse([]byte(test.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := body.Attribute("x")
+			if !ok {
+				t.Fatalf("x not parsed from %q: %+v", test.src, body)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("x = %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseBlocks(t *testing.T) {
+	body, err := Parse([]byte(`# job file
+job "web" {
+  datacenters = ["dc1"]
+
+  group "web" {
+    count = 2
+
+    task "app" {
+      driver = "docker"
+      env {
+        "LOG.LEVEL" = "debug"
+      }
+    }
+  }
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job := body.BlockOf("job")
+	if job == nil || job.Label() != "web" || job.Line != 2 {
+		t.Fatalf("job = %+v", job)
+	}
+	if got := job.Body.Strings("datacenters"); !reflect.DeepEqual(got, []string{"dc1"}) {
+		t.Errorf("datacenters = %v", got)
+	}
+	group := job.Body.BlockOf("group")
+	if group.Body.Int("count") != 2 || group.Line != 5 {
+		t.Errorf("group = %+v", group)
+	}
+	task := group.Body.BlockOf("task")
+	if task.Body.String("driver") != "docker" || task.Body.Attributes[0].Line != 9 {
+		t.Errorf("task = %+v", task)
+	}
+	if env := task.Body.BlockOf("env"); env == nil || env.Body.String("LOG.LEVEL") != "debug" {
+		t.Errorf("quoted attribute names aren't parsed: %+v", env)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"unterminated string", "a = 1\n\nb = \"x\n", `line 3: unterminated string`},
+		{"unterminated heredoc", "a = 1\nb = <<EOF\nx\ny\n", `line 2: unterminated heredoc EOF`},
+		{"unterminated template", "a = 1\nb = \"${c\"\n", `line 2: unterminated template sequence`},
+		{"missing brace", "job \"a\" {\n  x = 1\n", `line 3: missing '}'`},
+		{"unbalanced bracket", "x = 1)\n", `line 1: unexpected ')' after x`},
+		{"unclosed list", "\nx = [1, 2\n", `line 3: expected , or ] in list`},
+		{"invalid unicode escape", "\nx = \"\\u12\"\n", `line 2: invalid \u escape`},
+		{"missing value", "x =", `line 1: missing value`},
+		{"stray equals", "= 1\n", `line 1: unexpected '='`},
+		{"block without a body", "job \"a\" [\n", `line 1: expected = or { after job`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse([]byte(test.src))
+			if err == nil {
+				t.Fatalf("expected an error parsing %q", test.src)
+			}
+			if err.Error() != test.want {
+				t.Errorf("error = %q, want %q", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package image
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+// Media types of manifests and indexes nompose understands
+const (
+	MediaTypeOCIIndex         = "application/vnd.oci.image.index.v1+json"
+	MediaTypeOCIManifest      = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeDockerList       = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeDockerManifest   = "application/vnd.docker.distribution.manifest.v2+json"
+	annotationRefName         = "org.opencontainers.image.ref.name"
+	annotationContainerdImage = "io.containerd.image.name"
+)
+
+// Config is the part of an image config describing how containers run
+type Config struct {
+	ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+	Env          []string            `json:"Env,omitempty"`
+	Cmd          []string            `json:"Cmd,omitempty"`
+	Entrypoint   []string            `json:"Entrypoint,omitempty"`
+	WorkingDir   string              `json:"WorkingDir,omitempty"`
+	User         string              `json:"User,omitempty"`
+	Healthcheck  *Healthcheck        `json:"Healthcheck,omitempty"`
+	Volumes      map[string]struct{} `json:"Volumes,omitempty"`
+	Labels       map[string]string   `json:"Labels,omitempty"`
+}
+
+// Healthcheck is an image HEALTHCHECK; durations are in nanoseconds
+type Healthcheck struct {
+	Test        []string `json:"Test,omitempty"`
+	Interval    int64    `json:"Interval,omitempty"`
+	Timeout     int64    `json:"Timeout,omitempty"`
+	StartPeriod int64    `json:"StartPeriod,omitempty"`
+	Retries     int      `json:"Retries,omitempty"`
+}
+
+// Image is an inspected image
+type Image struct {
+	Reference string // Name the image was found under, if any
+	Config    Config
+	Source    string // Where the config was read from
+}
+
+// imageConfigFile is the image config blob; only the runtime config matters here
+type imageConfigFile struct {
+	Config Config `json:"config"`
+}
+
+// descriptor points at a manifest, index or blob
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+		Variant      string `json:"variant,omitempty"`
+	} `json:"platform,omitempty"`
+}
+
+// manifest is an image manifest or an index/manifest list
+type manifest struct {
+	MediaType string       `json:"mediaType"`
+	Config    descriptor   `json:"config"`
+	Manifests []descriptor `json:"manifests"`
+}
+
+func (m manifest) isIndex() bool {
+	return len(m.Manifests) > 0 || m.MediaType == MediaTypeOCIIndex || m.MediaType == MediaTypeDockerList
+}
+
+// parseConfig decodes an image config blob
+func parseConfig(data []byte) (Config, error) {
+	var file imageConfigFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return Config{}, fmt.Errorf("failed to parse image config: %w", err)
+	}
+	return file.Config, nil
+}
+
+// selectPlatform picks the linux manifest for this machine's architecture from
+// an index, falling back to linux/amd64 and then the first entry
+func selectPlatform(manifests []descriptor) (descriptor, error) {
+	if len(manifests) == 0 {
+		return descriptor{}, fmt.Errorf("image index lists no manifests")
+	}
+	for _, arch := range []string{runtime.GOARCH, "amd64"} {
+		for _, candidate := range manifests {
+			if candidate.Platform != nil && candidate.Platform.OS == "linux" && candidate.Platform.Architecture == arch {
+				return candidate, nil
+			}
+		}
+	}
+	return manifests[0], nil
+}
+
+// ToService describes the image as a service. Env, Cmd, Entrypoint,
+// WorkingDir, User and Labels are left out of the service because the
+// container already gets them from the image; repeating them in the job
+// would pin today's values.
+func (img *Image) ToService(name, imageRef string) types.EnhancedServiceConfig {
+	service := types.DockerComposeService{Image: imageRef}
+
+	var ports []types.PortMapping
+	for _, exposed := range sortedPorts(img.Config.ExposedPorts) {
+		port, protocol, _ := strings.Cut(exposed, "/")
+		number, err := strconv.Atoi(port)
+		if err != nil {
+			continue
+		}
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		// Exposed ports aren't published, so Nomad allocates the host port
+		ports = append(ports, types.PortMapping{Container: number, Protocol: protocol})
+		service.Expose = append(service.Expose, exposed)
+	}
+
+	for volume := range img.Config.Volumes {
+		service.Volumes = append(service.Volumes, volume)
+	}
+	sort.Strings(service.Volumes)
+
+	if check := img.Config.Healthcheck; check != nil && len(check.Test) > 0 {
+		service.HealthCheck = convertHealthcheck(check)
+	}
+
+	return types.EnhancedServiceConfig{
+		Name:            name,
+		OriginalService: service,
+		ResolvedImage:   imageRef,
+		ResolvedPorts:   ports,
+		Environment:     map[string]string{},
+		SourceFile:      img.Source,
+	}
+}
+
+// convertHealthcheck turns an image healthcheck into the compose form
+func convertHealthcheck(check *Healthcheck) *types.HealthCheckConfig {
+	config := &types.HealthCheckConfig{
+		Interval:    nanoseconds(check.Interval),
+		Timeout:     nanoseconds(check.Timeout),
+		StartPeriod: nanoseconds(check.StartPeriod),
+		Retries:     check.Retries,
+	}
+
+	if check.Test[0] == "NONE" {
+		config.Disable = true
+		return config
+	}
+
+	test := make([]interface{}, len(check.Test))
+	for i, word := range check.Test {
+		test[i] = word
+	}
+	config.Test = test
+	return config
+}
+
+// NameFromReference derives a service name from an image reference
+func NameFromReference(reference string) string {
+	name := reference
+	if at := strings.Index(name, "@"); at >= 0 {
+		name = name[:at]
+	}
+	name = path.Base(name)
+	if colon := strings.Index(name, ":"); colon >= 0 {
+		name = name[:colon]
+	}
+	name = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		if r >= 'A' && r <= 'Z' {
+			return r + ('a' - 'A')
+		}
+		return '-'
+	}, name)
+	name = strings.Trim(name, "-")
+	if name == "" {
+		return "app"
+	}
+	return name
+}
+
+func nanoseconds(value int64) string {
+	if value <= 0 {
+		return ""
+	}
+	d := time.Duration(value)
+	switch {
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	case d%time.Second == 0:
+		return fmt.Sprintf("%ds", int(d/time.Second))
+	}
+	return d.String()
+}
+
+// sortedPorts orders "80/tcp"-style keys by port number
+func sortedPorts(ports map[string]struct{}) []string {
+	keys := make([]string, 0, len(ports))
+	for key := range ports {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, _ := strconv.Atoi(strings.Split(keys[i], "/")[0])
+		b, _ := strconv.Atoi(strings.Split(keys[j], "/")[0])
+		if a != b {
+			return a < b
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
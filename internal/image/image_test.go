@@ -0,0 +1,353 @@
+package image
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+const testConfig = `{
+  "architecture": "amd64",
+  "os": "linux",
+  "config": {
+    "ExposedPorts": {"8080/tcp": {}, "53/udp": {}},
+    "Env": ["PATH=/usr/bin", "APP_ENV=production"],
+    "Cmd": ["serve"],
+    "Entrypoint": ["/app"],
+    "WorkingDir": "/srv",
+    "User": "app",
+    "Healthcheck": {"Test": ["CMD", "/app", "health"], "Interval": 30000000000, "Timeout": 5000000000, "Retries": 3},
+    "Volumes": {"/data": {}},
+    "Labels": {"org.opencontainers.image.version": "1.2.0"}
+  }
+}`
+
+func checkConfig(t *testing.T, img *Image) {
+	t.Helper()
+
+	service := img.ToService("app", "example.com/app:1.2.0")
+	if len(service.ResolvedPorts) != 2 || service.ResolvedPorts[0].Container != 53 || service.ResolvedPorts[0].Protocol != "udp" || service.ResolvedPorts[1].Container != 8080 {
+		t.Errorf("unexpected ports: %+v", service.ResolvedPorts)
+	}
+	for _, port := range service.ResolvedPorts {
+		if port.Host != 0 {
+			t.Errorf("exposed port %d is published on host port %d, want a dynamic port", port.Container, port.Host)
+		}
+	}
+	check := service.OriginalService.HealthCheck
+	if check == nil || check.Interval != "30s" || check.Timeout != "5s" || check.Retries != 3 || len(check.Test.([]interface{})) != 3 {
+		t.Errorf("unexpected healthcheck: %+v", check)
+	}
+	if len(service.OriginalService.Volumes) != 1 || service.OriginalService.Volumes[0] != "/data" {
+		t.Errorf("unexpected volumes: %v", service.OriginalService.Volumes)
+	}
+	if img.Config.User != "app" || img.Config.WorkingDir != "/srv" || len(img.Config.Env) != 2 {
+		t.Errorf("unexpected config: %+v", img.Config)
+	}
+}
+
+const testDigest = "4c2c5fe3e6a6e4b1b8b5c0f0f1a3d5c7e9b0a2c4d6e8f0a1b3c5d7e9f1a3b5c7"
+
+func digest(data string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(data)))
+}
+
+func TestFromRegistry(t *testing.T) {
+	manifest := fmt.Sprintf(`{"schemaVersion": 2, "mediaType": %q, "config": {"mediaType": "application/vnd.oci.image.config.v1+json", "digest": %q}}`, MediaTypeOCIManifest, digest(testConfig))
+	index := fmt.Sprintf(`{"schemaVersion": 2, "mediaType": %q, "manifests": [
+		{"digest": "sha256:s390x", "platform": {"os": "linux", "architecture": "s390x"}},
+		{"digest": %q, "platform": {"os": "linux", "architecture": "amd64"}}
+	]}`, MediaTypeOCIIndex, digest(manifest))
+
+	var registry *httptest.Server
+	registry = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.URL.Query().Get("scope") != "repository:team/app:pull" {
+				t.Errorf("unexpected token scope %q", r.URL.Query().Get("scope"))
+			}
+			fmt.Fprint(w, `{"token": "secret"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, registry.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/team/app/manifests/1.2.0":
+			fmt.Fprint(w, index)
+		case "/v2/team/app/manifests/" + digest(manifest):
+			fmt.Fprint(w, manifest)
+		case "/v2/team/app/blobs/" + digest(testConfig):
+			fmt.Fprint(w, testConfig)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer registry.Close()
+
+	host := strings.TrimPrefix(registry.URL, "http://")
+	img, err := NewRegistryClient(registry.Client()).FromRegistry(context.Background(), host+"/team/app:1.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkConfig(t, img)
+}
+
+func TestFromTarball(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.tar")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := tar.NewWriter(file)
+	for name, content := range map[string]string{
+		"manifest.json": `[{"Config": "abc.json", "RepoTags": ["example.com/app:1.2.0"], "Layers": ["layer.tar"]}]`,
+		"abc.json":      testConfig,
+		"layer.tar":     "",
+		"repositories":  "{}",
+	} {
+		if err := archive.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := archive.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	img, err := FromTarball(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Reference != "example.com/app:1.2.0" {
+		t.Errorf("unexpected reference %q", img.Reference)
+	}
+	checkConfig(t, img)
+}
+
+func TestFromLayout(t *testing.T) {
+	dir := t.TempDir()
+	manifest := fmt.Sprintf(`{"schemaVersion": 2, "config": {"digest": %q}}`, digest(testConfig))
+	index := fmt.Sprintf(`{"schemaVersion": 2, "manifests": [{"mediaType": %q, "digest": %q, "annotations": {%q: "1.2.0"}}]}`, MediaTypeOCIManifest, digest(manifest), annotationRefName)
+
+	files := map[string]string{
+		"oci-layout":                 `{"imageLayoutVersion": "1.0.0"}`,
+		"index.json":                 index,
+		blobPath(digest(manifest)):   manifest,
+		blobPath(digest(testConfig)): testConfig,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !IsLayout(dir) {
+		t.Fatal("layout not detected")
+	}
+	img, err := FromLayout(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkConfig(t, img)
+}
+
+func TestParseReference(t *testing.T) {
+	tests := map[string]Reference{
+		"nginx":                    {Registry: "docker.io", Repository: "library/nginx"},
+		"nginx:alpine":             {Registry: "docker.io", Repository: "library/nginx", Tag: "alpine"},
+		"library/nginx":            {Registry: "docker.io", Repository: "library/nginx"},
+		"bitnami/redis:7.2":        {Registry: "docker.io", Repository: "bitnami/redis", Tag: "7.2"},
+		"localhost:5000/app":       {Registry: "localhost:5000", Repository: "app"},
+		"localhost/app:dev":        {Registry: "localhost", Repository: "app", Tag: "dev"},
+		"ghcr.io/org/app:1.2":      {Registry: "ghcr.io", Repository: "org/app", Tag: "1.2"},
+		"app@sha256:" + testDigest: {Registry: "docker.io", Repository: "library/app", Digest: "sha256:" + testDigest},
+		"quay.io/org/app:v1@sha256:" + testDigest: {Registry: "quay.io", Repository: "org/app", Tag: "v1", Digest: "sha256:" + testDigest},
+	}
+	for input, want := range tests {
+		got, err := ParseReference(input)
+		if err != nil {
+			t.Errorf("ParseReference(%q): %v", input, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseReference(%q) = %+v, want %+v", input, got, want)
+		}
+	}
+
+	for _, input := range []string{"", "C:file.yml", "./app", "My/App", "app:", "app:-bad", "app@sha256:abc", "ghcr.io/", "Ghcr_io/app"} {
+		if _, err := ParseReference(input); err == nil {
+			t.Errorf("ParseReference(%q) accepted an invalid reference", input)
+		}
+	}
+}
+
+func TestReferenceDefaults(t *testing.T) {
+	if !SameImage("nginx", "docker.io/library/nginx:latest") {
+		t.Error("nginx and docker.io/library/nginx:latest should be the same image")
+	}
+	if SameImage("nginx:1.25", "nginx") {
+		t.Error("nginx:1.25 and nginx should differ")
+	}
+
+	for input, mutable := range map[string]bool{"nginx": true, "nginx:latest": true, "nginx:1.25": false, "nginx@sha256:" + testDigest: false} {
+		ref, err := ParseReference(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ref.Mutable() != mutable {
+			t.Errorf("%s: Mutable() = %v, want %v", input, ref.Mutable(), mutable)
+		}
+	}
+}
+
+func TestRewriter(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), LockFileName)
+	lockContent := "images:\n  registry.corp/dockerhub/library/redis:latest: sha256:" + testDigest + "\n"
+	if err := os.WriteFile(lockPath, []byte(lockContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lock, err := ReadLockfile(lockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rewriter, err := NewRewriter([]types.ImageRewrite{
+		{Match: "docker.io/*", Replace: "registry.corp/dockerhub/*"},
+		{Match: "registry.corp/dockerhub/library/postgres", Tag: `^(\d+)$`, Pin: "$1.4"},
+		{Match: "*", Tag: "^latest$", Lock: true},
+	}, lock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"nginx:1.25":                 "registry.corp/dockerhub/library/nginx:1.25",
+		"bitnami/redis:7.2":          "registry.corp/dockerhub/bitnami/redis:7.2",
+		"postgres:16":                "registry.corp/dockerhub/library/postgres:16.4",
+		"postgres:16-alpine":         "registry.corp/dockerhub/library/postgres:16-alpine",
+		"redis":                      "registry.corp/dockerhub/library/redis@sha256:" + testDigest,
+		"ghcr.io/org/app:1.0":        "ghcr.io/org/app:1.0",
+		"registry.corp/team/api:abc": "registry.corp/team/api:abc",
+	}
+	for input, want := range tests {
+		got, _, err := rewriter.Rewrite(input)
+		if err != nil {
+			t.Errorf("Rewrite(%q): %v", input, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("Rewrite(%q) = %q, want %q", input, got, want)
+		}
+	}
+
+	// :latest images missing from the lock keep their tag, with a note saying so
+	got, notes, err := rewriter.Rewrite("ghcr.io/org/app")
+	if err != nil || got != "ghcr.io/org/app" || len(notes) != 1 || !strings.Contains(notes[0], "no digest") {
+		t.Errorf("Rewrite(ghcr.io/org/app) = %q, %v, %v", got, notes, err)
+	}
+
+	for _, rules := range [][]types.ImageRewrite{
+		{{Replace: "mirror/*"}},
+		{{Match: "nginx"}},
+		{{Match: "nginx", Replace: "*/*"}},
+		{{Match: "*", Tag: "(", Pin: "1"}},
+	} {
+		if _, err := NewRewriter(rules, nil); err == nil {
+			t.Errorf("NewRewriter(%+v) accepted an invalid rule", rules)
+		}
+	}
+}
+
+func TestLockfile(t *testing.T) {
+	manifest := fmt.Sprintf(`{"schemaVersion": 2, "mediaType": %q, "config": {"digest": %q}}`, MediaTypeOCIManifest, digest(testConfig))
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/team/app/manifests/1.2.0":
+			fmt.Fprint(w, manifest)
+		case "/v2/team/app/manifests/signed":
+			// Registries report the digest of the manifest as stored, which
+			// needn't match the bytes served for the negotiated format
+			w.Header().Set("Docker-Content-Digest", "sha256:"+testDigest)
+			fmt.Fprint(w, manifest)
+		case "/v2/team/app/manifests/huge":
+			w.Write(make([]byte, maxMetadataSize+1))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer registry.Close()
+
+	host := strings.TrimPrefix(registry.URL, "http://")
+	client := NewRegistryClient(registry.Client())
+	app := host + "/team/app:1.2.0"
+	for reference, want := range map[string]string{
+		app:                       digest(manifest),
+		host + "/team/app:signed": "sha256:" + testDigest,
+	} {
+		got, err := client.Digest(context.Background(), reference)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("Digest(%s) = %s, want %s", reference, got, want)
+		}
+	}
+	if _, err := client.Digest(context.Background(), host+"/team/app:huge"); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("expected an oversized manifest to fail, got %v", err)
+	}
+
+	layout := t.TempDir()
+	index := fmt.Sprintf(`{"schemaVersion": 2, "manifests": [{"digest": %q, "annotations": {%q: "docker.io/library/redis:7"}}]}`, digest(manifest), annotationContainerdImage)
+	if err := os.WriteFile(filepath.Join(layout, "index.json"), []byte(index), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, found, err := LayoutDigest(layout, "redis:7"); err != nil || !found || got != digest(manifest) {
+		t.Errorf("LayoutDigest(redis:7) = %s, %v, %v", got, found, err)
+	}
+	if _, found, _ := LayoutDigest(layout, "redis:6"); found {
+		t.Error("LayoutDigest matched another tag")
+	}
+
+	lock := NewLockfile()
+	for _, reference := range []string{app, "redis:7"} {
+		if err := lock.Lock(reference, digest(manifest)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), LockFileName)
+	if err := lock.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadLockfile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pinned, ok := read.Pin("docker.io/library/redis:7"); !ok || pinned != "docker.io/library/redis@"+digest(manifest) {
+		t.Errorf("Pin(redis:7) = %s, %v", pinned, ok)
+	}
+	if _, ok := read.Pin("redis:6"); ok {
+		t.Error("Pin found an image that isn't locked")
+	}
+	if missing, err := ReadLockfile(filepath.Join(t.TempDir(), LockFileName)); missing != nil || err != nil {
+		t.Errorf("a missing lockfile should read as nil, got %v, %v", missing, err)
+	}
+}
@@ -0,0 +1,137 @@
+package image
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IsLayout reports whether dir is an OCI image layout
+func IsLayout(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, "oci-layout"))
+	return err == nil
+}
+
+// FromLayout reads an image from an OCI image layout directory. With an
+// empty name the layout must hold exactly one image; otherwise name is
+// matched against the ref.name annotations of the index.
+func FromLayout(dir, name string) (*Image, error) {
+	return readLayout(func(path string) ([]byte, error) {
+		return os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+	}, name, dir)
+}
+
+// readLayout walks an OCI layout through read, which returns files by
+// slash-separated path relative to the layout root
+func readLayout(read func(string) ([]byte, error), name, source string) (*Image, error) {
+	var index manifest
+	if err := readJSON(read, "index.json", &index); err != nil {
+		return nil, fmt.Errorf("failed to read OCI layout index: %w", err)
+	}
+
+	desc, reference, err := selectLayoutManifest(index.Manifests, name)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var m manifest
+		if err := readJSON(read, blobPath(desc.Digest), &m); err != nil {
+			return nil, fmt.Errorf("failed to read manifest %s: %w", desc.Digest, err)
+		}
+		if !m.isIndex() {
+			data, err := read(blobPath(m.Config.Digest))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read image config %s: %w", m.Config.Digest, err)
+			}
+			config, err := parseConfig(data)
+			if err != nil {
+				return nil, err
+			}
+			return &Image{Reference: reference, Config: config, Source: source}, nil
+		}
+		if desc, err = selectPlatform(m.Manifests); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// LayoutDigest returns the digest an OCI layout records for reference, found
+// by the image name annotations; unlike FromLayout a lone image only matches
+// when its name does
+func LayoutDigest(dir, reference string) (string, bool, error) {
+	var index manifest
+	read := func(path string) ([]byte, error) {
+		return os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+	}
+	if err := readJSON(read, "index.json", &index); err != nil {
+		return "", false, fmt.Errorf("failed to read OCI layout index in %s: %w", dir, err)
+	}
+	for _, desc := range index.Manifests {
+		if name := layoutReference(desc); name != "" && SameImage(name, reference) {
+			return desc.Digest, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// selectLayoutManifest finds the index entry for name and the reference it's known by
+func selectLayoutManifest(manifests []descriptor, name string) (descriptor, string, error) {
+	if len(manifests) == 0 {
+		return descriptor{}, "", fmt.Errorf("OCI layout contains no images")
+	}
+
+	if name == "" {
+		if len(manifests) > 1 {
+			return descriptor{}, "", fmt.Errorf("OCI layout contains %d images, choose one with --image (%s)", len(manifests), strings.Join(layoutNames(manifests), ", "))
+		}
+		return manifests[0], layoutReference(manifests[0]), nil
+	}
+
+	for _, desc := range manifests {
+		if desc.Annotations[annotationRefName] == name || desc.Annotations[annotationContainerdImage] == name {
+			return desc, layoutReference(desc), nil
+		}
+	}
+	// A single image is taken whatever it's called; name is then the name it's deployed as
+	if len(manifests) == 1 {
+		return manifests[0], layoutReference(manifests[0]), nil
+	}
+	return descriptor{}, "", fmt.Errorf("image %q not found in OCI layout (available: %s)", name, strings.Join(layoutNames(manifests), ", "))
+}
+
+// layoutReference prefers the full image name containerd records over the bare tag
+func layoutReference(desc descriptor) string {
+	if name := desc.Annotations[annotationContainerdImage]; name != "" {
+		return name
+	}
+	return desc.Annotations[annotationRefName]
+}
+
+func layoutNames(manifests []descriptor) []string {
+	var names []string
+	for _, desc := range manifests {
+		if name := layoutReference(desc); name != "" {
+			names = append(names, name)
+		} else {
+			names = append(names, desc.Digest)
+		}
+	}
+	return names
+}
+
+// blobPath locates a blob by digest in a layout
+func blobPath(digest string) string {
+	algorithm, hex, _ := strings.Cut(digest, ":")
+	return "blobs/" + algorithm + "/" + hex
+}
+
+func readJSON(read func(string) ([]byte, error), path string, v interface{}) error {
+	data, err := read(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,107 @@
+package image
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LockFileName is the lockfile pinning images to digests
+const LockFileName = "nompose.lock"
+
+// Lockfile maps canonical image references to the digests they resolved to
+type Lockfile struct {
+	Images map[string]string `yaml:"images"`
+}
+
+// ReadLockfile reads a lockfile; a missing file returns nil and no error
+func ReadLockfile(path string) (*Lockfile, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var lock Lockfile
+	if err := yaml.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for reference, digest := range lock.Images {
+		if !digestPattern.MatchString(digest) {
+			return nil, fmt.Errorf("invalid digest %q for %s in %s", digest, reference, path)
+		}
+	}
+	return &lock, nil
+}
+
+// NewLockfile creates an empty lockfile
+func NewLockfile() *Lockfile {
+	return &Lockfile{Images: make(map[string]string)}
+}
+
+// Write saves the lockfile, images sorted by name
+func (l *Lockfile) Write(path string) error {
+	var content bytes.Buffer
+	content.WriteString("# Written by nompose lock: the digest every image resolved to.\n# Commit it; nompose generate pins images to these digests.\n")
+	encoder := yaml.NewEncoder(&content)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(l); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, content.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// Lock records the digest of an image
+func (l *Lockfile) Lock(reference, digest string) error {
+	ref, err := ParseReference(reference)
+	if err != nil {
+		return err
+	}
+	l.Images[lockKey(ref)] = digest
+	return nil
+}
+
+// Pin returns the image pinned to its locked digest, e.g.
+// docker.io/library/nginx@sha256:...; images already naming a digest are
+// returned as they are
+func (l *Lockfile) Pin(reference string) (string, bool) {
+	ref, err := ParseReference(reference)
+	if err != nil {
+		return reference, false
+	}
+	if ref.Digest != "" {
+		return reference, true
+	}
+	digest, ok := l.Digest(reference)
+	if !ok {
+		return reference, false
+	}
+	ref.Tag, ref.Digest = "", digest
+	return ref.String(), true
+}
+
+// Digest returns the digest locked for an image, if any
+func (l *Lockfile) Digest(reference string) (string, bool) {
+	if l == nil {
+		return "", false
+	}
+	ref, err := ParseReference(reference)
+	if err != nil {
+		return "", false
+	}
+	digest, ok := l.Images[lockKey(ref)]
+	return digest, ok
+}
+
+// lockKey is the lockfile key of an image: its canonical name and tag
+func lockKey(ref Reference) string {
+	ref.Digest = ""
+	return ref.String()
+}
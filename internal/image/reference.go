@@ -0,0 +1,140 @@
+package image
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Docker Hub defaults applied to short references
+const (
+	DefaultRegistry  = "docker.io"
+	DefaultTag       = "latest"
+	registryEndpoint = "registry-1.docker.io"
+	maxNameLength    = 255
+)
+
+var (
+	domainPattern    = regexp.MustCompile(`^(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*(?::[0-9]+)?$`)
+	componentPattern = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*$`)
+	tagPattern       = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	digestPattern    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,}$`)
+)
+
+// Reference is a parsed image reference. Tag and Digest are empty when the
+// reference doesn't name them; Identifier applies the :latest default.
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference splits an image reference into registry, repository, tag
+// and digest, filling in Docker Hub defaults for the registry and library/
+// namespace, and rejects references docker would refuse
+func ParseReference(reference string) (Reference, error) {
+	var ref Reference
+	if reference == "" {
+		return ref, fmt.Errorf("image reference is empty")
+	}
+
+	name := reference
+	if at := strings.Index(name, "@"); at >= 0 {
+		ref.Digest = name[at+1:]
+		name = name[:at]
+		if !digestPattern.MatchString(ref.Digest) {
+			return ref, fmt.Errorf("invalid digest %q in image reference %q", ref.Digest, reference)
+		}
+	}
+	if colon := strings.LastIndex(name, ":"); colon > strings.LastIndex(name, "/") {
+		ref.Tag = name[colon+1:]
+		name = name[:colon]
+		if !tagPattern.MatchString(ref.Tag) {
+			return ref, fmt.Errorf("invalid tag %q in image reference %q", ref.Tag, reference)
+		}
+	}
+
+	// The first component is a registry when it looks like a host
+	if slash := strings.Index(name, "/"); slash >= 0 {
+		host := name[:slash]
+		if strings.ContainsAny(host, ".:") || host == "localhost" || strings.ToLower(host) != host {
+			if !domainPattern.MatchString(host) {
+				return ref, fmt.Errorf("invalid registry %q in image reference %q", host, reference)
+			}
+			ref.Registry = host
+			name = name[slash+1:]
+		}
+	}
+	if ref.Registry == "" {
+		ref.Registry = DefaultRegistry
+	}
+
+	if name == "" || len(name) > maxNameLength {
+		return ref, fmt.Errorf("invalid repository name in image reference %q", reference)
+	}
+	for _, component := range strings.Split(name, "/") {
+		if !componentPattern.MatchString(component) {
+			return ref, fmt.Errorf("invalid repository name %q in image reference %q (lowercase letters, digits and separators only)", name, reference)
+		}
+	}
+	if ref.Registry == DefaultRegistry && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	ref.Repository = name
+
+	return ref, nil
+}
+
+// Identifier returns the digest if there is one, the tag otherwise
+func (r Reference) Identifier() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	if r.Tag == "" {
+		return DefaultTag
+	}
+	return r.Tag
+}
+
+// Untagged reports whether the reference names neither a tag nor a digest
+func (r Reference) Untagged() bool {
+	return r.Tag == "" && r.Digest == ""
+}
+
+// Mutable reports whether the reference resolves through the latest tag,
+// explicitly or by default, without pinning a digest
+func (r Reference) Mutable() bool {
+	return r.Digest == "" && (r.Tag == "" || r.Tag == DefaultTag)
+}
+
+// String formats the reference in its canonical long form
+func (r Reference) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	} else if r.Digest == "" {
+		s += ":" + DefaultTag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// IsReference reports whether s parses as an image reference
+func IsReference(s string) bool {
+	_, err := ParseReference(s)
+	return err == nil
+}
+
+// SameImage reports whether two references name the same image once
+// defaults are applied (nginx and docker.io/library/nginx:latest do)
+func SameImage(a, b string) bool {
+	refA, errA := ParseReference(a)
+	refB, errB := ParseReference(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return refA.String() == refB.String()
+}
@@ -0,0 +1,227 @@
+package image
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// manifestAccept lists the manifest formats requested from registries
+var manifestAccept = strings.Join([]string{
+	MediaTypeOCIIndex,
+	MediaTypeOCIManifest,
+	MediaTypeDockerList,
+	MediaTypeDockerManifest,
+}, ", ")
+
+// RegistryClient fetches image configs over the registry distribution API
+type RegistryClient struct {
+	client *http.Client
+	tokens map[string]string // Bearer tokens by repository
+	// Insecure lists registries spoken to over plain http; localhost and
+	// 127.0.0.1 always are
+	Insecure map[string]bool
+}
+
+// NewRegistryClient creates a registry client using the given HTTP client
+func NewRegistryClient(client *http.Client) *RegistryClient {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &RegistryClient{
+		client:   client,
+		tokens:   make(map[string]string),
+		Insecure: make(map[string]bool),
+	}
+}
+
+// FromRegistry reads an image config from the registry hosting reference
+func (c *RegistryClient) FromRegistry(ctx context.Context, reference string) (*Image, error) {
+	ref, err := ParseReference(reference)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _, err := c.get(ctx, ref, "manifests/"+ref.Identifier(), manifestAccept)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest for %s: %w", reference, err)
+	}
+
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest for %s: %w", reference, err)
+	}
+	if m.isIndex() {
+		desc, err := selectPlatform(m.Manifests)
+		if err != nil {
+			return nil, err
+		}
+		if data, _, err = c.get(ctx, ref, "manifests/"+desc.Digest, manifestAccept); err != nil {
+			return nil, fmt.Errorf("failed to fetch platform manifest for %s: %w", reference, err)
+		}
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("failed to parse platform manifest for %s: %w", reference, err)
+		}
+	}
+
+	data, _, err = c.get(ctx, ref, "blobs/"+m.Config.Digest, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch image config for %s: %w", reference, err)
+	}
+	config, err := parseConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Image{Reference: reference, Config: config, Source: ref.Registry}, nil
+}
+
+// Digest resolves a reference to the digest of its manifest, or of its
+// index for multi-platform images, as pulling by digest expects. The
+// registry's Docker-Content-Digest header is preferred over hashing the body.
+func (c *RegistryClient) Digest(ctx context.Context, reference string) (string, error) {
+	ref, err := ParseReference(reference)
+	if err != nil {
+		return "", err
+	}
+	if ref.Digest != "" {
+		return ref.Digest, nil
+	}
+
+	data, header, err := c.get(ctx, ref, "manifests/"+ref.Identifier(), manifestAccept)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch manifest for %s: %w", reference, err)
+	}
+	if digest := header.Get("Docker-Content-Digest"); digest != "" {
+		if !strings.HasPrefix(digest, "sha256:") {
+			return "", fmt.Errorf("registry sent an unsupported digest %q for %s", digest, reference)
+		}
+		return digest, nil
+	}
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data)), nil
+}
+
+// get requests a repository path, authenticating once if the registry asks,
+// and returns the body and headers of the response
+func (c *RegistryClient) get(ctx context.Context, ref Reference, resource, accept string) ([]byte, http.Header, error) {
+	endpoint := c.baseURL(ref.Registry) + "/v2/" + ref.Repository + "/" + resource
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if token := c.tokens[ref.Repository]; token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxMetadataSize+1))
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(body) > maxMetadataSize {
+			return nil, nil, fmt.Errorf("registry response for %s is larger than %d bytes", resource, maxMetadataSize)
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			return body, resp.Header, nil
+		case resp.StatusCode == http.StatusUnauthorized && attempt == 0:
+			if err := c.authenticate(ctx, ref, resp.Header.Get("WWW-Authenticate")); err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, fmt.Errorf("registry returned %s", resp.Status)
+		}
+	}
+}
+
+// authenticate fetches an anonymous pull token from the realm named in a
+// Bearer challenge
+func (c *RegistryClient) authenticate(ctx context.Context, ref Reference, challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return fmt.Errorf("registry requires unsupported %q authentication", scheme)
+	}
+
+	values := parseChallenge(params)
+	realm, err := url.Parse(values["realm"])
+	if err != nil || values["realm"] == "" {
+		return fmt.Errorf("registry sent an invalid auth challenge: %q", challenge)
+	}
+	query := realm.Query()
+	if service := values["service"]; service != "" {
+		query.Set("service", service)
+	}
+	query.Set("scope", "repository:"+ref.Repository+":pull")
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch registry token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch registry token: %s", resp.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("failed to parse registry token: %w", err)
+	}
+	c.tokens[ref.Repository] = valueOrDefault(token.Token, token.AccessToken)
+	return nil
+}
+
+// baseURL maps a registry host to its API endpoint
+func (c *RegistryClient) baseURL(registry string) string {
+	if registry == DefaultRegistry {
+		registry = registryEndpoint
+	}
+	host := registry
+	if h, _, found := strings.Cut(registry, ":"); found {
+		host = h
+	}
+	if c.Insecure[registry] || host == "localhost" || host == "127.0.0.1" {
+		return "http://" + registry
+	}
+	return "https://" + registry
+}
+
+// parseChallenge splits `key="value",key="value"` challenge parameters
+func parseChallenge(params string) map[string]string {
+	values := make(map[string]string)
+	for _, part := range strings.Split(params, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if found {
+			values[strings.ToLower(key)] = strings.Trim(value, `"`)
+		}
+	}
+	return values
+}
+
+func valueOrDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
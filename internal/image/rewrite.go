@@ -0,0 +1,161 @@
+package image
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+// Rewriter applies image rewrite rules, e.g. to pull through an internal mirror
+type Rewriter struct {
+	rules []rewriteRule
+	lock  *Lockfile
+}
+
+// rewriteRule is a rule with its patterns compiled
+type rewriteRule struct {
+	types.ImageRewrite
+	match *regexp.Regexp
+	tag   *regexp.Regexp
+}
+
+// NewRewriter compiles rewrite rules; lock may be nil when there is no lockfile
+func NewRewriter(rules []types.ImageRewrite, lock *Lockfile) (*Rewriter, error) {
+	rewriter := &Rewriter{lock: lock}
+	for i, rule := range rules {
+		if rule.Match == "" {
+			return nil, fmt.Errorf("image rewrite %d has no match", i+1)
+		}
+		if rule.Replace == "" && rule.Pin == "" && !rule.Lock {
+			return nil, fmt.Errorf("image rewrite %q changes nothing: set replace, pin or lock", rule.Match)
+		}
+		if strings.Count(rule.Replace, "*") > strings.Count(rule.Match, "*") {
+			return nil, fmt.Errorf("image rewrite %q: replace %q has more * than match", rule.Match, rule.Replace)
+		}
+
+		compiled := rewriteRule{ImageRewrite: rule, match: globPattern(canonicalName(rule.Match))}
+		if rule.Tag != "" {
+			tag, err := regexp.Compile(rule.Tag)
+			if err != nil {
+				return nil, fmt.Errorf("image rewrite %q: invalid tag pattern: %w", rule.Match, err)
+			}
+			compiled.tag = tag
+		}
+		rewriter.rules = append(rewriter.rules, compiled)
+	}
+	return rewriter, nil
+}
+
+// Empty reports whether the rewriter has no rules
+func (r *Rewriter) Empty() bool {
+	return r == nil || len(r.rules) == 0
+}
+
+// Rewrite applies every matching rule to an image, in order. It returns the
+// rewritten image, unchanged when no rule applies, and a note per rule that
+// matched. References that don't parse are left alone.
+func (r *Rewriter) Rewrite(reference string) (string, []string, error) {
+	if r.Empty() {
+		return reference, nil, nil
+	}
+	ref, err := ParseReference(reference)
+	if err != nil {
+		return reference, nil, nil
+	}
+
+	var notes []string
+	changed := false
+	for _, rule := range r.rules {
+		groups := rule.match.FindStringSubmatch(ref.Registry + "/" + ref.Repository)
+		if groups == nil {
+			continue
+		}
+		tag := ref.Tag
+		if tag == "" && ref.Digest == "" {
+			tag = DefaultTag
+		}
+		if rule.tag != nil && (tag == "" || !rule.tag.MatchString(tag)) {
+			continue
+		}
+
+		if rule.Replace != "" {
+			name := expandGlob(rule.Replace, groups[1:])
+			renamed, err := ParseReference(name)
+			if err != nil || renamed.Tag != "" || renamed.Digest != "" {
+				return reference, notes, fmt.Errorf("image rewrite %q turns %s into invalid repository %q", rule.Match, reference, name)
+			}
+			ref.Registry, ref.Repository = renamed.Registry, renamed.Repository
+			notes = append(notes, fmt.Sprintf("%s → %s", rule.Match, rule.Replace))
+			changed = true
+		}
+
+		if rule.Pin != "" {
+			pinned := rule.Pin
+			if rule.tag != nil {
+				pinned = rule.tag.ReplaceAllString(tag, rule.Pin)
+			}
+			if !tagPattern.MatchString(pinned) {
+				return reference, notes, fmt.Errorf("image rewrite %q pins %s to invalid tag %q", rule.Match, reference, pinned)
+			}
+			if pinned != tag || ref.Digest != "" {
+				ref.Tag, ref.Digest = pinned, ""
+				notes = append(notes, fmt.Sprintf("tag %s pinned to %s", tag, pinned))
+				changed = true
+			}
+		}
+
+		if rule.Lock && ref.Digest == "" {
+			digest, ok := r.lock.Digest(ref.String())
+			if !ok {
+				notes = append(notes, fmt.Sprintf("no digest for %s in %s", ref, LockFileName))
+				continue
+			}
+			ref.Tag, ref.Digest = "", digest
+			notes = append(notes, fmt.Sprintf("tag %s locked to its digest from %s", tag, LockFileName))
+			changed = true
+		}
+	}
+
+	if !changed {
+		return reference, notes, nil
+	}
+	return ref.String(), notes, nil
+}
+
+// canonicalName expands a plain repository like nginx to docker.io/library/nginx
+// so rules can use the short names people write; globs are kept as written
+func canonicalName(pattern string) string {
+	if strings.Contains(pattern, "*") {
+		return pattern
+	}
+	ref, err := ParseReference(pattern)
+	if err != nil {
+		return pattern
+	}
+	return ref.Registry + "/" + ref.Repository
+}
+
+// globPattern turns a glob where * matches anything into an anchored regexp
+// capturing each *
+func globPattern(glob string) *regexp.Regexp {
+	parts := strings.Split(glob, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, "(.*)") + "$")
+}
+
+// expandGlob replaces each * of a replacement with the matching capture
+func expandGlob(replacement string, captures []string) string {
+	parts := strings.Split(replacement, "*")
+	var b strings.Builder
+	for i, part := range parts {
+		if i > 0 {
+			b.WriteString(captures[i-1])
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
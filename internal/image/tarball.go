@@ -0,0 +1,149 @@
+package image
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// maxMetadataSize bounds the tar entries kept in memory; manifests and
+// configs are far smaller, layers are skipped
+const maxMetadataSize = 4 << 20
+
+// IsTarball reports whether path looks like an image archive
+func IsTarball(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasSuffix(lower, ".tar") || strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz")
+}
+
+// dockerSaveEntry is one image in a `docker save` manifest.json
+type dockerSaveEntry struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+}
+
+// FromTarball reads an image from a `docker save` archive or a tarred OCI
+// layout, optionally gzipped. name picks an image by repo tag when the
+// archive holds several.
+func FromTarball(path, name string) (*Image, error) {
+	files, err := readArchive(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image archive: %w", err)
+	}
+	read := func(name string) ([]byte, error) {
+		data, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", name, fs.ErrNotExist)
+		}
+		return data, nil
+	}
+
+	// docker save writes manifest.json; newer versions add an OCI index too
+	if data, ok := files["manifest.json"]; ok {
+		return fromDockerSave(read, data, name, path)
+	}
+	if _, ok := files["index.json"]; ok {
+		return readLayout(read, name, path)
+	}
+	return nil, fmt.Errorf("%s is neither a docker save archive nor an OCI layout", path)
+}
+
+func fromDockerSave(read func(string) ([]byte, error), data []byte, name, source string) (*Image, error) {
+	var entries []dockerSaveEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest.json: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("image archive contains no images")
+	}
+
+	entry, reference, err := selectSavedImage(entries, name)
+	if err != nil {
+		return nil, err
+	}
+
+	configData, err := read(entry.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image config: %w", err)
+	}
+	config, err := parseConfig(configData)
+	if err != nil {
+		return nil, err
+	}
+	return &Image{Reference: reference, Config: config, Source: source}, nil
+}
+
+// selectSavedImage finds the archive entry tagged name, or the only entry
+// whatever it's tagged; name is then the name it's deployed as
+func selectSavedImage(entries []dockerSaveEntry, name string) (dockerSaveEntry, string, error) {
+	var tags []string
+	for _, entry := range entries {
+		for _, tag := range entry.RepoTags {
+			if name != "" && SameImage(tag, name) {
+				return entry, tag, nil
+			}
+			tags = append(tags, tag)
+		}
+	}
+
+	if name != "" && len(entries) > 1 {
+		return dockerSaveEntry{}, "", fmt.Errorf("image %q not found in archive (available: %s)", name, strings.Join(tags, ", "))
+	}
+	if len(entries) > 1 {
+		return dockerSaveEntry{}, "", fmt.Errorf("archive contains %d images, choose one with --image (%s)", len(entries), strings.Join(tags, ", "))
+	}
+
+	reference := ""
+	if len(entries[0].RepoTags) > 0 {
+		reference = entries[0].RepoTags[0]
+	}
+	return entries[0], reference, nil
+}
+
+// readArchive loads the small files of a tar archive keyed by cleaned path
+func readArchive(filePath string) (map[string][]byte, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var reader io.Reader = bufio.NewReader(file)
+	if magic, err := reader.(*bufio.Reader).Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	files := make(map[string][]byte)
+	archive := tar.NewReader(reader)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg || header.Size > maxMetadataSize {
+			continue
+		}
+		data, err := io.ReadAll(archive)
+		if err != nil {
+			return nil, err
+		}
+		files[path.Clean(strings.TrimPrefix(header.Name, "./"))] = data
+	}
+	return files, nil
+}
@@ -0,0 +1,61 @@
+package interactive
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ChooseSources asks which of the numbered sources to convert and returns
+// their indexes; ENTER or "all" picks every source
+func (c *Confirmer) ChooseSources(count int) ([]int, error) {
+	for {
+		fmt.Fprintf(c.out, "   Convert which sources? (e.g. 1,3 or 2-4; ENTER for all): ")
+		if !c.scanner.Scan() {
+			return nil, fmt.Errorf("failed to read user input")
+		}
+
+		chosen, err := parseSelection(strings.TrimSpace(c.scanner.Text()), count)
+		if err != nil {
+			fmt.Fprintf(c.out, "   ❌ %v\n", err)
+			continue
+		}
+		return chosen, nil
+	}
+}
+
+// parseSelection turns "1,3-4" into zero-based indexes, in list order
+func parseSelection(input string, count int) ([]int, error) {
+	if input == "" || strings.EqualFold(input, "all") {
+		all := make([]int, count)
+		for i := range all {
+			all[i] = i
+		}
+		return all, nil
+	}
+
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(input, ",") {
+		part = strings.TrimSpace(part)
+		from, to, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(strings.TrimSpace(from))
+		last := first
+		if err == nil && isRange {
+			last, err = strconv.Atoi(strings.TrimSpace(to))
+		}
+		if err != nil || first < 1 || last > count || first > last {
+			return nil, fmt.Errorf("%q is not a source number or range between 1 and %d", part, count)
+		}
+		for i := first; i <= last; i++ {
+			seen[i-1] = true
+		}
+	}
+
+	var chosen []int
+	for i := 0; i < count; i++ {
+		if seen[i] {
+			chosen = append(chosen, i)
+		}
+	}
+	return chosen, nil
+}
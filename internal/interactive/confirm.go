@@ -3,35 +3,63 @@ package interactive
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"regexp"
+	"sort"
 	"strings"
 
+	"github.com/Jassem-HCP/nompose/internal/image"
 	"github.com/Jassem-HCP/nompose/internal/types"
 )
 
 // Confirmer handles interactive user confirmation and editing
 type Confirmer struct {
-	scanner *bufio.Scanner
+	scanner  *bufio.Scanner
+	options  types.GenerateOptions
+	built    map[string]types.EnhancedServiceConfig // Image and build chosen for each build context
+	rewriter *image.Rewriter
+	out      io.Writer // Prompts and summaries, stdout unless set by ReportTo
 }
 
+// stdin is shared by every confirmer, so input buffered by one isn't lost to the next
+var stdin = bufio.NewScanner(os.Stdin)
+
 // NewConfirmer creates a new interactive confirmer
-func NewConfirmer() *Confirmer {
+func NewConfirmer(options types.GenerateOptions) *Confirmer {
 	return &Confirmer{
-		scanner: bufio.NewScanner(os.Stdin),
+		scanner: stdin,
+		options: options,
+		built:   make(map[string]types.EnhancedServiceConfig),
+		out:     os.Stdout,
 	}
 }
 
+// ReportTo sends prompts and summaries to w instead of stdout
+func (c *Confirmer) ReportTo(w io.Writer) {
+	c.out = w
+}
+
+// RewriteImages applies image rewrite rules to the images services run,
+// before they are confirmed
+func (c *Confirmer) RewriteImages(rewriter *image.Rewriter) {
+	c.rewriter = rewriter
+}
+
+// traefikHostRule extracts the host from a Host(`example.com`) router rule
+var traefikHostRule = regexp.MustCompile("Host\\(`([^`]+)`\\)")
+
 // ConfirmServices interactively confirms and allows editing of service configurations
 func (c *Confirmer) ConfirmServices(services []types.EnhancedServiceConfig) ([]types.EnhancedServiceConfig, error) {
-	fmt.Println("\n🔧 Let's review and confirm the detected configurations...")
-	fmt.Println("   You can press ENTER to keep detected values, or type new values to override them.")
-	fmt.Println()
+	fmt.Fprintln(c.out, "\n🔧 Let's review and confirm the detected configurations...")
+	fmt.Fprintln(c.out, "   You can press ENTER to keep detected values, or type new values to override them.")
+	fmt.Fprintln(c.out)
 
 	var confirmedServices []types.EnhancedServiceConfig
 
 	for i, service := range services {
-		fmt.Printf("📦 Service %d/%d: %s\n", i+1, len(services), service.Name)
-		fmt.Println(strings.Repeat("─", 50))
+		fmt.Fprintf(c.out, "📦 Service %d/%d: %s\n", i+1, len(services), service.Name)
+		fmt.Fprintln(c.out, strings.Repeat("─", 50))
 
 		confirmed, err := c.confirmService(service)
 		if err != nil {
@@ -39,7 +67,7 @@ func (c *Confirmer) ConfirmServices(services []types.EnhancedServiceConfig) ([]t
 		}
 
 		confirmedServices = append(confirmedServices, confirmed)
-		fmt.Println()
+		fmt.Fprintln(c.out)
 	}
 
 	return confirmedServices, nil
@@ -66,17 +94,27 @@ func (c *Confirmer) confirmService(service types.EnhancedServiceConfig) (types.E
 		return confirmed, err
 	}
 
+	// Ask where the ingress should expose the service
+	if err := c.confirmIngress(&confirmed); err != nil {
+		return confirmed, err
+	}
+
 	// Show environment variables
 	if len(confirmed.Environment) > 0 {
-		fmt.Printf("   Environment variables: %d detected ✅\n", len(confirmed.Environment))
-		for key, value := range confirmed.Environment {
-			fmt.Printf("     %s=%s\n", key, value)
+		fmt.Fprintf(c.out, "   Environment variables: %d detected ✅\n", len(confirmed.Environment))
+		keys := make([]string, 0, len(confirmed.Environment))
+		for key := range confirmed.Environment {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(c.out, "     %s=%s\n", key, confirmed.Environment[key])
 		}
 	}
 
 	// Show dependencies
 	if len(confirmed.Dependencies) > 0 {
-		fmt.Printf("   Dependencies: %v ✅\n", confirmed.Dependencies)
+		fmt.Fprintf(c.out, "   Dependencies: %v ✅\n", confirmed.Dependencies)
 	}
 
 	// Show additional docker-compose settings
@@ -92,79 +130,161 @@ func (c *Confirmer) resolveImage(service *types.EnhancedServiceConfig) error {
 		buildPath := strings.TrimPrefix(service.ResolvedImage, "{{BUILD_REQUIRED:")
 		buildPath = strings.TrimSuffix(buildPath, "}}")
 
-		fmt.Printf("   🔨 Build configuration detected\n")
-		fmt.Printf("   Build context: %s\n", buildPath)
-		fmt.Println()
-		fmt.Println("   How would you like to handle the Docker image?")
-		fmt.Println("   1. I have the image ready (enter image name/tag)")
-		fmt.Println("   2. Build it locally now (auto docker build)")
-		fmt.Println("   3. I'll build and push later (enter final image name)")
-		fmt.Println()
-
-		choice, err := c.promptForInput("Choice [1-3]", "1", true)
+		// Services sharing a build context (e.g. Procfile processes) share its image
+		if built, ok := c.built[buildPath]; ok {
+			fmt.Fprintf(c.out, "   🔨 Same build context as an earlier service: %s\n", built.ResolvedImage)
+			service.ResolvedImage = built.ResolvedImage
+			service.Build = built.Build
+			return nil
+		}
+		defer func() { c.built[buildPath] = *service }()
+
+		fmt.Fprintf(c.out, "   🔨 Build configuration detected\n")
+		fmt.Fprintf(c.out, "   Build context: %s\n", buildPath)
+		fmt.Fprintln(c.out)
+		fmt.Fprintln(c.out, "   How would you like to handle the Docker image?")
+		fmt.Fprintln(c.out, "   1. I have the image ready (enter image name/tag)")
+		fmt.Fprintln(c.out, "   2. Build and push it with the generated build.sh / docker-bake.hcl")
+		fmt.Fprintln(c.out, "   3. I'll build and push it myself (enter final image name)")
+		fmt.Fprintln(c.out)
+
+		choice, err := c.promptForInput("Choice [1-3]", "2", true)
 		if err != nil {
 			return err
 		}
 
 		switch choice {
 		case "1":
-			imageName, err := c.promptForInput("Enter image name (e.g., my-app:latest)", "", true)
+			imageName, err := c.promptForImage("Enter image name (e.g., my-app:1.2.0)", "")
 			if err != nil {
 				return err
 			}
 			service.ResolvedImage = imageName
+			service.Build = nil
 
-		case "2":
-			imageName, err := c.promptForInput("Enter image name to build (e.g., my-app:latest)", fmt.Sprintf("%s:latest", service.Name), true)
+		case "3":
+			imageName, err := c.promptForImage("Enter final image name (e.g., registry.com/my-app:1.2.0)", c.registryImageName(service.Name))
 			if err != nil {
 				return err
 			}
-			
-			fmt.Printf("   🔨 Building image: %s\n", imageName)
-			fmt.Printf("   Command: docker build -t %s %s\n", imageName, buildPath)
-			fmt.Printf("   ⚠️  Note: Image will be available locally only\n")
-			fmt.Printf("   💡 For production, push to registry after building\n")
-			
+
+			fmt.Fprintf(c.out, "   📝 You'll need to build and push:\n")
+			fmt.Fprintf(c.out, "   docker build -t %s %s\n", imageName, buildPath)
+			fmt.Fprintf(c.out, "   docker push %s\n", imageName)
+
 			service.ResolvedImage = imageName
+			service.Build = nil
 
-		case "3":
-			imageName, err := c.promptForInput("Enter final image name (e.g., registry.com/my-app:latest)", "", true)
+		default:
+			imageName, err := c.promptForImage("Enter image name to build", c.defaultImageName(service.Name))
 			if err != nil {
 				return err
 			}
-			
-			fmt.Printf("   📝 You'll need to build and push:\n")
-			fmt.Printf("   docker build -t %s %s\n", imageName, buildPath)
-			fmt.Printf("   docker push %s\n", imageName)
-			
-			service.ResolvedImage = imageName
+			if service.Build == nil {
+				service.Build = &types.BuildConfig{Context: buildPath}
+			}
+			if c.options.Registry == "" {
+				fmt.Fprintf(c.out, "   ⚠️  No --registry set: the image is only available where it is built\n")
+			}
 
-		default:
-			service.ResolvedImage = fmt.Sprintf("%s:latest", service.Name)
+			service.ResolvedImage = imageName
 		}
 	} else {
 		// Regular image confirmation
-		newImage, err := c.confirmString("Image", service.ResolvedImage, true)
-		if err != nil {
+		current := service.ResolvedImage
+		if _, err := image.ParseReference(current); err != nil {
+			fmt.Fprintf(c.out, "   ⚠️  %v\n", err)
+			current = ""
+		} else if rewritten, err := c.rewriteImage(current); err != nil {
 			return err
+		} else {
+			current = rewritten
 		}
-		if newImage != "" {
-			service.ResolvedImage = newImage
+		newImage, err := c.promptForImage("Image", current)
+		if err != nil {
+			return err
 		}
+		service.ResolvedImage = newImage
 	}
 	return nil
 }
 
+// rewriteImage applies the rewrite rules to an image, showing the original
+// and rewritten image side by side when a rule changed it
+func (c *Confirmer) rewriteImage(original string) (string, error) {
+	rewritten, notes, err := c.rewriter.Rewrite(original)
+	if err != nil {
+		return "", err
+	}
+	if rewritten != original {
+		width := max(len(original), len("original"))
+		fmt.Fprintf(c.out, "   🔁 Image rewritten:\n")
+		fmt.Fprintf(c.out, "      %-*s   %s\n", width, "original", "rewritten")
+		fmt.Fprintf(c.out, "      %-*s → %s\n", width, original, rewritten)
+	}
+	for _, note := range notes {
+		fmt.Fprintf(c.out, "      - %s\n", note)
+	}
+	return rewritten, nil
+}
+
+// promptForImage asks for an image until it gets a valid reference; keeping
+// the current value returns it
+func (c *Confirmer) promptForImage(fieldName, currentValue string) (string, error) {
+	for {
+		input, err := c.promptForInput(fieldName, currentValue, true)
+		if err != nil {
+			return "", err
+		}
+		if input == "" {
+			return currentValue, nil
+		}
+		if _, err := image.ParseReference(input); err != nil {
+			fmt.Fprintf(c.out, "   ❌ %v\n", err)
+			continue
+		}
+		return input, nil
+	}
+}
+
+// defaultImageName names an image built for a service, under the configured registry
+func (c *Confirmer) defaultImageName(serviceName string) string {
+	if name := c.registryImageName(serviceName); name != "" {
+		return name
+	}
+	return fmt.Sprintf("%s:%s", serviceName, c.imageTag())
+}
+
+// registryImageName returns <registry>/<service>:<tag>, or "" without a registry
+func (c *Confirmer) registryImageName(serviceName string) string {
+	if c.options.Registry == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s:%s", strings.TrimSuffix(c.options.Registry, "/"), serviceName, c.imageTag())
+}
+
+// imageTag is the tag of images nompose builds
+func (c *Confirmer) imageTag() string {
+	if c.options.ImageTag == "" {
+		return "latest"
+	}
+	return c.options.ImageTag
+}
+
 // confirmPorts handles port confirmation
 func (c *Confirmer) confirmPorts(service *types.EnhancedServiceConfig) error {
 	if len(service.ResolvedPorts) == 0 {
-		fmt.Printf("   Ports: none detected\n")
+		fmt.Fprintf(c.out, "   Ports: none detected\n")
 		return nil
 	}
 
-	fmt.Printf("   Ports detected:\n")
+	fmt.Fprintf(c.out, "   Ports detected:\n")
 	for i, port := range service.ResolvedPorts {
-		fmt.Printf("     %d. %d:%d (%s)\n", i+1, port.Host, port.Container, port.Protocol)
+		if port.Host == 0 {
+			fmt.Fprintf(c.out, "     %d. dynamic:%d (%s)\n", i+1, port.Container, port.Protocol)
+			continue
+		}
+		fmt.Fprintf(c.out, "     %d. %d:%d (%s)\n", i+1, port.Host, port.Container, port.Protocol)
 	}
 
 	keepPorts, err := c.promptForInput("Keep these port mappings? (Y/n)", "Y", false)
@@ -174,31 +294,107 @@ func (c *Confirmer) confirmPorts(service *types.EnhancedServiceConfig) error {
 
 	if strings.ToLower(keepPorts) == "n" || strings.ToLower(keepPorts) == "no" {
 		// Allow editing ports (simplified for now)
-		fmt.Printf("   Port editing not implemented yet - keeping detected ports\n")
+		fmt.Fprintf(c.out, "   Port editing not implemented yet - keeping detected ports\n")
 	}
 
 	return nil
 }
 
+// confirmIngress asks for the public hostname and path of services with published ports
+func (c *Confirmer) confirmIngress(service *types.EnhancedServiceConfig) error {
+	if !c.options.WithIngress || len(service.ResolvedPorts) == 0 {
+		return nil
+	}
+
+	route := types.IngressRoute{Host: traefikHost(service.OriginalService.Labels), Path: "/"}
+	if service.Ingress != nil {
+		route = *service.Ingress
+	}
+
+	fmt.Fprintf(c.out, "   🌐 Ingress (%s)\n", c.options.IngressMode)
+
+	host, err := c.promptForInput("Ingress hostname", route.Host, false)
+	if err != nil {
+		return err
+	}
+	if host != "" {
+		route.Host = host
+	}
+
+	path, err := c.promptForInput("Ingress path", route.Path, false)
+	if err != nil {
+		return err
+	}
+	if path != "" {
+		route.Path = path
+	}
+
+	if route.Host == "" && (route.Path == "" || route.Path == "/") {
+		fmt.Fprintf(c.out, "   Not exposed through the ingress\n")
+		service.Ingress = nil
+		return nil
+	}
+
+	if !strings.HasPrefix(route.Path, "/") {
+		route.Path = "/" + route.Path
+	}
+	service.Ingress = &route
+	return nil
+}
+
+// traefikHost returns the hostname in a traefik router rule label, if any
+func traefikHost(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if strings.HasPrefix(key, "traefik.http.routers.") && strings.HasSuffix(key, ".rule") {
+			if match := traefikHostRule.FindStringSubmatch(labels[key]); match != nil {
+				return match[1]
+			}
+		}
+	}
+	return ""
+}
+
 // showAdditionalSettings displays other docker-compose settings
 func (c *Confirmer) showAdditionalSettings(service types.DockerComposeService) {
 	if len(service.Volumes) > 0 {
-		fmt.Printf("   Volumes: %d detected ✅\n", len(service.Volumes))
+		fmt.Fprintf(c.out, "   Volumes: %d detected ✅\n", len(service.Volumes))
 		for _, volume := range service.Volumes {
-			fmt.Printf("     %s\n", volume)
+			fmt.Fprintf(c.out, "     %s\n", volume)
 		}
 	}
 
+	if service.Entrypoint != nil {
+		fmt.Fprintf(c.out, "   Entrypoint: %v ✅\n", service.Entrypoint)
+	}
+
+	if service.Command != nil {
+		fmt.Fprintf(c.out, "   Command: %v ✅\n", service.Command)
+	}
+
 	if service.WorkingDir != "" {
-		fmt.Printf("   Working directory: %s ✅\n", service.WorkingDir)
+		fmt.Fprintf(c.out, "   Working directory: %s ✅\n", service.WorkingDir)
 	}
 
 	if service.User != "" {
-		fmt.Printf("   User: %s ✅\n", service.User)
+		fmt.Fprintf(c.out, "   User: %s ✅\n", service.User)
 	}
 
 	if service.Restart != "" {
-		fmt.Printf("   Restart policy: %s ✅\n", service.Restart)
+		fmt.Fprintf(c.out, "   Restart policy: %s ✅\n", service.Restart)
+	}
+
+	if service.Logging != nil && service.Logging.Driver != "" {
+		fmt.Fprintf(c.out, "   Logging driver: %s ✅\n", service.Logging.Driver)
+	}
+
+	if service.Privileged || len(service.CapAdd) > 0 {
+		fmt.Fprintf(c.out, "   Elevated privileges: privileged=%t cap_add=%v ⚠️\n", service.Privileged, service.CapAdd)
 	}
 }
 
@@ -209,13 +405,13 @@ func (c *Confirmer) confirmString(fieldName, currentValue string, required bool)
 
 func (c *Confirmer) promptForInput(fieldName, currentValue string, required bool) (string, error) {
 	if currentValue != "" {
-		fmt.Printf("   %s: %s\n", fieldName, currentValue)
-		fmt.Printf("   Keep this value? (Y/n): ")
+		fmt.Fprintf(c.out, "   %s: %s\n", fieldName, currentValue)
+		fmt.Fprintf(c.out, "   Keep this value? (Y/n): ")
 	} else {
 		if required {
-			fmt.Printf("   Please enter %s: ", strings.ToLower(fieldName))
+			fmt.Fprintf(c.out, "   Please enter %s: ", strings.ToLower(fieldName))
 		} else {
-			fmt.Printf("   Enter %s (optional): ", strings.ToLower(fieldName))
+			fmt.Fprintf(c.out, "   Enter %s (optional): ", strings.ToLower(fieldName))
 		}
 	}
 
@@ -230,7 +426,7 @@ func (c *Confirmer) promptForInput(fieldName, currentValue string, required bool
 			return "", nil
 		}
 		if strings.ToLower(input) == "n" || strings.ToLower(input) == "no" {
-			fmt.Printf("   Enter new %s: ", strings.ToLower(fieldName))
+			fmt.Fprintf(c.out, "   Enter new %s: ", strings.ToLower(fieldName))
 			if !c.scanner.Scan() {
 				return "", fmt.Errorf("failed to read user input")
 			}
@@ -239,7 +435,7 @@ func (c *Confirmer) promptForInput(fieldName, currentValue string, required bool
 	}
 
 	if required && input == "" {
-		fmt.Printf("   ❌ %s is required. Please enter a value: ", fieldName)
+		fmt.Fprintf(c.out, "   ❌ %s is required. Please enter a value: ", fieldName)
 		return c.promptForInput(fieldName, "", required)
 	}
 
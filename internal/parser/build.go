@@ -0,0 +1,125 @@
+package parser
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+// composeSecret is a top-level compose secret, the source of build secrets
+type composeSecret struct {
+	File        string `yaml:"file"`
+	Environment string `yaml:"environment"`
+}
+
+// parseBuild reads the short (`build: ./dir`) and long forms of a compose
+// build section; the context is resolved against the compose file directory
+func parseBuild(build interface{}, baseDir string, secrets map[string]composeSecret) *types.BuildConfig {
+	config := &types.BuildConfig{Context: "."}
+
+	switch build := build.(type) {
+	case nil:
+		return nil
+	case string:
+		config.Context = build
+	case map[string]interface{}:
+		if context, ok := build["context"].(string); ok && context != "" {
+			config.Context = context
+		}
+		config.Dockerfile, _ = build["dockerfile"].(string)
+		config.Target, _ = build["target"].(string)
+		config.Args = stringMap(build["args"])
+		config.CacheFrom = stringList(build["cache_from"])
+		config.Platforms = stringList(build["platforms"])
+		config.Secrets = buildSecrets(build["secrets"], baseDir, secrets)
+	default:
+		return nil
+	}
+
+	config.Context = resolvePath(baseDir, config.Context)
+	return config
+}
+
+// buildSecrets resolves build secret references against the top-level secrets
+func buildSecrets(value interface{}, baseDir string, secrets map[string]composeSecret) []types.BuildSecret {
+	var result []types.BuildSecret
+	for _, item := range asList(value) {
+		id := ""
+		switch item := item.(type) {
+		case string:
+			id = item
+		case map[string]interface{}:
+			id, _ = item["source"].(string)
+		}
+		if id == "" {
+			continue
+		}
+
+		secret := types.BuildSecret{ID: id}
+		if source, ok := secrets[id]; ok {
+			secret.Environment = source.Environment
+			if source.File != "" {
+				secret.File = resolvePath(baseDir, source.File)
+			}
+		}
+		result = append(result, secret)
+	}
+	return result
+}
+
+// resolvePath makes a compose-relative path relative to where nompose runs;
+// URLs (git or https build contexts) are left alone
+func resolvePath(baseDir, path string) string {
+	if strings.Contains(path, "://") || strings.HasPrefix(path, "git@") || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(baseDir, path)
+}
+
+// stringMap reads a compose map or KEY=VALUE list, e.g. build args
+func stringMap(value interface{}) map[string]string {
+	result := make(map[string]string)
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if item != nil {
+				result[key] = fmt.Sprintf("%v", item)
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			if pair, ok := item.(string); ok {
+				if key, val, found := strings.Cut(pair, "="); found {
+					result[key] = val
+				}
+			}
+		}
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// stringList reads a list of strings, or a single string as a one-item list
+func stringList(value interface{}) []string {
+	var result []string
+	for _, item := range asList(value) {
+		if s, ok := item.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func asList(value interface{}) []interface{} {
+	switch value := value.(type) {
+	case []interface{}:
+		return value
+	case string:
+		return []interface{}{value}
+	}
+	return nil
+}
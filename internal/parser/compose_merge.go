@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// mergeComposeFiles merges override files over a compose document. Mappings
+// merge key by key, sequences are appended without repeating scalars, and
+// anything else in the override replaces the base value. Key order is kept,
+// so services stay in the order they were first declared.
+func mergeComposeFiles(base []byte, overrides []string) ([]byte, error) {
+	merged, err := composeDocument(base, "base compose file")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range overrides {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read compose override file: %w", err)
+		}
+		override, err := composeDocument(data, path)
+		if err != nil {
+			return nil, err
+		}
+		mergeNodes(merged, override)
+	}
+
+	data, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge compose files: %w", err)
+	}
+	return data, nil
+}
+
+// composeDocument returns the top-level mapping of a compose file
+func composeDocument(data []byte, name string) (*yaml.Node, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	if len(document.Content) == 0 {
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}, nil
+	}
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("failed to parse %s: top level is not a mapping", name)
+	}
+	return root, nil
+}
+
+// mergeNodes merges override into base in place
+func mergeNodes(base, override *yaml.Node) {
+	for i := 0; i+1 < len(override.Content); i += 2 {
+		key, value := override.Content[i], override.Content[i+1]
+
+		existing := mappingValue(base, key.Value)
+		switch {
+		case existing == nil:
+			base.Content = append(base.Content, key, value)
+		case existing.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode:
+			mergeNodes(existing, value)
+		case existing.Kind == yaml.SequenceNode && value.Kind == yaml.SequenceNode:
+			for _, item := range value.Content {
+				if item.Kind != yaml.ScalarNode || !containsScalar(existing, item.Value) {
+					existing.Content = append(existing.Content, item)
+				}
+			}
+		default:
+			*existing = *value
+		}
+	}
+}
+
+// mappingValue returns the value node for key in a mapping node
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func containsScalar(sequence *yaml.Node, value string) bool {
+	for _, item := range sequence.Content {
+		if item.Kind == yaml.ScalarNode && item.Value == value {
+			return true
+		}
+	}
+	return false
+}
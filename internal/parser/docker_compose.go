@@ -1,11 +1,15 @@
 package parser
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/Jassem-HCP/nompose/internal/shellwords"
 	"github.com/Jassem-HCP/nompose/internal/types"
 	"gopkg.in/yaml.v3"
 )
@@ -16,6 +20,8 @@ type DockerComposeFile struct {
 	Services map[string]types.DockerComposeService `yaml:"services"`
 	Networks map[string]interface{}                `yaml:"networks,omitempty"`
 	Volumes  map[string]interface{}                `yaml:"volumes,omitempty"`
+	Secrets  map[string]composeSecret              `yaml:"secrets,omitempty"`
+	XNomad   *types.NomadExtension                 `yaml:"x-nomad,omitempty"`
 }
 
 // DockerComposeParser handles parsing docker-compose files
@@ -28,11 +34,22 @@ func NewDockerComposeParser() *DockerComposeParser {
 
 // Parse reads and parses a docker-compose file
 func (p *DockerComposeParser) Parse(filePath string) ([]types.EnhancedServiceConfig, error) {
+	return p.ParseFiles(filePath)
+}
+
+// ParseFiles parses a compose file with override files merged over it in
+// order, the way `docker compose -f base -f override` does
+func (p *DockerComposeParser) ParseFiles(filePath string, overrides ...string) ([]types.EnhancedServiceConfig, error) {
 	// Read the file
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read docker-compose file: %w", err)
 	}
+	if len(overrides) > 0 {
+		if data, err = mergeComposeFiles(data, overrides); err != nil {
+			return nil, err
+		}
+	}
 
 	// Parse YAML
 	var compose DockerComposeFile
@@ -40,16 +57,27 @@ func (p *DockerComposeParser) Parse(filePath string) ([]types.EnhancedServiceCon
 		return nil, fmt.Errorf("failed to parse docker-compose YAML: %w", err)
 	}
 
+	// Hash of the source, recorded in job meta
+	sourceHash := fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+
 	// Convert to enhanced format
 	var services []types.EnhancedServiceConfig
-	for name, service := range compose.Services {
+	for _, name := range p.serviceOrder(data, compose.Services) {
+		service := compose.Services[name]
+		service.XNomad = service.XNomad.WithDefaults(compose.XNomad)
+		service.Command = unescapeCommand(service.Command)
+		service.Entrypoint = unescapeCommand(service.Entrypoint)
+		build := parseBuild(service.Build, filepath.Dir(filePath), compose.Secrets)
 		enhanced := types.EnhancedServiceConfig{
 			Name:            name,
 			OriginalService: service,
-			ResolvedImage:   p.getInitialImage(service),
+			ResolvedImage:   p.getInitialImage(service, build),
+			Build:           build,
 			ResolvedPorts:   p.parsePorts(service.Ports),
 			Environment:     p.parseEnvironment(service.Environment),
 			Dependencies:    p.parseDependencies(service.DependsOn),
+			SourceFile:      filePath,
+			SourceHash:      sourceHash,
 		}
 		services = append(services, enhanced)
 	}
@@ -57,23 +85,49 @@ func (p *DockerComposeParser) Parse(filePath string) ([]types.EnhancedServiceCon
 	return services, nil
 }
 
+// serviceOrder lists service names in the order they appear in the file
+func (p *DockerComposeParser) serviceOrder(data []byte, services map[string]types.DockerComposeService) []string {
+	var names []string
+	seen := make(map[string]bool)
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err == nil && len(document.Content) > 0 {
+		root := document.Content[0]
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			if root.Content[i].Value != "services" {
+				continue
+			}
+			section := root.Content[i+1]
+			for j := 0; j+1 < len(section.Content); j += 2 {
+				name := section.Content[j].Value
+				if _, ok := services[name]; ok && !seen[name] {
+					names = append(names, name)
+					seen[name] = true
+				}
+			}
+		}
+	}
+
+	// Anything the node walk missed (e.g. merge keys) goes last, sorted
+	var rest []string
+	for name := range services {
+		if !seen[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+
+	return append(names, rest...)
+}
+
 // getInitialImage determines the initial image (may be placeholder for build)
-func (p *DockerComposeParser) getInitialImage(service types.DockerComposeService) string {
+func (p *DockerComposeParser) getInitialImage(service types.DockerComposeService, build *types.BuildConfig) string {
 	if service.Image != "" {
 		return service.Image
 	}
 
-	if service.Build != nil {
-		buildPath := "."
-		switch build := service.Build.(type) {
-		case string:
-			buildPath = build
-		case map[string]interface{}:
-			if context, ok := build["context"].(string); ok {
-				buildPath = context
-			}
-		}
-		return fmt.Sprintf("{{BUILD_REQUIRED:%s}}", buildPath)
+	if build != nil {
+		return fmt.Sprintf("{{BUILD_REQUIRED:%s}}", build.Context)
 	}
 
 	return "{{NO_IMAGE_SPECIFIED}}"
@@ -159,14 +213,14 @@ func (p *DockerComposeParser) parseEnvironment(env interface{}) map[string]strin
 	switch environment := env.(type) {
 	case map[string]interface{}:
 		for key, value := range environment {
-			result[key] = fmt.Sprintf("%v", value)
+			result[key] = unescapeDollars(fmt.Sprintf("%v", value))
 		}
 	case []interface{}:
 		for _, item := range environment {
 			if envStr, ok := item.(string); ok {
 				if strings.Contains(envStr, "=") {
 					parts := strings.SplitN(envStr, "=", 2)
-					result[parts[0]] = parts[1]
+					result[parts[0]] = unescapeDollars(parts[1])
 				}
 			}
 		}
@@ -175,6 +229,46 @@ func (p *DockerComposeParser) parseEnvironment(env interface{}) map[string]strin
 	return result
 }
 
+// unescapeDollars turns compose's $$ escape back into a literal $
+func unescapeDollars(value string) string {
+	return strings.ReplaceAll(value, "$$", "$")
+}
+
+// unescapeCommand unescapes $$ in a command or entrypoint. A string that
+// doesn't otherwise need a shell is split into words first, so the $ stays
+// literal instead of being expanded by the shell the command would run in.
+func unescapeCommand(command interface{}) interface{} {
+	switch command := command.(type) {
+	case string:
+		if !strings.Contains(command, "$$") {
+			return command
+		}
+		if needsShell, _ := shellwords.RequiresShell(command); needsShell {
+			return unescapeDollars(command)
+		}
+		words, err := shellwords.Split(command)
+		if err != nil {
+			return unescapeDollars(command)
+		}
+		list := make([]interface{}, len(words))
+		for i, word := range words {
+			list[i] = unescapeDollars(word)
+		}
+		return list
+	case []interface{}:
+		list := make([]interface{}, len(command))
+		for i, word := range command {
+			if word, ok := word.(string); ok {
+				list[i] = unescapeDollars(word)
+				continue
+			}
+			list[i] = word
+		}
+		return list
+	}
+	return command
+}
+
 // parseDependencies extracts service dependencies
 func (p *DockerComposeParser) parseDependencies(deps interface{}) []string {
 	var result []string
@@ -190,6 +284,7 @@ func (p *DockerComposeParser) parseDependencies(deps interface{}) []string {
 		for serviceName := range depsVal {
 			result = append(result, serviceName)
 		}
+		sort.Strings(result)
 	}
 
 	return result
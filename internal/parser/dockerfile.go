@@ -0,0 +1,604 @@
+package parser
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/shellwords"
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+// DockerfileParser turns a Dockerfile into a service description
+type DockerfileParser struct{}
+
+// NewDockerfileParser creates a new Dockerfile parser
+func NewDockerfileParser() *DockerfileParser {
+	return &DockerfileParser{}
+}
+
+// dockerfileInstruction is one instruction after continuations and heredocs are folded
+type dockerfileInstruction struct {
+	Command string // Upper-cased keyword
+	Args    string
+	Line    int
+}
+
+// dockerfileStage is the image configuration built up by one FROM stage
+type dockerfileStage struct {
+	Name        string
+	Base        string
+	Platform    string
+	Args        map[string]string
+	Env         map[string]string
+	Labels      map[string]string
+	Ports       []types.PortMapping
+	Volumes     []string
+	WorkDir     string
+	User        string
+	Cmd         interface{}
+	CmdFromBase bool // CMD came from the stage built on, so ENTRYPOINT resets it
+	Entrypoint  interface{}
+	HealthCheck *types.HealthCheckConfig
+}
+
+// Matches the `# escape=` parser directive
+var escapeDirective = regexp.MustCompile(`(?i)^#\s*escape\s*=\s*(\S)\s*$`)
+
+// Matches a `<<EOF`, `<<-EOF`, `<<"EOF"` or `<<'EOF'` heredoc marker
+var heredocMarker = regexp.MustCompile(`^<<(-?)(?:"([A-Za-z_][A-Za-z0-9_]*)"|'([A-Za-z_][A-Za-z0-9_]*)'|([A-Za-z_][A-Za-z0-9_]*))`)
+
+// Parse reads a Dockerfile and describes the image its final stage builds
+func (p *DockerfileParser) Parse(filePath string) ([]types.EnhancedServiceConfig, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Dockerfile: %w", err)
+	}
+
+	instructions, err := p.instructions(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Dockerfile: %w", err)
+	}
+
+	stage, err := p.finalStage(instructions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Dockerfile: %w", err)
+	}
+
+	env := make(map[string]string, len(stage.Env))
+	composeEnv := make(map[string]interface{}, len(stage.Env))
+	for key, value := range stage.Env {
+		env[key] = value
+		composeEnv[key] = value
+	}
+
+	contextDir := filepath.Dir(filePath)
+	service := types.DockerComposeService{
+		Build:       contextDir,
+		Environment: composeEnv,
+		Volumes:     stage.Volumes,
+		Command:     stage.Cmd,
+		Entrypoint:  stage.Entrypoint,
+		WorkingDir:  stage.WorkDir,
+		User:        stage.User,
+		Labels:      types.ListOrDict(stage.Labels),
+		HealthCheck: stage.HealthCheck,
+	}
+	for _, port := range stage.Ports {
+		service.Expose = append(service.Expose, fmt.Sprintf("%d/%s", port.Container, port.Protocol))
+	}
+
+	// api.Dockerfile names its service; other names go by directory
+	name := serviceNameFromDir(contextDir)
+	if base := filepath.Base(filePath); strings.HasSuffix(strings.ToLower(base), ".dockerfile") {
+		name = sanitizeServiceName(base[:len(base)-len(".dockerfile")])
+	}
+
+	build := &types.BuildConfig{Context: contextDir}
+	if base := filepath.Base(filePath); base != "Dockerfile" {
+		build.Dockerfile = base
+	}
+
+	return []types.EnhancedServiceConfig{{
+		Name:            name,
+		OriginalService: service,
+		ResolvedImage:   fmt.Sprintf("{{BUILD_REQUIRED:%s}}", contextDir),
+		Build:           build,
+		ResolvedPorts:   stage.Ports,
+		Environment:     env,
+		SourceFile:      filePath,
+		SourceHash:      fmt.Sprintf("sha256:%x", sha256.Sum256(data)),
+	}}, nil
+}
+
+// instructions splits a Dockerfile into instructions, honouring the escape
+// directive, comments, line continuations and heredocs
+func (p *DockerfileParser) instructions(content string) ([]dockerfileInstruction, error) {
+	escape := byte('\\')
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var instructions []dockerfileInstruction
+	var current strings.Builder
+	start, lineNumber := 0, 0
+	directives := true
+
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+
+		if directives {
+			if match := escapeDirective.FindStringSubmatch(trimmed); match != nil {
+				escape = match[1][0]
+				continue
+			}
+			if !strings.HasPrefix(trimmed, "#") || trimmed == "" {
+				directives = false
+			}
+		}
+
+		// Comments and blank lines, including those inside a continuation
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if current.Len() == 0 {
+			start = lineNumber
+		}
+
+		if len(trimmed) > 0 && trimmed[len(trimmed)-1] == escape {
+			current.WriteString(strings.TrimSuffix(trimmed, string(escape)))
+			current.WriteString(" ")
+			continue
+		}
+		current.WriteString(trimmed)
+
+		command, args, _ := strings.Cut(current.String(), " ")
+		instruction := dockerfileInstruction{Command: strings.ToUpper(command), Args: strings.TrimSpace(args), Line: start}
+		current.Reset()
+
+		// Heredoc bodies belong to the instruction and are never instructions themselves
+		var markers []heredoc
+		switch instruction.Command {
+		case "RUN", "COPY", "ADD":
+			markers = heredocMarkers(instruction.Args, escape)
+		}
+		for _, marker := range markers {
+			stripTabs, terminator := marker.StripTabs, marker.Terminator
+			closed := false
+			for scanner.Scan() {
+				lineNumber++
+				body := strings.TrimRight(scanner.Text(), "\r")
+				if stripTabs {
+					body = strings.TrimLeft(body, "\t")
+				}
+				if body == terminator {
+					closed = true
+					break
+				}
+			}
+			if !closed {
+				return nil, fmt.Errorf("line %d: heredoc %s is never closed", instruction.Line, terminator)
+			}
+		}
+
+		instructions = append(instructions, instruction)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if current.Len() > 0 {
+		return nil, fmt.Errorf("line %d: line continuation at end of file", start)
+	}
+	return instructions, nil
+}
+
+// heredoc is a heredoc opened by an instruction
+type heredoc struct {
+	Terminator string
+	StripTabs  bool
+}
+
+// heredocMarkers finds the heredocs an instruction opens, skipping `<<<`
+// here-strings and `<<` inside quotes
+func heredocMarkers(args string, escape byte) []heredoc {
+	var markers []heredoc
+	var quote byte
+	for i := 0; i < len(args); i++ {
+		ch := args[i]
+		switch {
+		case ch == escape && quote != '\'':
+			i++
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case strings.HasPrefix(args[i:], "<<<"):
+			i += 2
+		case strings.HasPrefix(args[i:], "<<"):
+			match := heredocMarker.FindStringSubmatch(args[i:])
+			if match == nil {
+				i++
+				continue
+			}
+			markers = append(markers, heredoc{Terminator: match[2] + match[3] + match[4], StripTabs: match[1] == "-"})
+			i += len(match[0]) - 1
+		}
+	}
+	return markers
+}
+
+// finalStage replays the instructions and returns the last FROM stage,
+// including whatever it inherits from earlier stages it is built on
+func (p *DockerfileParser) finalStage(instructions []dockerfileInstruction) (*dockerfileStage, error) {
+	globalArgs := make(map[string]string)
+	stages := make(map[string]*dockerfileStage)
+	var stage *dockerfileStage
+
+	for _, instruction := range instructions {
+		if stage == nil && instruction.Command != "FROM" && instruction.Command != "ARG" {
+			return nil, fmt.Errorf("line %d: %s before FROM", instruction.Line, instruction.Command)
+		}
+
+		var err error
+		switch instruction.Command {
+		case "FROM":
+			stage, err = p.from(instruction, globalArgs, stages)
+		case "ARG":
+			if stage == nil {
+				err = p.arg(instruction, globalArgs, globalArgs)
+			} else {
+				err = p.arg(instruction, stage.Args, globalArgs)
+			}
+		case "ENV":
+			err = p.env(instruction, stage)
+		case "LABEL":
+			err = p.label(instruction, stage)
+		case "EXPOSE":
+			err = p.expose(instruction, stage)
+		case "WORKDIR":
+			dir := expandVariables(instruction.Args, stage.vars())
+			if !path.IsAbs(dir) {
+				dir = path.Join(valueOrRoot(stage.WorkDir), dir)
+			}
+			stage.WorkDir = dir
+		case "USER":
+			stage.User = expandVariables(instruction.Args, stage.vars())
+		case "VOLUME":
+			err = p.volume(instruction, stage)
+		case "CMD":
+			stage.Cmd = commandForm(instruction.Args)
+			stage.CmdFromBase = false
+		case "ENTRYPOINT":
+			stage.Entrypoint = commandForm(instruction.Args)
+			// Docker resets CMD inherited from the base when ENTRYPOINT is set
+			if stage.CmdFromBase {
+				stage.Cmd = nil
+			}
+		case "HEALTHCHECK":
+			stage.HealthCheck, err = p.healthCheck(instruction)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", instruction.Line, err)
+		}
+	}
+
+	if stage == nil {
+		return nil, fmt.Errorf("no FROM instruction")
+	}
+	return stage, nil
+}
+
+// from starts a stage, copying the configuration of an earlier stage it builds on
+func (p *DockerfileParser) from(instruction dockerfileInstruction, globalArgs map[string]string, stages map[string]*dockerfileStage) (*dockerfileStage, error) {
+	words := strings.Fields(instruction.Args)
+	stage := &dockerfileStage{Args: make(map[string]string), Env: make(map[string]string), Labels: make(map[string]string)}
+
+	for len(words) > 0 && strings.HasPrefix(words[0], "--") {
+		if value, ok := strings.CutPrefix(words[0], "--platform="); ok {
+			stage.Platform = expandVariables(value, globalArgs)
+		}
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("FROM without an image")
+	}
+
+	stage.Base = expandVariables(words[0], globalArgs)
+	if len(words) >= 3 && strings.EqualFold(words[1], "as") {
+		stage.Name = strings.ToLower(words[2])
+	}
+
+	if parent, ok := stages[strings.ToLower(stage.Base)]; ok {
+		inherited := parent.clone()
+		inherited.Name, inherited.Base, inherited.Platform = stage.Name, parent.Base, stage.Platform
+		inherited.Args = make(map[string]string)
+		inherited.CmdFromBase = inherited.Cmd != nil
+		stage = inherited
+	}
+
+	if stage.Name != "" {
+		stages[stage.Name] = stage
+	}
+	return stage, nil
+}
+
+// arg records ARG defaults; an ARG without one inherits the global value
+func (p *DockerfileParser) arg(instruction dockerfileInstruction, args, globalArgs map[string]string) error {
+	words, err := shellwords.Split(instruction.Args)
+	if err != nil {
+		return err
+	}
+	for _, word := range words {
+		name, value, hasDefault := strings.Cut(word, "=")
+		if !hasDefault {
+			value = globalArgs[name]
+		}
+		args[name] = expandVariables(value, args)
+	}
+	return nil
+}
+
+// env handles both `ENV KEY=value ...` and the legacy `ENV KEY value`
+func (p *DockerfileParser) env(instruction dockerfileInstruction, stage *dockerfileStage) error {
+	pairs, err := keyValuePairs(instruction.Args)
+	if err != nil {
+		return err
+	}
+	for _, pair := range pairs {
+		value := expandVariables(pair[1], stage.vars())
+		stage.Env[pair[0]] = value
+	}
+	return nil
+}
+
+// label handles `LABEL key=value ...`
+func (p *DockerfileParser) label(instruction dockerfileInstruction, stage *dockerfileStage) error {
+	pairs, err := keyValuePairs(instruction.Args)
+	if err != nil {
+		return err
+	}
+	for _, pair := range pairs {
+		stage.Labels[pair[0]] = expandVariables(pair[1], stage.vars())
+	}
+	return nil
+}
+
+// expose handles `EXPOSE 80 443/tcp 53/udp 8000-8002`
+func (p *DockerfileParser) expose(instruction dockerfileInstruction, stage *dockerfileStage) error {
+	for _, word := range strings.Fields(expandVariables(instruction.Args, stage.vars())) {
+		ports, protocol, _ := strings.Cut(word, "/")
+		if protocol == "" {
+			protocol = "tcp"
+		}
+
+		first, last, isRange := strings.Cut(ports, "-")
+		from, err := strconv.Atoi(first)
+		if err != nil {
+			return fmt.Errorf("invalid EXPOSE port %q", word)
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(last); err != nil || to < from {
+				return fmt.Errorf("invalid EXPOSE port range %q", word)
+			}
+		}
+
+		// EXPOSE documents a container port without publishing it, so Nomad
+		// allocates the host port
+		for port := from; port <= to; port++ {
+			if !stage.exposes(port, protocol) {
+				stage.Ports = append(stage.Ports, types.PortMapping{Container: port, Protocol: protocol})
+			}
+		}
+	}
+	return nil
+}
+
+// volume handles both `VOLUME ["/data"]` and `VOLUME /data /logs`
+func (p *DockerfileParser) volume(instruction dockerfileInstruction, stage *dockerfileStage) error {
+	var volumes []string
+	if err := json.Unmarshal([]byte(instruction.Args), &volumes); err != nil {
+		volumes = strings.Fields(instruction.Args)
+	}
+	for _, volume := range volumes {
+		stage.Volumes = append(stage.Volumes, expandVariables(volume, stage.vars()))
+	}
+	return nil
+}
+
+// healthCheck handles `HEALTHCHECK [options] CMD ...` and `HEALTHCHECK NONE`
+func (p *DockerfileParser) healthCheck(instruction dockerfileInstruction) (*types.HealthCheckConfig, error) {
+	args := instruction.Args
+	check := &types.HealthCheckConfig{}
+
+	for strings.HasPrefix(args, "--") {
+		option, rest, _ := strings.Cut(args, " ")
+		args = strings.TrimSpace(rest)
+
+		name, value, _ := strings.Cut(strings.TrimPrefix(option, "--"), "=")
+		switch name {
+		case "interval":
+			check.Interval = value
+		case "timeout":
+			check.Timeout = value
+		case "start-period":
+			check.StartPeriod = value
+		case "retries":
+			retries, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid HEALTHCHECK --retries %q", value)
+			}
+			check.Retries = retries
+		}
+	}
+
+	keyword, command, _ := strings.Cut(args, " ")
+	switch strings.ToUpper(keyword) {
+	case "NONE":
+		check.Disable = true
+		return check, nil
+	case "CMD":
+	default:
+		return nil, fmt.Errorf("HEALTHCHECK must be NONE or CMD, got %q", keyword)
+	}
+
+	// Same test forms as compose: ["CMD", ...] for exec form, ["CMD-SHELL", "..."]
+	// for shell form, which keeps curl and wget probes recognisable as HTTP checks
+	command = strings.TrimSpace(command)
+	if exec, ok := execForm(command); ok {
+		check.Test = append([]interface{}{"CMD"}, exec...)
+	} else {
+		check.Test = []interface{}{"CMD-SHELL", command}
+	}
+	return check, nil
+}
+
+// commandForm returns the exec form as a list, or the shell form wrapped in
+// /bin/sh -c the way Docker runs it
+func commandForm(args string) interface{} {
+	if exec, ok := execForm(args); ok {
+		return exec
+	}
+	return []interface{}{"/bin/sh", "-c", args}
+}
+
+// execForm parses the JSON exec form, e.g. ["node", "server.js"]
+func execForm(args string) ([]interface{}, bool) {
+	var exec []string
+	if err := json.Unmarshal([]byte(args), &exec); err != nil {
+		return nil, false
+	}
+	words := make([]interface{}, len(exec))
+	for i, word := range exec {
+		words[i] = word
+	}
+	return words, true
+}
+
+// keyValuePairs parses `a=1 b="two words"`, or the legacy single `KEY value` form
+func keyValuePairs(args string) ([][2]string, error) {
+	first, _, _ := strings.Cut(args, " ")
+	if !strings.Contains(first, "=") {
+		key, value, _ := strings.Cut(args, " ")
+		return [][2]string{{key, strings.TrimSpace(value)}}, nil
+	}
+
+	words, err := shellwords.Split(args)
+	if err != nil {
+		return nil, err
+	}
+	var pairs [][2]string
+	for _, word := range words {
+		key, value, found := strings.Cut(word, "=")
+		if !found {
+			return nil, fmt.Errorf("expected key=value, got %q", word)
+		}
+		pairs = append(pairs, [2]string{key, value})
+	}
+	return pairs, nil
+}
+
+// Matches $VAR, ${VAR}, ${VAR:-default} and ${VAR:+alternative}
+var variableReference = regexp.MustCompile(`\$(?:([A-Za-z_][A-Za-z0-9_]*)|\{([A-Za-z_][A-Za-z0-9_]*)(?::([-+])([^}]*))?\})`)
+
+// expandVariables substitutes build args and environment variables the way
+// Docker does for ENV, LABEL, EXPOSE, WORKDIR, USER, VOLUME and FROM
+func expandVariables(value string, vars map[string]string) string {
+	return variableReference.ReplaceAllStringFunc(value, func(reference string) string {
+		match := variableReference.FindStringSubmatch(reference)
+		name := match[1] + match[2]
+		current, set := vars[name]
+		switch match[3] {
+		case "-":
+			if !set || current == "" {
+				return match[4]
+			}
+		case "+":
+			if set && current != "" {
+				return match[4]
+			}
+			return ""
+		}
+		return current
+	})
+}
+
+// vars returns the variables visible to instructions in the stage
+func (s *dockerfileStage) vars() map[string]string {
+	vars := make(map[string]string, len(s.Args)+len(s.Env))
+	for key, value := range s.Args {
+		vars[key] = value
+	}
+	for key, value := range s.Env {
+		vars[key] = value
+	}
+	return vars
+}
+
+func (s *dockerfileStage) exposes(port int, protocol string) bool {
+	for _, existing := range s.Ports {
+		if existing.Container == port && existing.Protocol == protocol {
+			return true
+		}
+	}
+	return false
+}
+
+// clone copies a stage so a later stage can build on it
+func (s *dockerfileStage) clone() *dockerfileStage {
+	copied := *s
+	copied.Env = make(map[string]string, len(s.Env))
+	for key, value := range s.Env {
+		copied.Env[key] = value
+	}
+	copied.Labels = make(map[string]string, len(s.Labels))
+	for key, value := range s.Labels {
+		copied.Labels[key] = value
+	}
+	copied.Ports = append([]types.PortMapping{}, s.Ports...)
+	copied.Volumes = append([]string{}, s.Volumes...)
+	return &copied
+}
+
+// serviceNameFromDir names the service after the build context directory
+func serviceNameFromDir(dir string) string {
+	absolute, err := filepath.Abs(dir)
+	if err == nil {
+		dir = absolute
+	}
+	return sanitizeServiceName(filepath.Base(dir))
+}
+
+// sanitizeServiceName lowercases name and replaces characters Nomad job names can't hold
+func sanitizeServiceName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(name))
+	name = strings.Trim(name, "-")
+	if name == "" {
+		return "app"
+	}
+	return name
+}
+
+func valueOrRoot(dir string) string {
+	if dir == "" {
+		return "/"
+	}
+	return dir
+}
@@ -0,0 +1,804 @@
+package parser
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/types"
+	"gopkg.in/yaml.v3"
+)
+
+// KubernetesParser turns Kubernetes workloads into services: Deployments,
+// StatefulSets and CronJobs, with the Services, ConfigMaps and
+// PersistentVolumeClaims they use
+type KubernetesParser struct {
+	warnings []string
+}
+
+// NewKubernetesParser creates a new Kubernetes manifest parser
+func NewKubernetesParser() *KubernetesParser {
+	return &KubernetesParser{}
+}
+
+// Warnings lists what the last Parse couldn't convert
+func (p *KubernetesParser) Warnings() []string {
+	return p.warnings
+}
+
+// k8sHeader is what every Kubernetes object has
+type k8sHeader struct {
+	Kind     string      `yaml:"kind"`
+	Metadata k8sMetadata `yaml:"metadata"`
+	Items    []yaml.Node `yaml:"items"` // kind: List
+}
+
+type k8sMetadata struct {
+	Name   string            `yaml:"name"`
+	Labels map[string]string `yaml:"labels"`
+}
+
+type k8sWorkload struct {
+	Metadata k8sMetadata `yaml:"metadata"`
+	Spec     struct {
+		Replicas             *int             `yaml:"replicas"`
+		Template             k8sPodTemplate   `yaml:"template"`
+		VolumeClaimTemplates []k8sVolumeClaim `yaml:"volumeClaimTemplates"`
+	} `yaml:"spec"`
+}
+
+type k8sCronJob struct {
+	Metadata k8sMetadata `yaml:"metadata"`
+	Spec     struct {
+		Schedule          string `yaml:"schedule"`
+		TimeZone          string `yaml:"timeZone"`
+		ConcurrencyPolicy string `yaml:"concurrencyPolicy"`
+		JobTemplate       struct {
+			Spec struct {
+				Template k8sPodTemplate `yaml:"template"`
+			} `yaml:"spec"`
+		} `yaml:"jobTemplate"`
+	} `yaml:"spec"`
+}
+
+type k8sService struct {
+	Metadata k8sMetadata `yaml:"metadata"`
+	Spec     struct {
+		Selector map[string]string `yaml:"selector"`
+		Ports    []struct {
+			Port       int         `yaml:"port"`
+			TargetPort interface{} `yaml:"targetPort"`
+		} `yaml:"ports"`
+	} `yaml:"spec"`
+}
+
+type k8sConfigMap struct {
+	Metadata k8sMetadata       `yaml:"metadata"`
+	Data     map[string]string `yaml:"data"`
+}
+
+type k8sVolumeClaim struct {
+	Metadata k8sMetadata `yaml:"metadata"`
+	Spec     struct {
+		AccessModes []string `yaml:"accessModes"`
+	} `yaml:"spec"`
+}
+
+type k8sPodTemplate struct {
+	Metadata k8sMetadata `yaml:"metadata"`
+	Spec     k8sPodSpec  `yaml:"spec"`
+}
+
+type k8sPodSpec struct {
+	Containers      []k8sContainer `yaml:"containers"`
+	InitContainers  []k8sContainer `yaml:"initContainers"`
+	Volumes         []k8sVolume    `yaml:"volumes"`
+	RestartPolicy   string         `yaml:"restartPolicy"`
+	SecurityContext struct {
+		RunAsUser *int64 `yaml:"runAsUser"`
+	} `yaml:"securityContext"`
+}
+
+type k8sContainer struct {
+	Name          string   `yaml:"name"`
+	Image         string   `yaml:"image"`
+	Command       []string `yaml:"command"`
+	Args          []string `yaml:"args"`
+	WorkingDir    string   `yaml:"workingDir"`
+	RestartPolicy string   `yaml:"restartPolicy"` // Always makes an init container a sidecar
+	Env           []struct {
+		Name      string `yaml:"name"`
+		Value     string `yaml:"value"`
+		ValueFrom *struct {
+			ConfigMapKeyRef *k8sKeyRef `yaml:"configMapKeyRef"`
+			SecretKeyRef    *k8sKeyRef `yaml:"secretKeyRef"`
+			FieldRef        *struct {
+				FieldPath string `yaml:"fieldPath"`
+			} `yaml:"fieldRef"`
+		} `yaml:"valueFrom"`
+	} `yaml:"env"`
+	EnvFrom []struct {
+		Prefix       string     `yaml:"prefix"`
+		ConfigMapRef *k8sKeyRef `yaml:"configMapRef"`
+		SecretRef    *k8sKeyRef `yaml:"secretRef"`
+	} `yaml:"envFrom"`
+	Ports []struct {
+		Name          string `yaml:"name"`
+		ContainerPort int    `yaml:"containerPort"`
+		HostPort      int    `yaml:"hostPort"`
+		Protocol      string `yaml:"protocol"`
+	} `yaml:"ports"`
+	Resources struct {
+		Requests map[string]string `yaml:"requests"`
+		Limits   map[string]string `yaml:"limits"`
+	} `yaml:"resources"`
+	ReadinessProbe *k8sProbe `yaml:"readinessProbe"`
+	LivenessProbe  *k8sProbe `yaml:"livenessProbe"`
+	VolumeMounts   []struct {
+		Name      string `yaml:"name"`
+		MountPath string `yaml:"mountPath"`
+		ReadOnly  bool   `yaml:"readOnly"`
+	} `yaml:"volumeMounts"`
+	SecurityContext *struct {
+		RunAsUser    *int64 `yaml:"runAsUser"`
+		Privileged   bool   `yaml:"privileged"`
+		Capabilities struct {
+			Add  []string `yaml:"add"`
+			Drop []string `yaml:"drop"`
+		} `yaml:"capabilities"`
+	} `yaml:"securityContext"`
+}
+
+type k8sKeyRef struct {
+	Name string `yaml:"name"`
+	Key  string `yaml:"key"`
+}
+
+type k8sProbe struct {
+	HTTPGet *struct {
+		Path   string      `yaml:"path"`
+		Port   interface{} `yaml:"port"`
+		Scheme string      `yaml:"scheme"`
+	} `yaml:"httpGet"`
+	Exec *struct {
+		Command []string `yaml:"command"`
+	} `yaml:"exec"`
+	TCPSocket           interface{} `yaml:"tcpSocket"`
+	InitialDelaySeconds int         `yaml:"initialDelaySeconds"`
+	PeriodSeconds       int         `yaml:"periodSeconds"`
+	TimeoutSeconds      int         `yaml:"timeoutSeconds"`
+	FailureThreshold    int         `yaml:"failureThreshold"`
+}
+
+type k8sVolume struct {
+	Name                  string `yaml:"name"`
+	PersistentVolumeClaim *struct {
+		ClaimName string `yaml:"claimName"`
+		ReadOnly  bool   `yaml:"readOnly"`
+	} `yaml:"persistentVolumeClaim"`
+	HostPath *struct {
+		Path string `yaml:"path"`
+	} `yaml:"hostPath"`
+	EmptyDir  interface{} `yaml:"emptyDir"`
+	ConfigMap interface{} `yaml:"configMap"`
+	Secret    interface{} `yaml:"secret"`
+}
+
+// k8sManifest holds the objects workloads refer to
+type k8sManifest struct {
+	configMaps map[string]map[string]string
+	claims     map[string]k8sVolumeClaim
+	services   []k8sService
+}
+
+// k8sPod is a workload's pod template with what the workload adds to it
+type k8sPod struct {
+	Name     string
+	Kind     string
+	Template k8sPodTemplate
+	Replicas *int
+	Claims   []k8sVolumeClaim     // StatefulSet volumeClaimTemplates
+	Schedule *types.NomadPeriodic // CronJob schedule
+}
+
+// k8sMount is a pod volume as a compose volume source
+type k8sMount struct {
+	Source  string             // Claim name or host path
+	Volume  *types.NomadVolume // CSI volume, nil for host paths
+	Skipped string             // Volume type that isn't converted
+}
+
+// Environment values for the downward API fields Nomad has an equivalent of
+var k8sFieldEnv = map[string]string{
+	"metadata.name":      "${NOMAD_ALLOC_NAME}",
+	"metadata.namespace": "${NOMAD_NAMESPACE}",
+	"spec.nodeName":      "${node.unique.name}",
+	"status.podIP":       "${attr.unique.network.ip-address}",
+	"status.hostIP":      "${attr.unique.network.ip-address}",
+}
+
+// Nomad CSI access modes for PersistentVolumeClaim access modes
+var k8sAccessModes = map[string]string{
+	"ReadWriteOnce":    "single-node-writer",
+	"ReadWriteOncePod": "single-node-writer",
+	"ReadOnlyMany":     "multi-node-reader-only",
+	"ReadWriteMany":    "multi-node-multi-writer",
+}
+
+// Bytes per memory quantity suffix
+var k8sMemoryUnits = map[string]float64{
+	"Ki": 1 << 10, "Mi": 1 << 20, "Gi": 1 << 30, "Ti": 1 << 40,
+	"k": 1e3, "M": 1e6, "G": 1e9, "T": 1e12,
+}
+
+// Parse reads a Kubernetes manifest, YAML documents or a List, and returns
+// one service per Deployment, StatefulSet and CronJob. The first container of
+// a pod is the main task; other containers and init containers become
+// further tasks of the group.
+func (p *KubernetesParser) Parse(filePath string) ([]types.EnhancedServiceConfig, error) {
+	p.warnings = nil
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Kubernetes manifest: %w", err)
+	}
+	objects, err := k8sObjects(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Kubernetes YAML: %w", err)
+	}
+
+	manifest := k8sManifest{
+		configMaps: make(map[string]map[string]string),
+		claims:     make(map[string]k8sVolumeClaim),
+	}
+	var pods []k8sPod
+	for _, object := range objects {
+		var header k8sHeader
+		if err := object.Decode(&header); err != nil {
+			return nil, fmt.Errorf("failed to parse Kubernetes object: %w", err)
+		}
+
+		var decodeErr error
+		switch header.Kind {
+		case "Deployment", "StatefulSet":
+			var workload k8sWorkload
+			decodeErr = object.Decode(&workload)
+			pods = append(pods, k8sPod{
+				Name:     workload.Metadata.Name,
+				Kind:     header.Kind,
+				Template: workload.Spec.Template,
+				Replicas: workload.Spec.Replicas,
+				Claims:   workload.Spec.VolumeClaimTemplates,
+			})
+		case "CronJob":
+			var cronJob k8sCronJob
+			decodeErr = object.Decode(&cronJob)
+			pods = append(pods, k8sPod{
+				Name:     cronJob.Metadata.Name,
+				Kind:     header.Kind,
+				Template: cronJob.Spec.JobTemplate.Spec.Template,
+				Schedule: &types.NomadPeriodic{
+					Cron:            cronJob.Spec.Schedule,
+					ProhibitOverlap: cronJob.Spec.ConcurrencyPolicy == "Forbid",
+					TimeZone:        cronJob.Spec.TimeZone,
+				},
+			})
+		case "Service":
+			var service k8sService
+			decodeErr = object.Decode(&service)
+			manifest.services = append(manifest.services, service)
+		case "ConfigMap":
+			var configMap k8sConfigMap
+			decodeErr = object.Decode(&configMap)
+			manifest.configMaps[configMap.Metadata.Name] = configMap.Data
+		case "PersistentVolumeClaim":
+			var claim k8sVolumeClaim
+			decodeErr = object.Decode(&claim)
+			manifest.claims[claim.Metadata.Name] = claim
+		case "Secret":
+			// Secret values don't belong in job files; references to them are reported
+		default:
+			p.warn("%s %s isn't converted", header.Kind, header.Metadata.Name)
+		}
+		if decodeErr != nil {
+			return nil, fmt.Errorf("failed to parse %s %s: %w", header.Kind, header.Metadata.Name, decodeErr)
+		}
+	}
+
+	sourceHash := fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+	var services []types.EnhancedServiceConfig
+	names := make(map[string]bool)
+	published := make(map[string]bool)
+	for _, pod := range pods {
+		service, ok := p.podService(pod, manifest, published)
+		if !ok {
+			continue
+		}
+		if names[service.Name] {
+			service.Name += "-" + strings.ToLower(pod.Kind)
+		}
+		names[service.Name] = true
+		service.SourceFile = filePath
+		service.SourceHash = sourceHash
+		services = append(services, service)
+	}
+
+	for _, service := range manifest.services {
+		if !published[service.Metadata.Name] {
+			p.warn("Service %s selects no Deployment, StatefulSet or CronJob in the manifest", service.Metadata.Name)
+		}
+	}
+	if len(services) == 0 {
+		return nil, fmt.Errorf("failed to parse Kubernetes manifest: no Deployment, StatefulSet or CronJob with containers")
+	}
+	return services, nil
+}
+
+// warn records something the parser couldn't convert
+func (p *KubernetesParser) warn(format string, args ...interface{}) {
+	p.warnings = append(p.warnings, fmt.Sprintf(format, args...))
+}
+
+// k8sObjects splits a manifest into its objects, expanding kind: List
+func k8sObjects(data []byte) ([]*yaml.Node, error) {
+	var objects []*yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		document := &yaml.Node{}
+		err := decoder.Decode(document)
+		if errors.Is(err, io.EOF) {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(document.Content) == 0 {
+			continue
+		}
+
+		var header k8sHeader
+		if err := document.Decode(&header); err != nil {
+			return nil, err
+		}
+		if header.Kind != "List" {
+			objects = append(objects, document)
+			continue
+		}
+		for i := range header.Items {
+			objects = append(objects, &header.Items[i])
+		}
+	}
+}
+
+// podService converts a workload's pod; published records the Services
+// that select it
+func (p *KubernetesParser) podService(pod k8sPod, manifest k8sManifest, published map[string]bool) (types.EnhancedServiceConfig, bool) {
+	spec := pod.Template.Spec
+	if len(spec.Containers) == 0 {
+		p.warn("%s %s has no containers", pod.Kind, pod.Name)
+		return types.EnhancedServiceConfig{}, false
+	}
+
+	name := sanitizeServiceName(pod.Name)
+	ext := &types.NomadExtension{}
+	mounts := p.podMounts(pod, manifest)
+
+	main := spec.Containers[0]
+	service := p.containerService(name, main, spec, mounts, ext)
+	env := p.containerEnv(name, main, manifest)
+	service.Environment = composeEnvironment(env)
+	service.HealthCheck = p.probeHealthCheck(name, main)
+
+	if cpu, memory, memoryMax := containerResources(main); cpu > 0 || memory > 0 {
+		ext.Resources = &types.NomadResources{CPU: cpu, Memory: memory, MemoryMax: memoryMax}
+	}
+	if pod.Replicas != nil {
+		service.Deploy = &types.DeployConfig{Replicas: *pod.Replicas}
+	}
+	if pod.Schedule != nil {
+		ext.Type = "batch"
+		ext.Periodic = pod.Schedule
+		switch spec.RestartPolicy {
+		case "OnFailure":
+			service.Restart = "on-failure"
+		default:
+			service.Restart = "no"
+		}
+	} else {
+		service.Restart = "always"
+	}
+	service.XNomad = ext
+
+	ports := p.servicePorts(name, pod, main, manifest, published)
+
+	var tasks []types.Task
+	for _, container := range spec.InitContainers {
+		task := p.containerTask(name, container, spec, mounts, ext, manifest)
+		task.Lifecycle = "prestart"
+		task.Sidecar = container.RestartPolicy == "Always"
+		tasks = append(tasks, task)
+	}
+	for _, container := range spec.Containers[1:] {
+		if len(container.Ports) > 0 {
+			p.warn("%s: ports of container %s aren't published, only those of %s", name, container.Name, main.Name)
+		}
+		tasks = append(tasks, p.containerTask(name, container, spec, mounts, ext, manifest))
+	}
+
+	return types.EnhancedServiceConfig{
+		Name:            name,
+		OriginalService: service,
+		ResolvedImage:   main.Image,
+		ResolvedPorts:   ports,
+		Environment:     env,
+		Tasks:           tasks,
+	}, true
+}
+
+// containerService maps what a container runs to compose settings
+func (p *KubernetesParser) containerService(owner string, container k8sContainer, spec k8sPodSpec, mounts map[string]k8sMount, ext *types.NomadExtension) types.DockerComposeService {
+	service := types.DockerComposeService{
+		Image:      container.Image,
+		WorkingDir: container.WorkingDir,
+		Volumes:    p.containerVolumes(owner, container, mounts, ext),
+	}
+	// command replaces the image's ENTRYPOINT and args its CMD, as in compose
+	if len(container.Command) > 0 {
+		service.Entrypoint = interfaceList(container.Command)
+	}
+	if len(container.Args) > 0 {
+		service.Command = interfaceList(container.Args)
+	}
+
+	user := spec.SecurityContext.RunAsUser
+	if security := container.SecurityContext; security != nil {
+		if security.RunAsUser != nil {
+			user = security.RunAsUser
+		}
+		service.Privileged = security.Privileged
+		service.CapAdd = security.Capabilities.Add
+		service.CapDrop = security.Capabilities.Drop
+	}
+	if user != nil {
+		service.User = strconv.FormatInt(*user, 10)
+	}
+	return service
+}
+
+// containerTask converts a container running next to the main one
+func (p *KubernetesParser) containerTask(owner string, container k8sContainer, spec k8sPodSpec, mounts map[string]k8sMount, ext *types.NomadExtension, manifest k8sManifest) types.Task {
+	name := sanitizeServiceName(container.Name)
+	if name == "app" {
+		// The main task is named app
+		name = "app-" + strings.ToLower(owner)
+	}
+	cpu, memory, _ := containerResources(container)
+	return types.Task{
+		Name:        name,
+		Image:       container.Image,
+		Service:     p.containerService(owner, container, spec, mounts, ext),
+		Environment: p.containerEnv(owner, container, manifest),
+		CPU:         cpu,
+		Memory:      memory,
+	}
+}
+
+// containerEnv resolves a container's environment: literal values,
+// ConfigMap keys and downward API fields. Secrets are left out.
+func (p *KubernetesParser) containerEnv(owner string, container k8sContainer, manifest k8sManifest) map[string]string {
+	env := make(map[string]string)
+	for _, source := range container.EnvFrom {
+		switch {
+		case source.ConfigMapRef != nil:
+			data, ok := manifest.configMaps[source.ConfigMapRef.Name]
+			if !ok {
+				p.warn("%s: ConfigMap %s isn't in the manifest", owner, source.ConfigMapRef.Name)
+			}
+			for key, value := range data {
+				env[source.Prefix+key] = value
+			}
+		case source.SecretRef != nil:
+			p.warn("%s: Secret %s isn't copied into the job; provide it with a Nomad variable or Vault template", owner, source.SecretRef.Name)
+		}
+	}
+
+	for _, variable := range container.Env {
+		from := variable.ValueFrom
+		switch {
+		case from == nil:
+			env[variable.Name] = variable.Value
+		case from.ConfigMapKeyRef != nil:
+			value, ok := manifest.configMaps[from.ConfigMapKeyRef.Name][from.ConfigMapKeyRef.Key]
+			if !ok {
+				p.warn("%s: %s refers to %s in ConfigMap %s, which isn't in the manifest", owner, variable.Name, from.ConfigMapKeyRef.Key, from.ConfigMapKeyRef.Name)
+				continue
+			}
+			env[variable.Name] = value
+		case from.SecretKeyRef != nil:
+			p.warn("%s: %s comes from Secret %s, which isn't copied into the job; provide it with a Nomad variable or Vault template", owner, variable.Name, from.SecretKeyRef.Name)
+		case from.FieldRef != nil && k8sFieldEnv[from.FieldRef.FieldPath] != "":
+			env[variable.Name] = k8sFieldEnv[from.FieldRef.FieldPath]
+		default:
+			p.warn("%s: the source of %s isn't converted", owner, variable.Name)
+		}
+	}
+	return env
+}
+
+// servicePorts publishes the container's ports on the port of the Service
+// selecting the pod, or its hostPort; other ports are allocated dynamically
+func (p *KubernetesParser) servicePorts(owner string, pod k8sPod, container k8sContainer, manifest k8sManifest, published map[string]bool) []types.PortMapping {
+	var ports []types.PortMapping
+	for _, port := range container.Ports {
+		protocol := strings.ToLower(port.Protocol)
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		ports = append(ports, types.PortMapping{Host: port.HostPort, Container: port.ContainerPort, Protocol: protocol})
+	}
+
+	for _, service := range manifest.services {
+		if !selects(service.Spec.Selector, pod.Template.Metadata.Labels) {
+			continue
+		}
+		published[service.Metadata.Name] = true
+		for _, servicePort := range service.Spec.Ports {
+			target := servicePort.Port
+			if servicePort.TargetPort != nil {
+				target = containerPort(container, servicePort.TargetPort)
+			}
+			if target == 0 {
+				p.warn("%s: targetPort %v of Service %s matches no container port", owner, servicePort.TargetPort, service.Metadata.Name)
+				continue
+			}
+
+			found := false
+			for i := range ports {
+				if ports[i].Container == target {
+					ports[i].Host = servicePort.Port
+					found = true
+				}
+			}
+			if !found {
+				ports = append(ports, types.PortMapping{Host: servicePort.Port, Container: target, Protocol: "tcp"})
+			}
+		}
+	}
+	return ports
+}
+
+// podMounts resolves the pod's volumes and the StatefulSet's claim templates
+func (p *KubernetesParser) podMounts(pod k8sPod, manifest k8sManifest) map[string]k8sMount {
+	mounts := make(map[string]k8sMount)
+	for _, volume := range pod.Template.Spec.Volumes {
+		switch {
+		case volume.PersistentVolumeClaim != nil:
+			claim := volume.PersistentVolumeClaim.ClaimName
+			mounts[volume.Name] = k8sMount{Source: claim, Volume: &types.NomadVolume{
+				Type:           "csi",
+				Source:         claim,
+				ReadOnly:       volume.PersistentVolumeClaim.ReadOnly,
+				AccessMode:     accessMode(manifest.claims[claim].Spec.AccessModes),
+				AttachmentMode: "file-system",
+			}}
+		case volume.HostPath != nil:
+			mounts[volume.Name] = k8sMount{Source: volume.HostPath.Path}
+		case volume.EmptyDir != nil:
+			mounts[volume.Name] = k8sMount{Skipped: "emptyDir"}
+		case volume.ConfigMap != nil:
+			mounts[volume.Name] = k8sMount{Skipped: "configMap"}
+		case volume.Secret != nil:
+			mounts[volume.Name] = k8sMount{Skipped: "secret"}
+		default:
+			mounts[volume.Name] = k8sMount{Skipped: "this"}
+		}
+	}
+
+	// Each allocation claims its own volume, as each StatefulSet pod does
+	for _, claim := range pod.Claims {
+		mounts[claim.Metadata.Name] = k8sMount{Source: claim.Metadata.Name, Volume: &types.NomadVolume{
+			Type:           "csi",
+			Source:         claim.Metadata.Name,
+			AccessMode:     accessMode(claim.Spec.AccessModes),
+			AttachmentMode: "file-system",
+			PerAlloc:       true,
+		}}
+	}
+	return mounts
+}
+
+// containerVolumes turns volume mounts into compose volumes, declaring the
+// CSI volumes they use in x-nomad.volumes
+func (p *KubernetesParser) containerVolumes(owner string, container k8sContainer, mounts map[string]k8sMount, ext *types.NomadExtension) []string {
+	var volumes []string
+	for _, mount := range container.VolumeMounts {
+		volume, ok := mounts[mount.Name]
+		switch {
+		case !ok:
+			p.warn("%s: volume %s mounted by %s isn't declared by the pod", owner, mount.Name, container.Name)
+			continue
+		case volume.Skipped != "":
+			p.warn("%s: %s volume %s mounted at %s isn't converted", owner, volume.Skipped, mount.Name, mount.MountPath)
+			continue
+		}
+
+		spec := volume.Source + ":" + mount.MountPath
+		if mount.ReadOnly {
+			spec += ":ro"
+		}
+		volumes = append(volumes, spec)
+
+		if volume.Volume != nil {
+			if ext.Volumes == nil {
+				ext.Volumes = make(map[string]types.NomadVolume)
+			}
+			ext.Volumes[volume.Source] = *volume.Volume
+		}
+	}
+	return volumes
+}
+
+// probeHealthCheck turns the readiness probe, or else the liveness probe,
+// into a compose healthcheck; TCP probes are left to the default TCP check
+func (p *KubernetesParser) probeHealthCheck(owner string, container k8sContainer) *types.HealthCheckConfig {
+	probe := container.ReadinessProbe
+	if probe == nil {
+		probe = container.LivenessProbe
+	}
+	if probe == nil {
+		return nil
+	}
+
+	var test []interface{}
+	switch {
+	case probe.HTTPGet != nil:
+		port := containerPort(container, probe.HTTPGet.Port)
+		if port == 0 {
+			p.warn("%s: probe port %v matches no container port", owner, probe.HTTPGet.Port)
+			return nil
+		}
+		scheme := strings.ToLower(probe.HTTPGet.Scheme)
+		if scheme == "" {
+			scheme = "http"
+		}
+		path := probe.HTTPGet.Path
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		test = []interface{}{"CMD", "curl", "-f", fmt.Sprintf("%s://localhost:%d%s", scheme, port, path)}
+	case probe.Exec != nil && len(probe.Exec.Command) > 0:
+		test = append([]interface{}{"CMD"}, interfaceList(probe.Exec.Command)...)
+	default:
+		return nil
+	}
+
+	check := &types.HealthCheckConfig{Test: test, Retries: probe.FailureThreshold}
+	if probe.PeriodSeconds > 0 {
+		check.Interval = fmt.Sprintf("%ds", probe.PeriodSeconds)
+	}
+	if probe.TimeoutSeconds > 0 {
+		check.Timeout = fmt.Sprintf("%ds", probe.TimeoutSeconds)
+	}
+	if probe.InitialDelaySeconds > 0 {
+		check.StartPeriod = fmt.Sprintf("%ds", probe.InitialDelaySeconds)
+	}
+	return check
+}
+
+// containerResources converts requests, falling back to limits, to MHz and
+// MB; a memory limit above the request becomes memory_max
+func containerResources(container k8sContainer) (int, int, int) {
+	requests, limits := container.Resources.Requests, container.Resources.Limits
+
+	cpu := cpuMHz(requests["cpu"])
+	if cpu == 0 {
+		cpu = cpuMHz(limits["cpu"])
+	}
+
+	memory, memoryMax := memoryMB(requests["memory"]), memoryMB(limits["memory"])
+	if memory == 0 {
+		return cpu, memoryMax, 0
+	}
+	if memoryMax <= memory {
+		memoryMax = 0
+	}
+	return cpu, memory, memoryMax
+}
+
+// cpuMHz converts a CPU quantity, "250m" or "0.5", to MHz at 1000 MHz a core
+func cpuMHz(quantity string) int {
+	if quantity == "" {
+		return 0
+	}
+	scale := 1000.0
+	if strings.HasSuffix(quantity, "m") {
+		quantity, scale = strings.TrimSuffix(quantity, "m"), 1
+	}
+	value, err := strconv.ParseFloat(quantity, 64)
+	if err != nil {
+		return 0
+	}
+	return int(math.Ceil(value * scale))
+}
+
+// memoryMB converts a memory quantity, "512Mi" or "1G", to MiB
+func memoryMB(quantity string) int {
+	if quantity == "" {
+		return 0
+	}
+	bytes := 1.0
+	for suffix, unit := range k8sMemoryUnits {
+		if strings.HasSuffix(quantity, suffix) {
+			quantity, bytes = strings.TrimSuffix(quantity, suffix), unit
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(quantity, 64)
+	if err != nil {
+		return 0
+	}
+	return int(math.Ceil(value * bytes / (1 << 20)))
+}
+
+// containerPort resolves a port number or named container port
+func containerPort(container k8sContainer, port interface{}) int {
+	switch value := port.(type) {
+	case int:
+		return value
+	case string:
+		if number, err := strconv.Atoi(value); err == nil {
+			return number
+		}
+		for _, named := range container.Ports {
+			if named.Name == value {
+				return named.ContainerPort
+			}
+		}
+	}
+	return 0
+}
+
+// accessMode maps the first claim access mode to a CSI access mode
+func accessMode(modes []string) string {
+	if len(modes) > 0 && k8sAccessModes[modes[0]] != "" {
+		return k8sAccessModes[modes[0]]
+	}
+	return "single-node-writer"
+}
+
+// selects reports whether a Service selector matches the pod labels
+func selects(selector, labels map[string]string) bool {
+	if len(selector) == 0 {
+		return false
+	}
+	for key, value := range selector {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// composeEnvironment is env in the compose environment form
+func composeEnvironment(env map[string]string) map[string]interface{} {
+	environment := make(map[string]interface{}, len(env))
+	for key, value := range env {
+		environment[key] = value
+	}
+	return environment
+}
+
+// interfaceList is words in the form compose lists decode to
+func interfaceList(words []string) []interface{} {
+	list := make([]interface{}, len(words))
+	for i, word := range words {
+		list[i] = word
+	}
+	return list
+}
@@ -0,0 +1,677 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Jassem-HCP/nompose/internal/hcl"
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+// hclVariable matches ${var.name} in strings
+var hclVariable = regexp.MustCompile(`\$\{var\.([A-Za-z0-9_-]+)\}`)
+
+// Blocks read at each level of an HCL job; others are reported as unsupported
+var (
+	jobBlocks     = map[string]bool{"group": true}
+	groupBlocks   = map[string]bool{"network": true, "volume": true, "service": true, "task": true, "restart": true}
+	taskBlocks    = map[string]bool{"config": true, "env": true, "template": true, "lifecycle": true, "volume_mount": true, "resources": true, "service": true}
+	dockerOptions = map[string]bool{
+		"image": true, "ports": true, "command": true, "args": true, "entrypoint": true, "work_dir": true,
+		"volumes": true, "mount": true, "privileged": true, "cap_add": true, "cap_drop": true, "labels": true, "hostname": true,
+	}
+)
+
+// NomadJobParser reads Nomad jobs back, for nompose reverse
+type NomadJobParser struct {
+	variables map[string]string
+	job       *types.NomadJob
+}
+
+// NewNomadJobParser creates a new Nomad job parser
+func NewNomadJobParser() *NomadJobParser {
+	return &NomadJobParser{}
+}
+
+// Parse reads a Nomad job in HCL or API JSON. Settings compose can't
+// express are listed in the job's Unsupported notes.
+func (p *NomadJobParser) Parse(filePath string) (*types.NomadJob, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Nomad job: %w", err)
+	}
+
+	p.job = &types.NomadJob{SourceFile: filePath}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		err = p.parseJSON(data)
+	} else {
+		err = p.parseHCL(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p.job, nil
+}
+
+// unsupported records a setting compose can't express
+func (p *NomadJobParser) unsupported(format string, args ...interface{}) {
+	note := fmt.Sprintf(format, args...)
+	for _, existing := range p.job.Unsupported {
+		if existing == note {
+			return
+		}
+	}
+	p.job.Unsupported = append(p.job.Unsupported, note)
+}
+
+func (p *NomadJobParser) parseHCL(data []byte) error {
+	body, err := hcl.Parse(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse Nomad job HCL: %w", err)
+	}
+
+	// Variable defaults stand in for the values passed with -var
+	p.variables = make(map[string]string)
+	for _, variable := range body.BlocksOf("variable") {
+		if value, ok := variable.Body.Attribute("default"); ok {
+			if s, ok := scalarString(value); ok {
+				p.variables[variable.Label()] = s
+			}
+		}
+	}
+
+	jobBlock := body.BlockOf("job")
+	if jobBlock == nil {
+		return fmt.Errorf("failed to parse Nomad job HCL: no job block")
+	}
+	job := jobBlock.Body
+	p.job.Name = jobBlock.Label()
+	p.job.Type = valueOrDefault(p.str(job, "type"), "service")
+	p.unsupportedBlocks("job "+p.job.Name, job, jobBlocks)
+
+	for _, groupBlock := range job.BlocksOf("group") {
+		p.job.Groups = append(p.job.Groups, p.hclGroup(groupBlock))
+	}
+	return nil
+}
+
+func (p *NomadJobParser) hclGroup(block *hcl.Block) types.JobGroup {
+	body := block.Body
+	group := types.JobGroup{Name: block.Label(), Count: 1, Volumes: make(map[string]types.JobVolume)}
+	if _, ok := body.Attribute("count"); ok {
+		group.Count = body.Int("count")
+	}
+	where := "group " + group.Name
+	p.unsupportedBlocks(where, body, groupBlocks)
+
+	if network := body.BlockOf("network"); network != nil {
+		for _, port := range network.Body.BlocksOf("port") {
+			group.Ports = append(group.Ports, types.JobPort{Label: port.Label(), Static: port.Body.Int("static"), To: port.Body.Int("to")})
+		}
+		p.unsupportedBlocks(where+" network", network.Body, map[string]bool{"port": true})
+	}
+	for _, volume := range body.BlocksOf("volume") {
+		group.Volumes[volume.Label()] = types.JobVolume{
+			Type:     valueOrDefault(p.str(volume.Body, "type"), "host"),
+			Source:   valueOrDefault(p.str(volume.Body, "source"), volume.Label()),
+			ReadOnly: volume.Body.Bool("read_only"),
+		}
+	}
+	for _, service := range body.BlocksOf("service") {
+		group.Services = append(group.Services, p.hclService(where, service))
+	}
+	for _, task := range body.BlocksOf("task") {
+		group.Tasks = append(group.Tasks, p.hclTask(task))
+	}
+	return group
+}
+
+func (p *NomadJobParser) hclTask(block *hcl.Block) types.JobTask {
+	body := block.Body
+	task := types.JobTask{Name: block.Label(), Driver: p.str(body, "driver"), User: p.str(body, "user")}
+	where := "task " + task.Name
+	p.unsupportedBlocks(where, body, taskBlocks)
+
+	if config := body.BlockOf("config"); config != nil {
+		p.dockerConfig(where, &task, p.bodyMap(config.Body))
+	}
+
+	if env := body.BlockOf("env"); env != nil {
+		task.Env = make(map[string]string)
+		for _, attr := range env.Body.Attributes {
+			task.Env[attr.Name] = p.resolve(where+" env "+attr.Name, attr.Value)
+		}
+	}
+	// env = { ... } is the attribute form
+	if env := p.stringMap(where+" env", body, "env"); env != nil {
+		task.Env = env
+	}
+
+	for _, template := range body.BlocksOf("template") {
+		task.Templates = append(task.Templates, types.JobTemplate{
+			Data:        p.str(template.Body, "data"),
+			Source:      p.str(template.Body, "source"),
+			Destination: p.str(template.Body, "destination"),
+			Env:         template.Body.Bool("env"),
+		})
+	}
+	if lifecycle := body.BlockOf("lifecycle"); lifecycle != nil {
+		task.Lifecycle = p.str(lifecycle.Body, "hook")
+		task.Sidecar = lifecycle.Body.Bool("sidecar")
+	}
+	for _, mount := range body.BlocksOf("volume_mount") {
+		task.VolumeMounts = append(task.VolumeMounts, types.JobVolumeMount{
+			Volume:      p.str(mount.Body, "volume"),
+			Destination: p.str(mount.Body, "destination"),
+			ReadOnly:    mount.Body.Bool("read_only"),
+		})
+	}
+	if resources := body.BlockOf("resources"); resources != nil {
+		task.CPU = resources.Body.Int("cpu")
+		task.Memory = resources.Body.Int("memory")
+		task.MemoryMax = resources.Body.Int("memory_max")
+	}
+	for _, service := range body.BlocksOf("service") {
+		task.Services = append(task.Services, p.hclService(where, service))
+	}
+	return task
+}
+
+func (p *NomadJobParser) hclService(where string, block *hcl.Block) types.JobService {
+	body := block.Body
+	service := types.JobService{Name: p.str(body, "name"), Port: p.str(body, "port")}
+
+	for _, check := range body.BlocksOf("check") {
+		jobCheck := types.JobCheck{
+			Type:     p.str(check.Body, "type"),
+			Path:     p.str(check.Body, "path"),
+			Protocol: p.str(check.Body, "protocol"),
+			Port:     p.str(check.Body, "port"),
+			Command:  p.str(check.Body, "command"),
+			Args:     p.strs(check.Body, "args"),
+			Interval: p.str(check.Body, "interval"),
+			Timeout:  p.str(check.Body, "timeout"),
+		}
+		if restart := check.Body.BlockOf("check_restart"); restart != nil {
+			jobCheck.Retries = restart.Body.Int("limit")
+			jobCheck.Grace = p.str(restart.Body, "grace")
+		}
+		service.Checks = append(service.Checks, jobCheck)
+	}
+
+	if connect := body.BlockOf("connect"); connect != nil {
+		p.unsupported("%s: Consul Connect service mesh (services reach each other directly on the compose network)", where)
+		if sidecar := connect.Body.BlockOf("sidecar_service"); sidecar != nil {
+			if proxy := sidecar.Body.BlockOf("proxy"); proxy != nil {
+				for _, upstream := range proxy.Body.BlocksOf("upstreams") {
+					service.Upstreams = append(service.Upstreams, types.JobUpstream{
+						Service:   p.str(upstream.Body, "destination_name"),
+						LocalPort: upstream.Body.Int("local_bind_port"),
+					})
+				}
+			}
+		}
+	}
+	return service
+}
+
+// bodyMap turns a block body into the map the API JSON form of the block
+// decodes to, with nested blocks as lists
+func (p *NomadJobParser) bodyMap(body *hcl.Body) map[string]interface{} {
+	values := make(map[string]interface{})
+	for _, attr := range body.Attributes {
+		values[attr.Name] = attr.Value
+	}
+	for _, block := range body.Blocks {
+		list, _ := values[block.Type].([]interface{})
+		values[block.Type] = append(list, p.bodyMap(block.Body))
+	}
+	return values
+}
+
+// dockerConfig reads the docker driver options compose has equivalents of
+func (p *NomadJobParser) dockerConfig(where string, task *types.JobTask, config map[string]interface{}) {
+	task.Image = p.resolve(where+" image", config["image"])
+	task.Ports = p.resolveList(where+" ports", config["ports"])
+	task.Command = p.resolve(where+" command", config["command"])
+	task.Args = p.resolveList(where+" args", config["args"])
+	task.Entrypoint = p.resolveList(where+" entrypoint", config["entrypoint"])
+	task.WorkDir = p.resolve(where+" work_dir", config["work_dir"])
+	task.Hostname = p.resolve(where+" hostname", config["hostname"])
+	task.CapAdd = p.resolveList(where+" cap_add", config["cap_add"])
+	task.CapDrop = p.resolveList(where+" cap_drop", config["cap_drop"])
+	task.Privileged, _ = config["privileged"].(bool)
+	task.Mounts = p.resolveList(where+" volumes", config["volumes"])
+
+	if labels := flattenList(config["labels"]); len(labels) > 0 {
+		task.Labels = make(map[string]string)
+		for _, item := range labels {
+			object, _ := item.(map[string]interface{})
+			for key, value := range object {
+				task.Labels[key] = p.resolve(where+" label "+key, value)
+			}
+		}
+	}
+
+	for _, item := range flattenList(config["mount"]) {
+		mount, _ := item.(map[string]interface{})
+		mountType := strings.ToLower(p.resolve(where+" mount", firstOf(mount, "type", "Type")))
+		source := p.resolve(where+" mount", firstOf(mount, "source", "Source"))
+		target := p.resolve(where+" mount", firstOf(mount, "target", "Target"))
+		readOnly, _ := firstOf(mount, "readonly", "ReadOnly").(bool)
+		if mountType == "tmpfs" || source == "" || target == "" {
+			p.unsupported("%s: %s mount at %s", where, valueOrDefault(mountType, "unknown"), target)
+			continue
+		}
+		spec := source + ":" + target
+		if readOnly {
+			spec += ":ro"
+		}
+		task.Mounts = append(task.Mounts, spec)
+	}
+
+	var others []string
+	for key := range config {
+		if !dockerOptions[key] {
+			others = append(others, key)
+		}
+	}
+	sort.Strings(others)
+	for _, key := range others {
+		p.unsupported("%s: docker option %s", where, key)
+	}
+}
+
+// unsupportedBlocks reports the blocks of a body that aren't read
+func (p *NomadJobParser) unsupportedBlocks(where string, body *hcl.Body, read map[string]bool) {
+	for _, block := range body.Blocks {
+		if !read[block.Type] {
+			p.unsupported("%s: %s", where, block.Type)
+		}
+	}
+}
+
+// str returns a string attribute with variable defaults applied
+func (p *NomadJobParser) str(body *hcl.Body, name string) string {
+	value, _ := body.Attribute(name)
+	return p.resolve(name, value)
+}
+
+// strs returns a list attribute's items with variable defaults applied
+func (p *NomadJobParser) strs(body *hcl.Body, name string) []string {
+	value, _ := body.Attribute(name)
+	return p.resolveList(name, value)
+}
+
+// stringMap returns an object attribute with variable defaults applied
+func (p *NomadJobParser) stringMap(where string, body *hcl.Body, name string) map[string]string {
+	value, _ := body.Attribute(name)
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	values := make(map[string]string, len(object))
+	for key, item := range object {
+		values[key] = p.resolve(where+" "+key, item)
+	}
+	return values
+}
+
+// resolve turns a value into a string, replacing variables with their
+// defaults; expressions it can't evaluate are reported and left empty
+func (p *NomadJobParser) resolve(where string, value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return hclVariable.ReplaceAllStringFunc(v, func(reference string) string {
+			name := hclVariable.FindStringSubmatch(reference)[1]
+			if value, ok := p.variables[name]; ok {
+				return value
+			}
+			p.unsupported("%s: variable %s has no default", where, name)
+			return reference
+		})
+	case hcl.Expression:
+		if name := strings.TrimPrefix(v.Raw, "var."); name != v.Raw {
+			if value, ok := p.variables[name]; ok {
+				return value
+			}
+		}
+		p.unsupported("%s: expression %s", where, v.Raw)
+		return ""
+	}
+	if s, ok := scalarString(value); ok {
+		return s
+	}
+	p.unsupported("%s: value %v", where, value)
+	return ""
+}
+
+// resolveList resolves the items of a list value
+func (p *NomadJobParser) resolveList(where string, value interface{}) []string {
+	list, ok := value.([]interface{})
+	if !ok {
+		if s := p.resolve(where, value); s != "" {
+			return []string{s}
+		}
+		return nil
+	}
+	items := make([]string, 0, len(list))
+	for _, item := range list {
+		items = append(items, p.resolve(where, item))
+	}
+	return items
+}
+
+// scalarString formats strings, numbers and bools
+func scalarString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case int, bool:
+		return fmt.Sprintf("%v", v), true
+	case float64:
+		if v == float64(int64(v)) {
+			return fmt.Sprintf("%d", int64(v)), true
+		}
+		return fmt.Sprintf("%v", v), true
+	}
+	return "", false
+}
+
+// flattenList returns a list value, or a single value as a list
+func flattenList(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	}
+	return []interface{}{value}
+}
+
+// firstOf returns the first key present in a map
+func firstOf(values map[string]interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		if value, ok := values[key]; ok {
+			return value
+		}
+	}
+	return nil
+}
+
+// API JSON, as `nomad job run -output` and `nomad job inspect` print it
+type apiJob struct {
+	ID               string
+	Name             string
+	Type             string
+	TaskGroups       []apiGroup
+	Constraints      json.RawMessage
+	Affinities       json.RawMessage
+	Spreads          json.RawMessage
+	Periodic         *struct{ Enabled *bool }
+	ParameterizedJob json.RawMessage
+	Multiregion      json.RawMessage
+}
+
+type apiGroup struct {
+	Name     string
+	Count    *int
+	Networks []struct {
+		DynamicPorts  []apiPort
+		ReservedPorts []apiPort
+	}
+	Volumes map[string]struct {
+		Type     string
+		Source   string
+		ReadOnly bool
+	}
+	Services    []apiService
+	Tasks       []apiTask
+	Constraints json.RawMessage
+	Affinities  json.RawMessage
+	Spreads     json.RawMessage
+	Scaling     json.RawMessage
+}
+
+type apiPort struct {
+	Label string
+	Value int
+	To    int
+}
+
+type apiTask struct {
+	Name      string
+	Driver    string
+	User      string
+	Config    map[string]interface{}
+	Env       map[string]string
+	Templates []struct {
+		EmbeddedTmpl string
+		SourcePath   string
+		DestPath     string
+		Envvars      bool
+	}
+	Lifecycle *struct {
+		Hook    string
+		Sidecar bool
+	}
+	VolumeMounts []struct {
+		Volume      string
+		Destination string
+		ReadOnly    bool
+	}
+	Resources *struct {
+		CPU         *int
+		MemoryMB    *int
+		MemoryMaxMB *int
+	}
+	Services    []apiService
+	Artifacts   json.RawMessage
+	Vault       json.RawMessage
+	Constraints json.RawMessage
+}
+
+type apiService struct {
+	Name      string
+	PortLabel string
+	Checks    []struct {
+		Type         string
+		Path         string
+		Protocol     string
+		PortLabel    string
+		Command      string
+		Args         []string
+		Interval     int64
+		Timeout      int64
+		CheckRestart *struct {
+			Limit int
+			Grace int64
+		}
+	}
+	Connect *struct {
+		SidecarService *struct {
+			Proxy *struct {
+				Upstreams []struct {
+					DestinationName string
+					LocalBindPort   int
+				}
+			}
+		}
+	}
+}
+
+func (p *NomadJobParser) parseJSON(data []byte) error {
+	var wrapped struct{ Job *apiJob }
+	if err := json.Unmarshal(data, &wrapped); err != nil {
+		return fmt.Errorf("failed to parse Nomad job JSON: %w", err)
+	}
+	job := wrapped.Job
+	if job == nil {
+		job = &apiJob{}
+		if err := json.Unmarshal(data, job); err != nil {
+			return fmt.Errorf("failed to parse Nomad job JSON: %w", err)
+		}
+	}
+	if len(job.TaskGroups) == 0 {
+		return fmt.Errorf("failed to parse Nomad job JSON: no TaskGroups")
+	}
+
+	p.job.Name = valueOrDefault(job.Name, job.ID)
+	p.job.Type = valueOrDefault(job.Type, "service")
+	where := "job " + p.job.Name
+	p.unsupportedFields(where, map[string]json.RawMessage{
+		"constraint": job.Constraints, "affinity": job.Affinities, "spread": job.Spreads,
+		"parameterized": job.ParameterizedJob, "multiregion": job.Multiregion,
+	})
+	if job.Periodic != nil && (job.Periodic.Enabled == nil || *job.Periodic.Enabled) {
+		p.unsupported("%s: periodic", where)
+	}
+
+	for _, group := range job.TaskGroups {
+		p.job.Groups = append(p.job.Groups, p.jsonGroup(group))
+	}
+	return nil
+}
+
+func (p *NomadJobParser) jsonGroup(api apiGroup) types.JobGroup {
+	group := types.JobGroup{Name: api.Name, Count: 1, Volumes: make(map[string]types.JobVolume)}
+	if api.Count != nil {
+		group.Count = *api.Count
+	}
+	where := "group " + group.Name
+	p.unsupportedFields(where, map[string]json.RawMessage{
+		"constraint": api.Constraints, "affinity": api.Affinities, "spread": api.Spreads, "scaling": api.Scaling,
+	})
+
+	for _, network := range api.Networks {
+		for _, port := range network.ReservedPorts {
+			group.Ports = append(group.Ports, types.JobPort{Label: port.Label, Static: port.Value, To: port.To})
+		}
+		for _, port := range network.DynamicPorts {
+			group.Ports = append(group.Ports, types.JobPort{Label: port.Label, To: port.To})
+		}
+	}
+	for name, volume := range api.Volumes {
+		group.Volumes[name] = types.JobVolume{Type: valueOrDefault(volume.Type, "host"), Source: valueOrDefault(volume.Source, name), ReadOnly: volume.ReadOnly}
+	}
+	for _, service := range api.Services {
+		group.Services = append(group.Services, p.jsonService(where, service))
+	}
+	for _, task := range api.Tasks {
+		group.Tasks = append(group.Tasks, p.jsonTask(task))
+	}
+	return group
+}
+
+func (p *NomadJobParser) jsonTask(api apiTask) types.JobTask {
+	task := types.JobTask{Name: api.Name, Driver: api.Driver, User: api.User, Env: api.Env}
+	where := "task " + task.Name
+	p.unsupportedFields(where, map[string]json.RawMessage{
+		"artifact": api.Artifacts, "vault": api.Vault, "constraint": api.Constraints,
+	})
+	if api.Config != nil {
+		p.dockerConfig(where, &task, api.Config)
+	}
+
+	for _, template := range api.Templates {
+		task.Templates = append(task.Templates, types.JobTemplate{
+			Data:        template.EmbeddedTmpl,
+			Source:      template.SourcePath,
+			Destination: template.DestPath,
+			Env:         template.Envvars,
+		})
+	}
+	if api.Lifecycle != nil {
+		task.Lifecycle = api.Lifecycle.Hook
+		task.Sidecar = api.Lifecycle.Sidecar
+	}
+	for _, mount := range api.VolumeMounts {
+		task.VolumeMounts = append(task.VolumeMounts, types.JobVolumeMount{Volume: mount.Volume, Destination: mount.Destination, ReadOnly: mount.ReadOnly})
+	}
+	if resources := api.Resources; resources != nil {
+		task.CPU = intValue(resources.CPU)
+		task.Memory = intValue(resources.MemoryMB)
+		task.MemoryMax = intValue(resources.MemoryMaxMB)
+	}
+	for _, service := range api.Services {
+		task.Services = append(task.Services, p.jsonService(where, service))
+	}
+	return task
+}
+
+func (p *NomadJobParser) jsonService(where string, api apiService) types.JobService {
+	service := types.JobService{Name: api.Name, Port: api.PortLabel}
+	for _, check := range api.Checks {
+		jobCheck := types.JobCheck{
+			Type:     check.Type,
+			Path:     check.Path,
+			Protocol: check.Protocol,
+			Port:     check.PortLabel,
+			Command:  check.Command,
+			Args:     check.Args,
+			Interval: jsonDuration(check.Interval),
+			Timeout:  jsonDuration(check.Timeout),
+		}
+		if check.CheckRestart != nil {
+			jobCheck.Retries = check.CheckRestart.Limit
+			jobCheck.Grace = jsonDuration(check.CheckRestart.Grace)
+		}
+		service.Checks = append(service.Checks, jobCheck)
+	}
+
+	if api.Connect != nil {
+		p.unsupported("%s: Consul Connect service mesh (services reach each other directly on the compose network)", where)
+		if sidecar := api.Connect.SidecarService; sidecar != nil && sidecar.Proxy != nil {
+			for _, upstream := range sidecar.Proxy.Upstreams {
+				service.Upstreams = append(service.Upstreams, types.JobUpstream{Service: upstream.DestinationName, LocalPort: upstream.LocalBindPort})
+			}
+		}
+	}
+	return service
+}
+
+// unsupportedFields reports API fields that are set
+func (p *NomadJobParser) unsupportedFields(where string, fields map[string]json.RawMessage) {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch strings.TrimSpace(string(fields[name])) {
+		case "", "null", "[]", "{}":
+			continue
+		}
+		p.unsupported("%s: %s", where, name)
+	}
+}
+
+// jsonDuration formats API nanoseconds the way job files write durations
+func jsonDuration(nanoseconds int64) string {
+	if nanoseconds == 0 {
+		return ""
+	}
+	return time.Duration(nanoseconds).String()
+}
+
+func intValue(value *int) int {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
+
+func valueOrDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,717 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseFilesMergesOverrides(t *testing.T) {
+	dir := t.TempDir()
+	base := writeFile(t, dir, "compose.yaml", `services:
+  web:
+    image: nginx:1.25
+    ports: ["80:80"]
+    environment:
+      LOG_LEVEL: info
+  db:
+    image: postgres:16
+`)
+	override := writeFile(t, dir, "compose.override.yaml", `services:
+  web:
+    image: nginx:1.27
+    ports: ["80:80", "443:443"]
+    environment:
+      DEBUG: "1"
+  cache:
+    image: redis:7
+`)
+
+	services, err := NewDockerComposeParser().ParseFiles(base, override)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, service := range services {
+		names = append(names, service.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"web", "db", "cache"}) {
+		t.Errorf("unexpected service order %v", names)
+	}
+
+	web := services[0]
+	if web.ResolvedImage != "nginx:1.27" {
+		t.Errorf("override image not applied: %s", web.ResolvedImage)
+	}
+	if len(web.ResolvedPorts) != 2 {
+		t.Errorf("expected ports merged without duplicates, got %+v", web.ResolvedPorts)
+	}
+	if !reflect.DeepEqual(web.Environment, map[string]string{"LOG_LEVEL": "info", "DEBUG": "1"}) {
+		t.Errorf("unexpected environment %v", web.Environment)
+	}
+}
+
+func TestParseBuildLongForm(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "compose.yaml", `services:
+  api:
+    build:
+      context: ./api
+      dockerfile: Dockerfile.prod
+      target: runtime
+      args:
+        VERSION: "1.2"
+        UNSET:
+      cache_from: [registry.example.com/api:cache]
+      platforms: [linux/amd64, linux/arm64]
+      secrets: [npm, token]
+  web:
+    build: .
+secrets:
+  npm:
+    file: ./npmrc
+  token:
+    environment: API_TOKEN
+`)
+
+	services, err := NewDockerComposeParser().Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	builds := make(map[string]*types.BuildConfig)
+	images := make(map[string]string)
+	for _, service := range services {
+		builds[service.Name] = service.Build
+		images[service.Name] = service.ResolvedImage
+	}
+
+	want := &types.BuildConfig{
+		Context:    filepath.Join(dir, "api"),
+		Dockerfile: "Dockerfile.prod",
+		Target:     "runtime",
+		Args:       map[string]string{"VERSION": "1.2"},
+		CacheFrom:  []string{"registry.example.com/api:cache"},
+		Platforms:  []string{"linux/amd64", "linux/arm64"},
+		Secrets: []types.BuildSecret{
+			{ID: "npm", File: filepath.Join(dir, "npmrc")},
+			{ID: "token", Environment: "API_TOKEN"},
+		},
+	}
+	if !reflect.DeepEqual(builds["api"], want) {
+		t.Errorf("api build = %+v, want %+v", builds["api"], want)
+	}
+	if images["api"] != "{{BUILD_REQUIRED:"+filepath.Join(dir, "api")+"}}" {
+		t.Errorf("api image = %q", images["api"])
+	}
+	if got := builds["web"]; got == nil || got.Context != dir || got.Dockerfile != "" {
+		t.Errorf("web build = %+v, want context %s", got, dir)
+	}
+}
+
+func TestParseComposeDollarEscapes(t *testing.T) {
+	tests := []struct {
+		name           string
+		service        string
+		wantCommand    interface{}
+		wantEntrypoint interface{}
+		wantEnv        map[string]string
+	}{
+		{
+			name:        "list command",
+			service:     `command: ["sh", "-c", "echo $$HOSTNAME"]`,
+			wantCommand: []interface{}{"sh", "-c", "echo $HOSTNAME"},
+		},
+		{
+			name:        "string command is split so $ stays literal",
+			service:     `command: echo "$$HOSTNAME costs $$5"`,
+			wantCommand: []interface{}{"echo", "$HOSTNAME costs $5"},
+		},
+		{
+			name:        "string command that needs a shell",
+			service:     `command: echo $$HOME | tee out`,
+			wantCommand: "echo $HOME | tee out",
+		},
+		{
+			name:        "string command without escapes is kept",
+			service:     `command: serve $PORT`,
+			wantCommand: "serve $PORT",
+		},
+		{
+			name:           "entrypoint",
+			service:        `entrypoint: ["/bin/sh", "-c", "exec app --pid $$$$"]`,
+			wantEntrypoint: []interface{}{"/bin/sh", "-c", "exec app --pid $$"},
+		},
+		{
+			name:    "environment map",
+			service: "environment:\n      PRICE: \"$$5\"\n      PLAIN: ok",
+			wantEnv: map[string]string{"PRICE": "$5", "PLAIN": "ok"},
+		},
+		{
+			name:    "environment list",
+			service: `environment: ["PS1=$$ ", "HOME_REF=$${HOME}"]`,
+			wantEnv: map[string]string{"PS1": "$ ", "HOME_REF": "${HOME}"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := writeFile(t, t.TempDir(), "compose.yaml", "services:\n  app:\n    image: example/app:1\n    "+test.service+"\n")
+			services, err := NewDockerComposeParser().Parse(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			app := services[0]
+			if !reflect.DeepEqual(app.OriginalService.Command, test.wantCommand) {
+				t.Errorf("command = %#v, want %#v", app.OriginalService.Command, test.wantCommand)
+			}
+			if !reflect.DeepEqual(app.OriginalService.Entrypoint, test.wantEntrypoint) {
+				t.Errorf("entrypoint = %#v, want %#v", app.OriginalService.Entrypoint, test.wantEntrypoint)
+			}
+			if test.wantEnv == nil {
+				test.wantEnv = map[string]string{}
+			}
+			if !reflect.DeepEqual(app.Environment, test.wantEnv) {
+				t.Errorf("environment = %v, want %v", app.Environment, test.wantEnv)
+			}
+		})
+	}
+}
+
+func TestProcfileParser(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "shop")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := writeFile(t, dir, "Procfile", "# processes\nweb: bundle exec puma -p $PORT\nworker: bundle exec sidekiq\nrelease: rails db:migrate\n")
+
+	services, err := NewProcfileParser().Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 3 {
+		t.Fatalf("expected 3 services, got %d", len(services))
+	}
+
+	web, worker, release := services[0], services[1], services[2]
+	if web.Name != "shop-web" || len(web.ResolvedPorts) != 1 || web.Environment["PORT"] != "5000" {
+		t.Errorf("unexpected web service %+v", web)
+	}
+	if worker.Name != "shop-worker" || len(worker.ResolvedPorts) != 0 {
+		t.Errorf("unexpected worker service %+v", worker)
+	}
+	if release.OriginalService.XNomad == nil || release.OriginalService.XNomad.Type != "batch" {
+		t.Errorf("release process should be a batch job")
+	}
+	if !reflect.DeepEqual(worker.OriginalService.Command, []interface{}{"/bin/sh", "-c", "bundle exec sidekiq"}) {
+		t.Errorf("unexpected command %v", worker.OriginalService.Command)
+	}
+}
+
+func TestKubernetesParser(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "app.yaml", `apiVersion: v1
+kind: ConfigMap
+metadata: {name: web-config}
+data: {LOG_LEVEL: info}
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: web}
+spec:
+  replicas: 3
+  template:
+    metadata:
+      labels: {app: web}
+    spec:
+      initContainers:
+        - name: migrate
+          image: example/web:1.2
+          command: [./migrate]
+      containers:
+        - name: web
+          image: example/web:1.2
+          args: [serve]
+          ports: [{name: http, containerPort: 8080}]
+          envFrom: [{configMapRef: {name: web-config}}]
+          env:
+            - {name: POD, valueFrom: {fieldRef: {fieldPath: metadata.name}}}
+            - {name: TOKEN, valueFrom: {secretKeyRef: {name: api, key: token}}}
+          readinessProbe:
+            httpGet: {path: /healthz, port: http}
+            periodSeconds: 5
+          resources:
+            requests: {cpu: 250m, memory: 256Mi}
+            limits: {memory: 1Gi}
+          volumeMounts: [{name: uploads, mountPath: /uploads, readOnly: true}]
+        - name: metrics
+          image: prom/statsd-exporter
+      volumes:
+        - name: uploads
+          persistentVolumeClaim: {claimName: uploads}
+---
+apiVersion: v1
+kind: Service
+metadata: {name: web}
+spec:
+  selector: {app: web}
+  ports: [{port: 80, targetPort: http}]
+---
+apiVersion: v1
+kind: List
+items:
+  - apiVersion: batch/v1
+    kind: CronJob
+    metadata: {name: report}
+    spec:
+      schedule: "0 3 * * *"
+      concurrencyPolicy: Forbid
+      jobTemplate:
+        spec:
+          template:
+            spec:
+              restartPolicy: Never
+              containers: [{name: report, image: example/report}]
+`)
+
+	k8sParser := NewKubernetesParser()
+	services, err := k8sParser.Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 2 {
+		t.Fatalf("expected 2 services, got %d", len(services))
+	}
+
+	web, report := services[0], services[1]
+	if web.Name != "web" || web.ResolvedImage != "example/web:1.2" || web.OriginalService.Deploy.Replicas != 3 {
+		t.Errorf("unexpected web service %+v", web)
+	}
+	if want := []types.PortMapping{{Host: 80, Container: 8080, Protocol: "tcp"}}; !reflect.DeepEqual(web.ResolvedPorts, want) {
+		t.Errorf("ports = %+v, want %+v", web.ResolvedPorts, want)
+	}
+	if want := map[string]string{"LOG_LEVEL": "info", "POD": "${NOMAD_ALLOC_NAME}"}; !reflect.DeepEqual(web.Environment, want) {
+		t.Errorf("environment = %v, want %v", web.Environment, want)
+	}
+	if check := web.OriginalService.HealthCheck; check == nil ||
+		!reflect.DeepEqual(check.Test, []interface{}{"CMD", "curl", "-f", "http://localhost:8080/healthz"}) || check.Interval != "5s" {
+		t.Errorf("unexpected healthcheck %+v", check)
+	}
+
+	ext := web.OriginalService.XNomad
+	if want := (types.NomadResources{CPU: 250, Memory: 256, MemoryMax: 1024}); ext.Resources == nil || *ext.Resources != want {
+		t.Errorf("resources = %+v, want %+v", ext.Resources, want)
+	}
+	if !reflect.DeepEqual(web.OriginalService.Volumes, []string{"uploads:/uploads:ro"}) || ext.Volumes["uploads"].Type != "csi" {
+		t.Errorf("unexpected volumes %v, %+v", web.OriginalService.Volumes, ext.Volumes)
+	}
+
+	if len(web.Tasks) != 2 {
+		t.Fatalf("expected 2 more tasks, got %+v", web.Tasks)
+	}
+	if migrate := web.Tasks[0]; migrate.Name != "migrate" || migrate.Lifecycle != "prestart" ||
+		!reflect.DeepEqual(migrate.Service.Entrypoint, []interface{}{"./migrate"}) {
+		t.Errorf("unexpected init task %+v", migrate)
+	}
+	if metrics := web.Tasks[1]; metrics.Name != "metrics" || metrics.Lifecycle != "" {
+		t.Errorf("unexpected sidecar task %+v", metrics)
+	}
+
+	rext := report.OriginalService.XNomad
+	if rext.Type != "batch" || rext.Periodic == nil || rext.Periodic.Cron != "0 3 * * *" || !rext.Periodic.ProhibitOverlap {
+		t.Errorf("report should be a periodic batch job, got %+v", rext)
+	}
+	if report.OriginalService.Restart != "no" {
+		t.Errorf("restart = %q, want no", report.OriginalService.Restart)
+	}
+
+	if len(k8sParser.Warnings()) != 1 {
+		t.Errorf("expected a warning for the secret, got %v", k8sParser.Warnings())
+	}
+}
+
+func TestKubernetesContainerPorts(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "app.yaml", `apiVersion: apps/v1
+kind: Deployment
+metadata: {name: web}
+spec:
+  template:
+    metadata:
+      labels: {app: web}
+    spec:
+      containers:
+        - name: web
+          image: example/web:1.0
+          ports:
+            - {name: http, containerPort: 8080}
+            - {name: metrics, containerPort: 9090}
+            - {name: dns, containerPort: 53, hostPort: 5353, protocol: UDP}
+---
+apiVersion: v1
+kind: Service
+metadata: {name: web}
+spec:
+  selector: {app: web}
+  ports: [{port: 80, targetPort: http}]
+`)
+	services, err := NewKubernetesParser().Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []types.PortMapping{
+		{Host: 80, Container: 8080, Protocol: "tcp"},
+		{Host: 0, Container: 9090, Protocol: "tcp"},
+		{Host: 5353, Container: 53, Protocol: "udp"},
+	}
+	if !reflect.DeepEqual(services[0].ResolvedPorts, want) {
+		t.Errorf("ports = %+v, want %+v: only Service ports and hostPorts are static", services[0].ResolvedPorts, want)
+	}
+}
+
+func TestNomadJobParser(t *testing.T) {
+	dir := t.TempDir()
+	hclPath := writeFile(t, dir, "shop.nomad.hcl", `variable "image" {
+  default = "example/shop:1.0"
+}
+
+job "shop" {
+  type = "service"
+
+  group "app" {
+    count = 2
+    network {
+      port "http" { to = 8000 }
+    }
+    volume "data" {
+      type   = "host"
+      source = "shop-data"
+    }
+    service {
+      name = "shop"
+      port = "http"
+      check {
+        type     = "http"
+        path     = "/ready"
+        interval = "5s"
+      }
+    }
+
+    task "migrate" {
+      driver = "docker"
+      lifecycle { hook = "prestart" }
+      config {
+        image   = var.image
+        command = "./migrate"
+      }
+    }
+
+    task "web" {
+      driver = "docker"
+      config {
+        image = "${var.image}"
+        ports = ["http"]
+        args  = ["--port", "${NOMAD_PORT_http}"]
+      }
+      volume_mount {
+        volume      = "data"
+        destination = "/data"
+      }
+      template {
+        destination = "local/app.env"
+        env         = true
+        data        = <<-EOT
+          MODE=production
+        EOT
+      }
+      env {
+        LOG_LEVEL = "info"
+      }
+      resources {
+        cpu    = 250
+        memory = 128
+      }
+      vault { policies = ["shop"] }
+    }
+  }
+}
+`)
+
+	job, err := NewNomadJobParser().Parse(hclPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Name != "shop" || job.Type != "service" || len(job.Groups) != 1 {
+		t.Fatalf("unexpected job %+v", job)
+	}
+	group := job.Groups[0]
+	if group.Count != 2 || !reflect.DeepEqual(group.Ports, []types.JobPort{{Label: "http", To: 8000}}) {
+		t.Errorf("unexpected group %+v", group)
+	}
+	if group.Volumes["data"].Source != "shop-data" {
+		t.Errorf("volumes = %+v", group.Volumes)
+	}
+	if len(group.Services) != 1 || group.Services[0].Checks[0].Path != "/ready" {
+		t.Errorf("services = %+v", group.Services)
+	}
+	if len(group.Tasks) != 2 {
+		t.Fatalf("expected 2 tasks, got %+v", group.Tasks)
+	}
+
+	migrate, web := group.Tasks[0], group.Tasks[1]
+	if migrate.Lifecycle != "prestart" || migrate.Image != "example/shop:1.0" || migrate.Command != "./migrate" {
+		t.Errorf("unexpected migrate task %+v", migrate)
+	}
+	if web.Image != "example/shop:1.0" || !reflect.DeepEqual(web.Args, []string{"--port", "${NOMAD_PORT_http}"}) {
+		t.Errorf("unexpected web task %+v", web)
+	}
+	if web.CPU != 250 || web.Memory != 128 || web.Env["LOG_LEVEL"] != "info" {
+		t.Errorf("unexpected web resources or env %+v", web)
+	}
+	if len(web.Templates) != 1 || !web.Templates[0].Env || web.Templates[0].Data != "MODE=production\n" {
+		t.Errorf("templates = %+v", web.Templates)
+	}
+	if !reflect.DeepEqual(job.Unsupported, []string{"task web: vault"}) {
+		t.Errorf("unsupported = %v", job.Unsupported)
+	}
+
+	jsonPath := writeFile(t, dir, "db.json", `{"Job": {"ID": "db", "Type": "service", "TaskGroups": [{
+  "Name": "db", "Count": 1,
+  "Networks": [{"ReservedPorts": [{"Label": "db", "Value": 5432}]}],
+  "Tasks": [{
+    "Name": "postgres", "Driver": "docker",
+    "Config": {"image": "postgres:16", "ports": ["db"]},
+    "Env": {"POSTGRES_DB": "app"},
+    "Services": [{"Name": "db", "PortLabel": "db", "Checks": [{"Type": "tcp", "Interval": 10000000000}]}],
+    "Resources": {"CPU": 500, "MemoryMB": 256}
+  }]
+}]}}`)
+
+	db, err := NewNomadJobParser().Parse(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Name != "db" || len(db.Groups) != 1 || len(db.Groups[0].Tasks) != 1 {
+		t.Fatalf("unexpected job %+v", db)
+	}
+	if !reflect.DeepEqual(db.Groups[0].Ports, []types.JobPort{{Label: "db", Static: 5432}}) {
+		t.Errorf("ports = %+v", db.Groups[0].Ports)
+	}
+	postgres := db.Groups[0].Tasks[0]
+	if postgres.Image != "postgres:16" || postgres.Memory != 256 || postgres.Env["POSTGRES_DB"] != "app" {
+		t.Errorf("unexpected task %+v", postgres)
+	}
+	if len(postgres.Services) != 1 || postgres.Services[0].Checks[0].Interval != "10s" {
+		t.Errorf("services = %+v", postgres.Services)
+	}
+}
+
+func parseDockerfile(t *testing.T, content string) types.EnhancedServiceConfig {
+	t.Helper()
+	path := writeFile(t, t.TempDir(), "Dockerfile", content)
+	services, err := NewDockerfileParser().Parse(path)
+	if err != nil {
+		t.Fatalf("failed to parse Dockerfile: %v", err)
+	}
+	return services[0]
+}
+
+func TestDockerfileHeredocs(t *testing.T) {
+	service := parseDockerfile(t, `FROM alpine
+RUN <<EOF
+EXPOSE 1111
+EOF
+RUN <<-"SCRIPT" bash
+	EXPOSE 2222
+	SCRIPT
+COPY <<conf <<'other' /etc/
+EXPOSE 3333
+conf
+EXPOSE 4444
+other
+RUN cat <<< "here string" && echo "a << b" 'c <<d'
+EXPOSE 8080
+`)
+	want := []types.PortMapping{{Container: 8080, Protocol: "tcp"}}
+	if !reflect.DeepEqual(service.ResolvedPorts, want) {
+		t.Errorf("ports = %+v, want %+v: heredoc bodies are not instructions, here-strings and quoted << open nothing", service.ResolvedPorts, want)
+	}
+
+	path := writeFile(t, t.TempDir(), "Dockerfile", "FROM alpine\nRUN <<EOF\necho hi\n")
+	if _, err := NewDockerfileParser().Parse(path); err == nil || !strings.Contains(err.Error(), "heredoc EOF is never closed") {
+		t.Errorf("expected an unclosed heredoc error, got %v", err)
+	}
+}
+
+func TestDockerfileContinuations(t *testing.T) {
+	service := parseDockerfile(t, `FROM alpine
+ENV A=1 \
+    # comments inside a continuation are skipped
+    B=2
+EXPOSE 80 \
+       443
+`)
+	if service.Environment["A"] != "1" || service.Environment["B"] != "2" {
+		t.Errorf("environment = %v", service.Environment)
+	}
+	if len(service.ResolvedPorts) != 2 {
+		t.Errorf("ports = %+v", service.ResolvedPorts)
+	}
+
+	escaped := parseDockerfile(t, "# escape=`\nFROM mcr.microsoft.com/windows\nENV A=1 `\n    B=2\n")
+	if escaped.Environment["B"] != "2" {
+		t.Errorf("backtick escape continuation not honoured: %v", escaped.Environment)
+	}
+
+	path := writeFile(t, t.TempDir(), "Dockerfile", "FROM alpine\nRUN echo \\\n")
+	if _, err := NewDockerfileParser().Parse(path); err == nil || !strings.Contains(err.Error(), "line continuation at end of file") {
+		t.Errorf("expected a dangling continuation error, got %v", err)
+	}
+}
+
+func TestDockerfileMultiStage(t *testing.T) {
+	service := parseDockerfile(t, `ARG NODE=20
+FROM node:${NODE} AS base
+ENV NODE_ENV=production
+WORKDIR /app
+EXPOSE 9229
+CMD ["node", "server.js"]
+
+FROM golang:1.22 AS build
+ENV CGO_ENABLED=0
+EXPOSE 6060
+
+FROM base AS runtime
+EXPOSE 3000
+ENTRYPOINT ["tini", "--"]
+`)
+	if service.Environment["NODE_ENV"] != "production" || service.Environment["CGO_ENABLED"] != "" {
+		t.Errorf("environment = %v, want the base stage's only", service.Environment)
+	}
+	if len(service.ResolvedPorts) != 2 || service.ResolvedPorts[1].Container != 3000 {
+		t.Errorf("ports = %+v, want 9229 from base and 3000", service.ResolvedPorts)
+	}
+	if service.OriginalService.WorkingDir != "/app" {
+		t.Errorf("working dir = %q", service.OriginalService.WorkingDir)
+	}
+	if service.OriginalService.Command != nil {
+		t.Errorf("ENTRYPOINT should reset the CMD inherited from base, got %v", service.OriginalService.Command)
+	}
+}
+
+func TestDockerfileVariableExpansion(t *testing.T) {
+	service := parseDockerfile(t, `ARG VERSION=1
+FROM alpine
+ARG PORT=8080
+ARG VERSION
+ENV METRICS_PORT=9090 APP_HOME=/srv/app-${VERSION}
+EXPOSE $PORT ${METRICS_PORT}/udp ${MISSING:-7000}
+WORKDIR $APP_HOME
+WORKDIR current
+USER ${APP_USER:-app}
+`)
+	want := []types.PortMapping{
+		{Container: 8080, Protocol: "tcp"},
+		{Container: 9090, Protocol: "udp"},
+		{Container: 7000, Protocol: "tcp"},
+	}
+	if !reflect.DeepEqual(service.ResolvedPorts, want) {
+		t.Errorf("ports = %+v, want %+v", service.ResolvedPorts, want)
+	}
+	if service.OriginalService.WorkingDir != "/srv/app-1/current" {
+		t.Errorf("working dir = %q, want relative WORKDIR joined to the previous one", service.OriginalService.WorkingDir)
+	}
+	if service.OriginalService.User != "app" {
+		t.Errorf("user = %q", service.OriginalService.User)
+	}
+
+	relative := parseDockerfile(t, "FROM alpine\nWORKDIR app\n")
+	if relative.OriginalService.WorkingDir != "/app" {
+		t.Errorf("relative WORKDIR without a previous one = %q, want /app", relative.OriginalService.WorkingDir)
+	}
+}
+
+func TestDockerfileHealthCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		instruction string
+		want        *types.HealthCheckConfig
+		wantErr     bool
+	}{
+		{
+			name:        "shell form",
+			instruction: "HEALTHCHECK --interval=5s --timeout=2s --start-period=10s --retries=4 CMD curl -f http://localhost:8080/health || exit 1",
+			want: &types.HealthCheckConfig{
+				Test:     []interface{}{"CMD-SHELL", "curl -f http://localhost:8080/health || exit 1"},
+				Interval: "5s", Timeout: "2s", StartPeriod: "10s", Retries: 4,
+			},
+		},
+		{
+			name:        "exec form",
+			instruction: `HEALTHCHECK CMD ["wget", "-qO-", "http://localhost:8080/"]`,
+			want:        &types.HealthCheckConfig{Test: []interface{}{"CMD", "wget", "-qO-", "http://localhost:8080/"}},
+		},
+		{
+			name:        "none",
+			instruction: "HEALTHCHECK NONE",
+			want:        &types.HealthCheckConfig{Disable: true},
+		},
+		{name: "bad retries", instruction: "HEALTHCHECK --retries=many CMD true", wantErr: true},
+		{name: "missing CMD", instruction: "HEALTHCHECK curl localhost", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := writeFile(t, t.TempDir(), "Dockerfile", "FROM alpine\nEXPOSE 8080\n"+test.instruction+"\n")
+			services, err := NewDockerfileParser().Parse(path)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := services[0].OriginalService.HealthCheck; !reflect.DeepEqual(got, test.want) {
+				t.Errorf("healthcheck = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseEnvFile(t *testing.T) {
+	path := writeFile(t, t.TempDir(), ".env", `# settings
+export APP_ENV=production
+GREETING="hello world"
+QUOTED='a # b'
+LEVEL=debug # trailing comment
+EMPTY=
+`)
+
+	env, err := ParseEnvFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"APP_ENV":  "production",
+		"GREETING": "hello world",
+		"QUOTED":   "a # b",
+		"LEVEL":    "debug",
+		"EMPTY":    "",
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("got %v, want %v", env, want)
+	}
+}
@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+// procfilePort is the $PORT the web process is told to listen on
+const procfilePort = 5000
+
+// procfileLine matches `process: command`
+var procfileLine = regexp.MustCompile(`^([A-Za-z0-9_-]+):\s*(.+)$`)
+
+// ProcfileParser turns Procfile processes into services
+type ProcfileParser struct{}
+
+// NewProcfileParser creates a new Procfile parser
+func NewProcfileParser() *ProcfileParser {
+	return &ProcfileParser{}
+}
+
+// Parse reads a Procfile and returns one service per process. Processes
+// share the image built from the Procfile's directory and run through a
+// shell, as on Heroku; the web process gets $PORT, release runs as a batch job.
+func (p *ProcfileParser) Parse(filePath string) ([]types.EnhancedServiceConfig, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Procfile: %w", err)
+	}
+
+	contextDir := filepath.Dir(filePath)
+	app := serviceNameFromDir(contextDir)
+	sourceHash := fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+
+	var services []types.EnhancedServiceConfig
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		match := procfileLine.FindStringSubmatch(line)
+		if match == nil {
+			return nil, fmt.Errorf("failed to parse Procfile: line %d is not `process: command`", lineNumber)
+		}
+		process, command := match[1], strings.TrimSpace(match[2])
+		if seen[process] {
+			return nil, fmt.Errorf("failed to parse Procfile: process %q is declared twice", process)
+		}
+		seen[process] = true
+
+		services = append(services, p.service(app, process, command, contextDir, filePath, sourceHash))
+	}
+	if len(services) == 0 {
+		return nil, fmt.Errorf("failed to parse Procfile: no processes declared")
+	}
+	return services, nil
+}
+
+// service builds the service running one process
+func (p *ProcfileParser) service(app, process, command, contextDir, filePath, sourceHash string) types.EnhancedServiceConfig {
+	// Every process is <dir>-<process>, so web doesn't take the name a
+	// Dockerfile in the same directory gets
+	name := app + "-" + strings.ToLower(process)
+
+	env := map[string]string{}
+	composeEnv := map[string]interface{}{}
+	service := types.DockerComposeService{
+		Build:       contextDir,
+		Command:     []interface{}{"/bin/sh", "-c", command},
+		Environment: composeEnv,
+	}
+
+	var ports []types.PortMapping
+	if process == "web" {
+		port := strconv.Itoa(procfilePort)
+		env["PORT"] = port
+		composeEnv["PORT"] = port
+		service.Expose = []string{port}
+		ports = []types.PortMapping{{Host: procfilePort, Container: procfilePort, Protocol: "tcp"}}
+	}
+	if process == "release" {
+		service.XNomad = &types.NomadExtension{Type: "batch"}
+	}
+
+	return types.EnhancedServiceConfig{
+		Name:            name,
+		OriginalService: service,
+		ResolvedImage:   fmt.Sprintf("{{BUILD_REQUIRED:%s}}", contextDir),
+		Build:           &types.BuildConfig{Builder: types.BuilderBuildpacks, Context: contextDir},
+		ResolvedPorts:   ports,
+		Environment:     env,
+		SourceFile:      filePath,
+		SourceHash:      sourceHash,
+	}
+}
@@ -0,0 +1,265 @@
+package scanner
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Jassem-HCP/nompose/internal/parser"
+	"github.com/Jassem-HCP/nompose/internal/types"
+)
+
+// maxDepth bounds how deep below the scanned directory sources are looked for
+const maxDepth = 5
+
+// skippedDirs are never scanned: dependencies, fixtures and tooling
+var skippedDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+	"testdata":     true,
+}
+
+// composeNames are the compose file names, in the order docker compose prefers them
+var composeNames = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// Source is one deployable found in a directory
+type Source struct {
+	Type      types.SourceType
+	Path      string   // File to parse
+	Overrides []string // Compose override files merged over Path
+	EnvFile   string   // .env applied to Dockerfile and Procfile services
+	Reason    string
+}
+
+// Plan lists what a directory scan found
+type Plan struct {
+	Dir     string
+	Sources []Source
+	Skipped []string // Files found but not converted, relative to Dir, with the reason
+}
+
+// Scanner looks for deployable sources in a directory tree
+type Scanner struct {
+	// DockerfileVariants includes Dockerfile.<env> files, skipped by default
+	// as they usually duplicate the directory's Dockerfile for another environment
+	DockerfileVariants bool
+}
+
+// NewScanner creates a new directory scanner
+func NewScanner() *Scanner {
+	return &Scanner{}
+}
+
+// Scan walks dir for compose files, Dockerfiles and Procfiles. Dockerfiles
+// built by a compose file found in the scan are left to that compose file.
+func (s *Scanner) Scan(dir string) (*Plan, error) {
+	plan := &Plan{Dir: dir}
+	filesByDir := make(map[string][]string)
+	var dirs []string
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != dir && (strings.HasPrefix(entry.Name(), ".") || skippedDirs[entry.Name()] || depth(dir, path) > maxDepth) {
+				return filepath.SkipDir
+			}
+			dirs = append(dirs, path)
+			return nil
+		}
+		if entry.Type().IsRegular() {
+			filesByDir[filepath.Dir(path)] = append(filesByDir[filepath.Dir(path)], entry.Name())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+
+	for _, current := range dirs {
+		s.scanDir(plan, current, filesByDir[current])
+	}
+	s.dropComposeBuilds(plan)
+	s.attachEnvFiles(plan, filesByDir)
+	sort.Strings(plan.Skipped)
+
+	return plan, nil
+}
+
+// scanDir adds the sources in one directory
+func (s *Scanner) scanDir(plan *Plan, dir string, files []string) {
+	present := make(map[string]bool, len(files))
+	for _, name := range files {
+		present[name] = true
+	}
+
+	// One compose project per directory, as docker compose picks it
+	used := make(map[string]bool)
+	for _, compose := range composeNames {
+		if !present[compose] {
+			continue
+		}
+		used[compose] = true
+		source := Source{Type: types.SourceDockerCompose, Path: filepath.Join(dir, compose), Reason: "compose file"}
+		stem := strings.TrimSuffix(compose, filepath.Ext(compose))
+		for _, override := range []string{stem + ".override.yaml", stem + ".override.yml"} {
+			if present[override] {
+				used[override] = true
+				source.Overrides = append(source.Overrides, filepath.Join(dir, override))
+				break
+			}
+		}
+		if len(source.Overrides) > 0 {
+			source.Reason = "compose file with override"
+		}
+		plan.Sources = append(plan.Sources, source)
+		break
+	}
+
+	for _, name := range files {
+		path := filepath.Join(dir, name)
+		lower := strings.ToLower(name)
+		switch {
+		case used[name] || strings.HasSuffix(lower, ".dockerignore"):
+		case isComposeVariant(lower):
+			plan.Skipped = append(plan.Skipped, fmt.Sprintf("%s (alternative compose file; pass it to nompose generate directly)", plan.Relative(path)))
+		case isDockerfileVariant(lower) && !s.DockerfileVariants:
+			plan.Skipped = append(plan.Skipped, fmt.Sprintf("%s (Dockerfile variant; pass --type dockerfile to include it)", plan.Relative(path)))
+		case lower == "dockerfile" || lower == "containerfile" || strings.HasSuffix(lower, ".dockerfile") || isDockerfileVariant(lower):
+			plan.Sources = append(plan.Sources, Source{Type: types.SourceDockerfile, Path: path, Reason: "Dockerfile"})
+		case name == "Procfile":
+			plan.Sources = append(plan.Sources, Source{Type: types.SourceProcfile, Path: path, Reason: "Procfile"})
+		}
+	}
+}
+
+// dropComposeBuilds removes Dockerfiles that a compose service builds
+func (s *Scanner) dropComposeBuilds(plan *Plan) {
+	built := make(map[string]string)
+	for _, source := range plan.Sources {
+		if source.Type != types.SourceDockerCompose {
+			continue
+		}
+		services, err := parser.NewDockerComposeParser().ParseFiles(source.Path, source.Overrides...)
+		if err != nil {
+			continue
+		}
+		for _, service := range services {
+			if dockerfile := buildDockerfile(filepath.Dir(source.Path), service.OriginalService.Build); dockerfile != "" {
+				built[dockerfile] = source.Path
+			}
+		}
+	}
+
+	var sources []Source
+	for _, source := range plan.Sources {
+		if compose, ok := built[filepath.Clean(source.Path)]; ok && source.Type == types.SourceDockerfile {
+			plan.Skipped = append(plan.Skipped, fmt.Sprintf("%s (built by %s)", plan.Relative(source.Path), plan.Relative(compose)))
+			continue
+		}
+		sources = append(sources, source)
+	}
+	plan.Sources = sources
+}
+
+// buildDockerfile resolves the Dockerfile a compose build section uses
+func buildDockerfile(composeDir string, build interface{}) string {
+	context, dockerfile := "", "Dockerfile"
+	switch build := build.(type) {
+	case string:
+		context = build
+	case map[string]interface{}:
+		context, _ = build["context"].(string)
+		if name, ok := build["dockerfile"].(string); ok && name != "" {
+			dockerfile = name
+		}
+	default:
+		return ""
+	}
+	if context == "" {
+		context = "."
+	}
+	if strings.Contains(context, "://") {
+		return ""
+	}
+	return filepath.Clean(filepath.Join(composeDir, context, dockerfile))
+}
+
+// attachEnvFiles gives Dockerfile and Procfile sources the nearest .env at or
+// above their directory, within the scan. Compose reads .env itself for
+// variable substitution, so compose sources are left alone.
+func (s *Scanner) attachEnvFiles(plan *Plan, filesByDir map[string][]string) {
+	root := filepath.Clean(plan.Dir)
+	for i := range plan.Sources {
+		source := &plan.Sources[i]
+		if source.Type == types.SourceDockerCompose {
+			continue
+		}
+		for dir := filepath.Dir(source.Path); ; dir = filepath.Dir(dir) {
+			if contains(filesByDir[dir], ".env") {
+				source.EnvFile = filepath.Join(dir, ".env")
+				break
+			}
+			if filepath.Clean(dir) == root || filepath.Dir(dir) == dir {
+				break
+			}
+		}
+	}
+}
+
+// isComposeVariant matches compose files docker compose doesn't pick by default
+func isComposeVariant(name string) bool {
+	return (strings.HasPrefix(name, "compose.") || strings.HasPrefix(name, "docker-compose.")) &&
+		(strings.HasSuffix(name, ".yml") || strings.HasSuffix(name, ".yaml"))
+}
+
+// isDockerfileVariant reports whether a lowercased file name is an
+// environment-specific Dockerfile such as Dockerfile.dev
+func isDockerfileVariant(name string) bool {
+	return strings.HasPrefix(name, "dockerfile.") || strings.HasPrefix(name, "containerfile.")
+}
+
+// depth counts the directories between root and path
+func depth(root, path string) int {
+	relative, err := filepath.Rel(root, path)
+	if err != nil || relative == "." {
+		return 0
+	}
+	return strings.Count(relative, string(os.PathSeparator)) + 1
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Names lists the plan's sources relative to the scanned directory, for display
+func (p *Plan) Names() []string {
+	names := make([]string, len(p.Sources))
+	for i, source := range p.Sources {
+		names[i] = p.Relative(source.Path)
+		for _, override := range source.Overrides {
+			names[i] += " + " + p.Relative(override)
+		}
+		if source.EnvFile != "" {
+			names[i] += " (env from " + p.Relative(source.EnvFile) + ")"
+		}
+	}
+	return names
+}
+
+// Relative returns path relative to the scanned directory
+func (p *Plan) Relative(path string) string {
+	if relative, err := filepath.Rel(p.Dir, path); err == nil {
+		return relative
+	}
+	return path
+}
@@ -0,0 +1,123 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"compose.yaml":                  "services:\n  api:\n    build: ./api\n  web:\n    build:\n      context: web\n      dockerfile: web.Dockerfile\n",
+		"compose.override.yaml":         "services:\n  api:\n    ports: [\"9229:9229\"]\n",
+		"docker-compose.yml":            "services:\n  old:\n    image: old:1\n",
+		"docker-compose.prod.yml":       "services: {}\n",
+		"api/Dockerfile":                "FROM golang:1.22\n",
+		"web/web.Dockerfile":            "FROM node:20\n",
+		"admin/Dockerfile":              "FROM node:20\n",
+		"admin/Dockerfile.dockerignore": "node_modules\n",
+		"jobs/worker.Dockerfile":        "FROM python:3.12\n",
+		"jobs/Procfile":                 "worker: celery -A app worker\n",
+		".env":                          "LOG_LEVEL=debug\n",
+		"node_modules/x/Dockerfile":     "FROM scratch\n",
+		".github/Dockerfile":            "FROM scratch\n",
+	})
+
+	plan, err := NewScanner().Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make([]string, len(plan.Sources))
+	for i, source := range plan.Sources {
+		got[i] = string(source.Type) + " " + source.Path
+	}
+	want := []string{
+		"docker-compose " + filepath.Join(dir, "compose.yaml"),
+		"dockerfile " + filepath.Join(dir, "admin", "Dockerfile"),
+		"procfile " + filepath.Join(dir, "jobs", "Procfile"),
+		"dockerfile " + filepath.Join(dir, "jobs", "worker.Dockerfile"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected sources:\n got %v\nwant %v", got, want)
+	}
+
+	compose := plan.Sources[0]
+	if !reflect.DeepEqual(compose.Overrides, []string{filepath.Join(dir, "compose.override.yaml")}) {
+		t.Errorf("unexpected overrides %v", compose.Overrides)
+	}
+	if compose.EnvFile != "" {
+		t.Errorf("compose source got env file %s", compose.EnvFile)
+	}
+	for _, source := range plan.Sources[1:] {
+		if source.EnvFile != filepath.Join(dir, ".env") {
+			t.Errorf("%s: env file %q, want the root .env", source.Path, source.EnvFile)
+		}
+	}
+
+	// api/Dockerfile and web/web.Dockerfile are built by compose; two compose files aren't picked
+	if len(plan.Skipped) != 4 {
+		t.Errorf("expected 4 skipped files, got %v", plan.Skipped)
+	}
+}
+
+func TestScanEmpty(t *testing.T) {
+	plan, err := NewScanner().Scan(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Sources) != 0 {
+		t.Errorf("found sources in an empty directory: %+v", plan.Sources)
+	}
+}
+
+func TestScanDockerfileVariants(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"Dockerfile":         "FROM node:20\n",
+		"Dockerfile.dev":     "FROM node:20\n",
+		"Containerfile.prod": "FROM node:20\n",
+		"worker.Dockerfile":  "FROM python:3.12\n",
+	})
+
+	plan, err := NewScanner().Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := plan.Names(); len(plan.Sources) != 2 || plan.Sources[0].Path != filepath.Join(dir, "Dockerfile") ||
+		plan.Sources[1].Path != filepath.Join(dir, "worker.Dockerfile") {
+		t.Errorf("variants should be skipped by default, got %v", names)
+	}
+	want := []string{
+		"Containerfile.prod (Dockerfile variant; pass --type dockerfile to include it)",
+		"Dockerfile.dev (Dockerfile variant; pass --type dockerfile to include it)",
+	}
+	if !reflect.DeepEqual(plan.Skipped, want) {
+		t.Errorf("skipped = %v, want %v", plan.Skipped, want)
+	}
+
+	scanner := NewScanner()
+	scanner.DockerfileVariants = true
+	plan, err = scanner.Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Sources) != 4 || len(plan.Skipped) != 0 {
+		t.Errorf("variants should be sources with DockerfileVariants, got %v (skipped %v)", plan.Names(), plan.Skipped)
+	}
+}
@@ -0,0 +1,140 @@
+package shellwords
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Split breaks a command string into words the way a POSIX shell would,
+// honouring single quotes, double quotes and backslash escapes. It does not
+// expand variables or interpret operators; use RequiresShell to find out
+// whether the string depends on those.
+func Split(input string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+
+	for i := 0; i < len(input); i++ {
+		ch := input[i]
+
+		switch {
+		case ch == '\\':
+			i++
+			if i >= len(input) {
+				return nil, fmt.Errorf("trailing backslash in %q", input)
+			}
+			// A backslash-newline is a line continuation
+			if input[i] != '\n' {
+				word.WriteByte(input[i])
+				inWord = true
+			}
+
+		case ch == '\'':
+			end := strings.IndexByte(input[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote in %q", input)
+			}
+			word.WriteString(input[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+
+		case ch == '"':
+			i++
+			for ; i < len(input) && input[i] != '"'; i++ {
+				// Inside double quotes a backslash only escapes these characters
+				if input[i] == '\\' && i+1 < len(input) && strings.IndexByte("\"\\$`\n", input[i+1]) >= 0 {
+					i++
+					if input[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(input[i])
+			}
+			if i >= len(input) {
+				return nil, fmt.Errorf("unterminated double quote in %q", input)
+			}
+			inWord = true
+
+		case isSpace(ch):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+
+		default:
+			word.WriteByte(ch)
+			inWord = true
+		}
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// RequiresShell reports whether the command string uses shell features
+// (pipes, lists, redirects, substitutions, variables, globs, subshells,
+// comments or tilde expansion) outside of quotes, along with a short description of the first one found. Such a
+// command only behaves as intended when run through `sh -c`. A $$ is compose's escape for a literal $, not a variable.
+func RequiresShell(input string) (bool, string) {
+	inSingle, inDouble := false, false
+
+	for i := 0; i < len(input); i++ {
+		ch := input[i]
+
+		switch {
+		case ch == '\\' && !inSingle:
+			i++
+		case ch == '\'' && !inDouble:
+			inSingle = !inSingle
+		case ch == '"' && !inSingle:
+			inDouble = !inDouble
+		case inSingle:
+			continue
+		case ch == '$' && i+1 < len(input) && input[i+1] == '$':
+			// Compose's escape for a literal $
+			i++
+		case ch == '$':
+			// Variables and substitutions expand even inside double quotes
+			if i+1 < len(input) && input[i+1] == '(' {
+				return true, "command substitution"
+			}
+			return true, "variable expansion"
+		case ch == '`':
+			return true, "command substitution"
+		case inDouble:
+			continue
+		case ch == '|':
+			if i+1 < len(input) && input[i+1] == '|' {
+				return true, "'||' list"
+			}
+			return true, "pipe"
+		case ch == '&':
+			if i+1 < len(input) && input[i+1] == '&' {
+				return true, "'&&' list"
+			}
+			return true, "background job"
+		case ch == ';':
+			return true, "command list"
+		case ch == '>' || ch == '<':
+			return true, "redirect"
+		case ch == '*' || ch == '?':
+			return true, "glob"
+		case ch == '(' || ch == ')':
+			return true, "subshell"
+		case ch == '#' && (i == 0 || isSpace(input[i-1])):
+			return true, "comment"
+		case ch == '~' && (i == 0 || isSpace(input[i-1])):
+			return true, "tilde expansion"
+		}
+	}
+
+	return false, ""
+}
+
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
@@ -0,0 +1,89 @@
+package shellwords
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "words", input: "node server.js --port 3000", want: []string{"node", "server.js", "--port", "3000"}},
+		{name: "extra whitespace", input: "  a \t b\n c  ", want: []string{"a", "b", "c"}},
+		{name: "empty", input: "", want: nil},
+		{name: "single quotes", input: `echo 'hello world'`, want: []string{"echo", "hello world"}},
+		{name: "single quotes keep backslashes", input: `echo 'a\nb "c"'`, want: []string{"echo", `a\nb "c"`}},
+		{name: "double quotes", input: `echo "hello world"`, want: []string{"echo", "hello world"}},
+		{name: "double quote escapes", input: `echo "say \"hi\" \\ \$HOME \n"`, want: []string{"echo", `say "hi" \ $HOME \n`}},
+		{name: "empty quotes are a word", input: `run "" ''`, want: []string{"run", "", ""}},
+		{name: "adjacent quotes join", input: `--name="my app"'s'`, want: []string{"--name=my apps"}},
+		{name: "backslash escapes", input: `touch my\ file \"quoted\"`, want: []string{"touch", "my file", `"quoted"`}},
+		{name: "line continuation", input: "run \\\n  --flag", want: []string{"run", "--flag"}},
+		{name: "line continuation in double quotes", input: "echo \"a\\\nb\"", want: []string{"echo", "ab"}},
+		{name: "unterminated single quote", input: `echo 'oops`, wantErr: true},
+		{name: "unterminated double quote", input: `echo "oops`, wantErr: true},
+		{name: "trailing backslash", input: `echo oops\`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Split(test.input)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("Split(%q) = %q, want an error", test.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Split(%q) failed: %v", test.input, err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Split(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+func TestRequiresShell(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   bool
+		reason string
+	}{
+		{input: "node server.js --port 3000"},
+		{input: `echo "a | b; c && d > e"`},
+		{input: `echo '$HOME $(date) *'`},
+		{input: `echo \$HOME \| \;`},
+		{input: "echo $$HOSTNAME costs $$5"},
+		{input: "echo $$$HOME", want: true, reason: "variable expansion"},
+		{input: "curl -f http://localhost:8080/health?x=1#top", want: true, reason: "glob"},
+		{input: "cat a.log | grep error", want: true, reason: "pipe"},
+		{input: "make || exit 1", want: true, reason: "'||' list"},
+		{input: "migrate && serve", want: true, reason: "'&&' list"},
+		{input: "worker &", want: true, reason: "background job"},
+		{input: "cd /app; serve", want: true, reason: "command list"},
+		{input: "serve > /var/log/app.log", want: true, reason: "redirect"},
+		{input: "serve < input", want: true, reason: "redirect"},
+		{input: "echo $HOME", want: true, reason: "variable expansion"},
+		{input: `echo "${HOME}"`, want: true, reason: "variable expansion"},
+		{input: "echo $(date)", want: true, reason: "command substitution"},
+		{input: "echo `date`", want: true, reason: "command substitution"},
+		{input: "rm *.tmp", want: true, reason: "glob"},
+		{input: "(cd /app && serve)", want: true, reason: "subshell"},
+		{input: "serve # start the app", want: true, reason: "comment"},
+		{input: "serve --color=#fff"},
+		{input: "ls ~/data", want: true, reason: "tilde expansion"},
+		{input: "ls a~b"},
+	}
+
+	for _, test := range tests {
+		got, reason := RequiresShell(test.input)
+		if got != test.want || reason != test.reason {
+			t.Errorf("RequiresShell(%q) = %t, %q; want %t, %q", test.input, got, reason, test.want, test.reason)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package types
+
+// Builders that turn a build context into an image
+const (
+	BuilderDocker     = ""           // docker buildx with a Dockerfile
+	BuilderBuildpacks = "buildpacks" // pack, for Procfile apps without a Dockerfile
+)
+
+// BuildConfig is a service's build section in its long form
+type BuildConfig struct {
+	Builder    string
+	Context    string            // Directory or git URL, relative to where nompose runs
+	Dockerfile string            // Relative to Context; empty means Dockerfile
+	Args       map[string]string // --build-arg values
+	Target     string            // Stage to build
+	CacheFrom  []string
+	Platforms  []string
+	Secrets    []BuildSecret
+}
+
+// BuildSecret is a secret mounted into the build
+type BuildSecret struct {
+	ID          string
+	File        string // Read from this file...
+	Environment string // ...or from this environment variable
+}
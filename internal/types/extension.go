@@ -0,0 +1,148 @@
+package types
+
+// NomadExtension is the `x-nomad` compose extension. At the top level of the
+// compose file it holds defaults for every service; on a service it wins over
+// those defaults, and over anything nompose detects or guesses.
+//
+//	x-nomad:
+//	  type: batch
+//	  count: 3
+//	  resources: {cpu: 500, memory: 256, memory_max: 512}
+//	  constraints:
+//	    - {attribute: "${attr.kernel.name}", value: linux}
+//	  ports: {"8080": web}          # container port → port label
+//	  volumes:                      # compose volume → Nomad volume
+//	    data: {type: csi, source: pg-data}
+//	  tags: [public]
+//	  update: {max_parallel: 2, auto_revert: true}
+//	  periodic: {cron: "0 3 * * *", prohibit_overlap: true}  # type batch
+//	  hcl:                          # raw HCL appended to the job, group or task
+//	    group: |
+//	      ephemeral_disk { size = 500 }
+type NomadExtension struct {
+	Type        string                 `yaml:"type,omitempty"`
+	Count       int                    `yaml:"count,omitempty"`
+	Resources   *NomadResources        `yaml:"resources,omitempty"`
+	Constraints []NomadConstraint      `yaml:"constraints,omitempty"`
+	Ports       map[string]string      `yaml:"ports,omitempty"`
+	Volumes     map[string]NomadVolume `yaml:"volumes,omitempty"`
+	Tags        []string               `yaml:"tags,omitempty"`
+	Update      *NomadUpdate           `yaml:"update,omitempty"`
+	Periodic    *NomadPeriodic         `yaml:"periodic,omitempty"`
+	HCL         NomadHCL               `yaml:"hcl,omitempty"`
+}
+
+// NomadPeriodic runs a batch job on a schedule
+type NomadPeriodic struct {
+	Cron            string `yaml:"cron,omitempty"`
+	ProhibitOverlap bool   `yaml:"prohibit_overlap,omitempty"`
+	TimeZone        string `yaml:"time_zone,omitempty"`
+}
+
+// NomadResources sets the task resources
+type NomadResources struct {
+	CPU       int `yaml:"cpu,omitempty"`
+	Memory    int `yaml:"memory,omitempty"`
+	MemoryMax int `yaml:"memory_max,omitempty"`
+}
+
+// NomadConstraint is a job constraint
+type NomadConstraint struct {
+	Attribute string `yaml:"attribute,omitempty"`
+	Operator  string `yaml:"operator,omitempty"`
+	Value     string `yaml:"value,omitempty"`
+}
+
+// NomadVolume maps a compose named volume to a Nomad volume
+type NomadVolume struct {
+	Type           string `yaml:"type,omitempty"` // host, csi or docker
+	Source         string `yaml:"source,omitempty"`
+	ReadOnly       bool   `yaml:"read_only,omitempty"`
+	AccessMode     string `yaml:"access_mode,omitempty"`
+	AttachmentMode string `yaml:"attachment_mode,omitempty"`
+	PerAlloc       bool   `yaml:"per_alloc,omitempty"` // CSI only: each allocation claims <source>[<index>]
+}
+
+// NomadUpdate sets update stanza fields, over those converted from deploy.update_config
+type NomadUpdate struct {
+	MaxParallel      int    `yaml:"max_parallel,omitempty"`
+	Stagger          string `yaml:"stagger,omitempty"`
+	MinHealthyTime   string `yaml:"min_healthy_time,omitempty"`
+	HealthyDeadline  string `yaml:"healthy_deadline,omitempty"`
+	ProgressDeadline string `yaml:"progress_deadline,omitempty"`
+	AutoRevert       *bool  `yaml:"auto_revert,omitempty"`
+	Canary           int    `yaml:"canary,omitempty"`
+	AutoPromote      *bool  `yaml:"auto_promote,omitempty"`
+}
+
+// NomadHCL holds raw HCL appended to the job, group and task bodies
+type NomadHCL struct {
+	Job   string `yaml:"job,omitempty"`
+	Group string `yaml:"group,omitempty"`
+	Task  string `yaml:"task,omitempty"`
+}
+
+// WithDefaults returns the extension with unset fields taken from defaults.
+// Constraints and raw HCL from both are kept, defaults first.
+func (e *NomadExtension) WithDefaults(defaults *NomadExtension) *NomadExtension {
+	if defaults == nil {
+		return e
+	}
+	if e == nil {
+		copied := *defaults
+		return &copied
+	}
+
+	merged := *e
+	if merged.Type == "" {
+		merged.Type = defaults.Type
+	}
+	if merged.Count == 0 {
+		merged.Count = defaults.Count
+	}
+	if merged.Resources == nil {
+		merged.Resources = defaults.Resources
+	}
+	if merged.Tags == nil {
+		merged.Tags = defaults.Tags
+	}
+	if merged.Update == nil {
+		merged.Update = defaults.Update
+	}
+	if merged.Periodic == nil {
+		merged.Periodic = defaults.Periodic
+	}
+	merged.Constraints = append(append([]NomadConstraint{}, defaults.Constraints...), e.Constraints...)
+	merged.Ports = mergeMaps(defaults.Ports, e.Ports)
+	merged.Volumes = mergeMaps(defaults.Volumes, e.Volumes)
+	merged.HCL = NomadHCL{
+		Job:   joinSnippets(defaults.HCL.Job, e.HCL.Job),
+		Group: joinSnippets(defaults.HCL.Group, e.HCL.Group),
+		Task:  joinSnippets(defaults.HCL.Task, e.HCL.Task),
+	}
+	return &merged
+}
+
+func mergeMaps[V any](defaults, values map[string]V) map[string]V {
+	if len(defaults) == 0 {
+		return values
+	}
+	merged := make(map[string]V, len(defaults)+len(values))
+	for key, value := range defaults {
+		merged[key] = value
+	}
+	for key, value := range values {
+		merged[key] = value
+	}
+	return merged
+}
+
+func joinSnippets(first, second string) string {
+	switch {
+	case first == "":
+		return second
+	case second == "":
+		return first
+	}
+	return first + "\n" + second
+}
@@ -0,0 +1,106 @@
+package types
+
+// NomadJob is a Nomad job read back from HCL or JSON, with what
+// `nompose reverse` needs to run it with docker compose
+type NomadJob struct {
+	Name        string
+	Type        string // service, batch, system or sysbatch
+	Groups      []JobGroup
+	SourceFile  string
+	Unsupported []string // Nomad-only settings found in the job
+}
+
+// JobGroup is a task group
+type JobGroup struct {
+	Name     string
+	Count    int
+	Ports    []JobPort
+	Volumes  map[string]JobVolume
+	Services []JobService // Group-level services, e.g. Consul Connect ones
+	Tasks    []JobTask
+}
+
+// JobPort is a network port of a group
+type JobPort struct {
+	Label  string
+	Static int // Host port; 0 for dynamic ports
+	To     int // Port inside the container; 0 maps to the host port
+}
+
+// JobVolume is a group volume block
+type JobVolume struct {
+	Type     string // host or csi
+	Source   string
+	ReadOnly bool
+}
+
+// JobTask is a task of a group
+type JobTask struct {
+	Name         string
+	Driver       string
+	Image        string
+	Entrypoint   []string
+	Command      string
+	Args         []string
+	WorkDir      string
+	User         string
+	Ports        []string // Port labels the task uses
+	Mounts       []string // Docker volumes and mounts as compose volumes
+	Privileged   bool
+	CapAdd       []string
+	CapDrop      []string
+	Labels       map[string]string
+	Hostname     string
+	Env          map[string]string
+	Templates    []JobTemplate
+	VolumeMounts []JobVolumeMount
+	Lifecycle    string // prestart, poststart or poststop; empty for main tasks
+	Sidecar      bool
+	CPU          int // MHz
+	Memory       int // MB
+	MemoryMax    int // MB
+	Services     []JobService
+}
+
+// JobTemplate is a template block
+type JobTemplate struct {
+	Data        string
+	Source      string // Template file, when not inline
+	Destination string
+	Env         bool
+}
+
+// JobVolumeMount mounts a group volume into a task
+type JobVolumeMount struct {
+	Volume      string
+	Destination string
+	ReadOnly    bool
+}
+
+// JobService is a service registration
+type JobService struct {
+	Name      string
+	Port      string
+	Checks    []JobCheck
+	Upstreams []JobUpstream // Consul Connect upstreams
+}
+
+// JobCheck is a service check
+type JobCheck struct {
+	Type     string // http, tcp, script or grpc
+	Path     string
+	Protocol string
+	Port     string
+	Command  string
+	Args     []string
+	Interval string
+	Timeout  string
+	Retries  int // check_restart limit
+	Grace    string
+}
+
+// JobUpstream is a Consul Connect upstream
+type JobUpstream struct {
+	Service   string
+	LocalPort int
+}
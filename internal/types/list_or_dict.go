@@ -0,0 +1,40 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ListOrDict is a compose field that may be written either as a mapping
+// (`KEY: value`) or as a list of `KEY=value` strings. A list entry without
+// '=' is kept as a key with an empty value.
+type ListOrDict map[string]string
+
+// UnmarshalYAML accepts both the mapping and the list form
+func (l *ListOrDict) UnmarshalYAML(value *yaml.Node) error {
+	result := make(ListOrDict)
+
+	switch value.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			key, val := value.Content[i], value.Content[i+1]
+			if val.Tag == "!!null" {
+				result[key.Value] = ""
+			} else {
+				result[key.Value] = val.Value
+			}
+		}
+	case yaml.SequenceNode:
+		for _, item := range value.Content {
+			key, val, _ := strings.Cut(item.Value, "=")
+			result[key] = val
+		}
+	default:
+		return fmt.Errorf("line %d: expected a mapping or a list, got %q", value.Line, value.Value)
+	}
+
+	*l = result
+	return nil
+}
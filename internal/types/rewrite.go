@@ -0,0 +1,11 @@
+package types
+
+// ImageRewrite is one rule of the image_rewrites configuration. Rules are
+// applied in order, each to the result of the previous ones.
+type ImageRewrite struct {
+	Match   string `yaml:"match"`   // Repository glob on the full name (docker.io/library/nginx); * matches anything
+	Replace string `yaml:"replace"` // New repository; each * is replaced by what the matching * in Match matched
+	Tag     string `yaml:"tag"`     // Only rewrite images whose tag matches this regexp
+	Pin     string `yaml:"pin"`     // New tag; may refer to groups of Tag as $1
+	Lock    bool   `yaml:"lock"`    // Replace the tag with the digest recorded in nompose.lock
+}
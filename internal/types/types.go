@@ -8,7 +8,26 @@ const (
 	SourceDockerfile    SourceType = "dockerfile"  
 	SourceDockerImage   SourceType = "docker-image"
 	SourceLocalDir      SourceType = "local-directory"
-	SourceGitHubRepo    SourceType = "github-repo"
+	SourceGitRepo       SourceType = "git-repo"
+	SourceKubernetes    SourceType = "kubernetes"
+	SourceNomadJob      SourceType = "nomad-job"
+	SourceSwarmStack    SourceType = "swarm-stack"
+	SourceProcfile      SourceType = "procfile"
+)
+
+// SourceTypes lists every source type, in the order they're offered to --type
+var SourceTypes = []SourceType{
+	SourceDockerCompose, SourceSwarmStack, SourceDockerfile, SourceDockerImage, SourceProcfile,
+	SourceKubernetes, SourceNomadJob, SourceLocalDir, SourceGitRepo,
+}
+
+// Confidence says how sure detection is about a source type
+type Confidence string
+
+const (
+	ConfidenceHigh   Confidence = "high"   // Content or a canonical file name says so
+	ConfidenceMedium Confidence = "medium" // Content suggests it
+	ConfidenceLow    Confidence = "low"    // Guessed from the name alone
 )
 
 // DetectionResult holds what we detected about the source
@@ -17,6 +36,8 @@ type DetectionResult struct {
 	Source     string
 	Valid      bool
 	Error      string
+	Confidence Confidence
+	Reason     string // Why the source was classified this way
 }
 
 // EnhancedServiceConfig preserves all docker-compose data
@@ -24,9 +45,34 @@ type EnhancedServiceConfig struct {
 	Name            string
 	OriginalService DockerComposeService
 	ResolvedImage   string                 // Final image after user input
+	Build           *BuildConfig           // How ResolvedImage is built, when nompose's build files build it
 	ResolvedPorts   []PortMapping         // Processed port mappings
 	Environment     map[string]string      // Flattened environment
 	Dependencies    []string               // Flattened dependencies
+	SourceFile      string                 // File the service was parsed from
+	SourceHash      string                 // sha256 of the source file, for tracing jobs back to it
+	SourceRepo      string                 // Git repository the source file was read from, if any
+	SourceCommit    string                 // Commit of SourceRepo that was checked out
+	Ingress         *IngressRoute          // Public hostname/path, when exposed through an ingress
+	Tasks           []Task                 // More tasks of the group, e.g. the other containers of a Kubernetes pod
+}
+
+// Task is a further container run in a service's group
+type Task struct {
+	Name        string
+	Image       string
+	Service     DockerComposeService // Command, entrypoint, volumes, user and working dir
+	Environment map[string]string
+	Lifecycle   string // "prestart" runs the task before the main task; empty runs it alongside
+	Sidecar     bool   // Keep a prestart task running for the life of the group
+	CPU         int    // MHz; 0 uses the default
+	Memory      int    // MB; 0 uses the default
+}
+
+// IngressRoute is where an ingress exposes a service
+type IngressRoute struct {
+	Host string // e.g. app.example.com
+	Path string // e.g. /api
 }
 
 // PortMapping represents a port configuration
@@ -49,10 +95,31 @@ type DockerComposeService struct {
 	Restart     string                 `yaml:"restart,omitempty"`
 	Networks    interface{}            `yaml:"networks,omitempty"`
 	Command     interface{}            `yaml:"command,omitempty"`
+	Entrypoint  interface{}            `yaml:"entrypoint,omitempty"`
 	WorkingDir  string                 `yaml:"working_dir,omitempty"`
 	User        string                 `yaml:"user,omitempty"`
-	Labels      map[string]string      `yaml:"labels,omitempty"`
+	Labels      ListOrDict             `yaml:"labels,omitempty"`
 	Expose      []string               `yaml:"expose,omitempty"`
+	XNomad      *NomadExtension        `yaml:"x-nomad,omitempty"`
+
+	// Docker runtime options
+	CapAdd      []string               `yaml:"cap_add,omitempty"`
+	CapDrop     []string               `yaml:"cap_drop,omitempty"`
+	Privileged  bool                   `yaml:"privileged,omitempty"`
+	Devices     []interface{}          `yaml:"devices,omitempty"`
+	Ulimits     map[string]interface{} `yaml:"ulimits,omitempty"`
+	Sysctls     ListOrDict             `yaml:"sysctls,omitempty"`
+	ShmSize     interface{}            `yaml:"shm_size,omitempty"`
+	Init        *bool                  `yaml:"init,omitempty"`
+	ReadOnly    bool                   `yaml:"read_only,omitempty"`
+	SecurityOpt []string               `yaml:"security_opt,omitempty"`
+	Tmpfs       interface{}            `yaml:"tmpfs,omitempty"`
+	Ipc         string                 `yaml:"ipc,omitempty"`
+	Pid         string                 `yaml:"pid,omitempty"`
+	DNS         interface{}            `yaml:"dns,omitempty"`
+	ExtraHosts  ListOrDict             `yaml:"extra_hosts,omitempty"`
+	Hostname    string                 `yaml:"hostname,omitempty"`
+	Logging     *LoggingConfig         `yaml:"logging,omitempty"`
 }
 
 // HealthCheckConfig represents healthcheck configuration
@@ -62,11 +129,39 @@ type HealthCheckConfig struct {
 	Timeout     string      `yaml:"timeout,omitempty"`
 	Retries     int         `yaml:"retries,omitempty"`
 	StartPeriod string      `yaml:"start_period,omitempty"`
+	Disable     bool        `yaml:"disable,omitempty"`
+}
+
+// LoggingConfig represents the logging driver configuration
+type LoggingConfig struct {
+	Driver  string            `yaml:"driver,omitempty"`
+	Options map[string]string `yaml:"options,omitempty"`
 }
 
 // DeployConfig represents deploy configuration  
 type DeployConfig struct {
-	Replicas int `yaml:"replicas,omitempty"`
+	Replicas       int                  `yaml:"replicas,omitempty"`
+	RestartPolicy  *RestartPolicyConfig `yaml:"restart_policy,omitempty"`
+	UpdateConfig   *UpdateConfig        `yaml:"update_config,omitempty"`
+	RollbackConfig *UpdateConfig        `yaml:"rollback_config,omitempty"`
+}
+
+// RestartPolicyConfig represents deploy.restart_policy
+type RestartPolicyConfig struct {
+	Condition   string `yaml:"condition,omitempty"`
+	Delay       string `yaml:"delay,omitempty"`
+	MaxAttempts int    `yaml:"max_attempts,omitempty"`
+	Window      string `yaml:"window,omitempty"`
+}
+
+// UpdateConfig represents deploy.update_config and deploy.rollback_config
+type UpdateConfig struct {
+	Parallelism     *int    `yaml:"parallelism,omitempty"` // nil means unset; 0 means all at once
+	Delay           string  `yaml:"delay,omitempty"`
+	FailureAction   string  `yaml:"failure_action,omitempty"`
+	Monitor         string  `yaml:"monitor,omitempty"`
+	MaxFailureRatio float64 `yaml:"max_failure_ratio,omitempty"`
+	Order           string  `yaml:"order,omitempty"`
 }
 
 // Legacy types for backward compatibility (if needed)
@@ -83,19 +178,52 @@ type ServiceConfig struct {
 
 type GenerateOptions struct {
 	ServiceName  string
+	Image        string            // Image for a Dockerfile source, instead of prompting
 	Port         int
 	Instances    int
 	CPU          int
 	Memory       int
 	HealthCheck  string
 	Datacenter   string
+	Datacenters  []string          // Overrides Datacenter when set
 	Namespace    string
+	Region       string
+	NodePool     string
+	Priority     int
 	OutputFile   string
 	OutputFormat string
 	DryRun       bool
+	Force        bool              // Overwrite generated files even if they were edited by hand
 	Interactive  bool
 	ForceType    string
 	WithConsul   bool
 	WithVault    bool
 	WithIngress  bool
+	LogDriver    string            // Logging driver injected into every job
+	LogOptions   map[string]string // Options for LogDriver
+	Mesh         string            // Service mesh mode ("consul-connect")
+	UpstreamAddr string            // How mesh upstreams are addressed ("localhost" or "env")
+	AddressRewrite string          // Provider for other services' addresses (none, consul-dns, consul-template, nomad-template)
+	ServiceProvider string         // Service registration provider (consul, nomad)
+	IngressMode  string            // Ingress used when WithIngress is set (traefik, fabio, consul-gateway)
+	LabelTagPrefixes []string      // Labels starting with one of these become service tags
+	Overrides    map[string]ServiceOverride // Per-service settings from --set, by service name
+	Registry     string            // Prefix for images nompose builds (e.g. registry.example.com/team)
+	ImageTag     string            // Tag of images nompose builds; defaults to the source's git commit
+	VolumeType   string            // How named volumes are mounted (host, csi, docker)
+}
+
+// ServiceOverride holds per-service settings that win over compose values,
+// global flags and heuristics. Zero values mean "not set".
+type ServiceOverride struct {
+	CPU         int
+	Memory      int
+	Count       int
+	Port        int      // Published port of the main port mapping
+	Priority    int
+	HealthCheck string   // HTTP check path, or "none"
+	Namespace   string
+	Region      string
+	NodePool    string
+	Datacenters []string
 }